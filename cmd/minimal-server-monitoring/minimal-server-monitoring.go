@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
 )
@@ -43,6 +46,26 @@ func main() {
 	configPath := os.Args[1]
 	cfg := config.LoadConfiguration(configPath)
 
+	router, err := notifier.MakeRouter(cfg.Routes, slices.Collect(maps.Keys(cfg.Notifiers)))
+	if err != nil {
+		logging.Fatal("Invalid routes: %v", err)
+	}
+
+	scraperTags := map[string][]string{}
+	scraperPolicies := map[string]provider.AlertPolicy{}
+	for scraperName, scraperCfg := range cfg.Scrapers {
+		scraperTags[scraperName] = scraperCfg.Tags
+		scraperPolicies[scraperName] = scraperCfg.Alert
+	}
+	inhibitor, err := alert.MakeInhibitor(cfg.Alert.Dependencies, scraperTags)
+	if err != nil {
+		logging.Fatal("Invalid dependencies: %v", err)
+	}
+	policies, err := alert.MakePolicies(cfg.Alert, scraperPolicies)
+	if err != nil {
+		logging.Fatal("Invalid alert policies: %v", err)
+	}
+
 	storage := storage.NewJSONStorage(cfg.CachePath)
 	storage.Sync(true) // Test if storage can be synced
 
@@ -54,12 +77,12 @@ func main() {
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 	// alert center
-	alert.AlertCenter(ctx, cfg.Alert, scrapeResultChan, notifyChan)
+	alert.AlertCenter(ctx, cfg.Alert, router, inhibitor, policies, storage, scrapeResultChan, notifyChan)
 
 	// notifier
 	go func() {
 		defer wg.Done()
-		notifier.LoadAndRunNotifiers(ctx, cfg.MachineName, cfg.Notifiers, notifyChan)
+		notifier.LoadAndRunNotifiers(ctx, cfg.MachineName, cfg.Notifiers, router, notifyChan)
 	}()
 
 	// Start metric scraping
@@ -7,33 +7,78 @@ import (
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
 )
 
-func AlertCenter(ctx context.Context, alertCfg Config, scrapResultChan <-chan any, notifyChan chan<- notifier.Message) {
+func AlertCenter(ctx context.Context, alertCfg Config, router *notifier.Router, inhibitor *Inhibitor, policies *Policies, storageInstance storage.Storager, scrapResultChan <-chan any, notifyChan chan<- notifier.Message) {
 
 	rawNotifications := make(chan metricIdWithMsg)
 	filteredNotifications := make(chan notifier.Message)
-	metricStateMachines := map[string]*MetricStateMachine{}
+	escalatedNotifications := make(chan notifier.Message)
+	silencedNotifications := make(chan notifier.Message)
+	routedNotifications := make(chan notifier.Message)
+	alertStorage := storage.NewSubStorage(storageInstance, "alert")
+	persistence := loadMetricStatePersistence(alertStorage, time.Now())
+	metricStateMachines := persistence.restore(policies.makeStateMachine)
+	silencer, err := MakeSilencer(alertCfg.Silences, alertCfg.SilenceDir, alertStorage)
+	if err != nil {
+		logging.Fatal("Unable to setup silences: %v", err)
+	}
+	escalator, err := MakeEscalator(alertCfg.Escalations, router, silencer, utils.SystemClock)
+	if err != nil {
+		logging.Fatal("Unable to setup escalations: %v", err)
+	}
+	escalator.WithAckDir(alertCfg.AckDir)
+	if err := checkGroupBy(alertCfg.Grouping.GroupBy); err != nil {
+		logging.Fatal("Unable to setup grouping: %v", err)
+	}
+	severityOrder, err := parseSeverityOrder(alertCfg.Grouping.SeverityOrder)
+	if err != nil {
+		logging.Fatal("Unable to setup grouping: %v", err)
+	}
 
 	//Step 1: convert scrape result to messages
 	go func(outputChan chan<- metricIdWithMsg) {
+		if summary := persistence.summary(); summary != nil {
+			outputChan <- metricIdWithMsg{message: *summary}
+		}
 		for scrapeResult := range scrapResultChan {
 			switch element := scrapeResult.(type) {
 			case provider.MetricMessage:
-				outputChan <- metricIdWithMsg{
-					metricId: element.MetricID,
-					message:  notifier.MakeMessage(notifier.Notification, "%v: %v", element.Name, element.Description),
+				msg := notifier.MakeMessage(notifier.Notification, "%v: %v", element.Name, element.Description)
+				msg.Scraper = element.Scraper
+				msg.MetricID = element.MetricID
+				if !inhibitor.Inhibit(msg, metricStateMachines) {
+					outputChan <- metricIdWithMsg{
+						metricId: element.MetricID,
+						message:  msg,
+					}
 				}
 			case provider.MetricState:
+				now := time.Now()
 				if metricStateMachines[element.MetricID] == nil {
-					metricStateMachines[element.MetricID] = MakeMetricStateMachine(alertCfg.HealthyThreshold, alertCfg.UnhealthyThreshold, alertCfg.FailureReminder.AsDuration(), alertCfg.FailureReminderCount, alertCfg.DailyReminderTime)
+					metricStateMachines[element.MetricID] = policies.makeStateMachine(element.Scraper, element.MetricID)
+				}
+				optMessage := metricStateMachines[element.MetricID].Update(element, now)
+				persistence.update(element, metricStateMachines[element.MetricID])
+				for _, expiredMessage := range persistence.expire(now, metricStateMachines) {
+					outputChan <- expiredMessage
 				}
-				optMessage := metricStateMachines[element.MetricID].Update(element, time.Now())
 
 				if optMessage != nil {
+					optMessage.Scraper = element.Scraper
+					optMessage.MetricID = element.MetricID
+					if !inhibitor.Inhibit(*optMessage, metricStateMachines) {
+						outputChan <- metricIdWithMsg{
+							metricId: element.MetricID,
+							message:  *optMessage}
+					}
+				}
+				for _, releasedMessage := range inhibitor.Release(metricStateMachines) {
 					outputChan <- metricIdWithMsg{
-						metricId: element.MetricID,
-						message:  *optMessage}
+						metricId: releasedMessage.MetricID,
+						message:  releasedMessage}
 				}
 			default:
 				logging.Warning("Unsupported element received: %v", element)
@@ -43,11 +88,35 @@ func AlertCenter(ctx context.Context, alertCfg Config, scrapResultChan <-chan an
 
 	//Step 2: filter messages
 	go func() {
-		MakeAndRunAlertFilters(rawNotifications, filteredNotifications)
+		MakeAndRunAlertFilters(alertCfg.Filtering, rawNotifications, filteredNotifications)
+	}()
+
+	//Step 3: escalate unresolved failures (destination of escalated messages is set)
+	go func() {
+		MakeAndRunEscalation(escalator, filteredNotifications, escalatedNotifications)
+	}()
+
+	//Step 4: suppress silenced messages
+	go func() {
+		MakeAndRunSilencing(silencer, escalatedNotifications, silencedNotifications)
+	}()
+
+	//Step 5: route messages to notifiers (unless already escalated)
+	go func() {
+		for msg := range silencedNotifications {
+			if msg.Notifiers == nil {
+				msg.Notifiers = router.Route(msg)
+			}
+			if len(msg.Notifiers) == 0 {
+				logging.Debug("No route for: %v", msg)
+				continue
+			}
+			routedNotifications <- msg
+		}
 	}()
 
-	//Step 3: group messages
+	//Step 6: group messages (by destination)
 	go func() {
-		MakeAndRunAlertGrouping(alertCfg.Grouping, filteredNotifications, notifyChan)
+		MakeAndRunAlertGrouping(alertCfg.Grouping, severityOrder, routedNotifications, notifyChan)
 	}()
 }
@@ -1,26 +1,30 @@
 package alert
 
 import (
+	"slices"
 	"time"
 
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
-	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
 )
 
-const maximumNotificationThreshold int = 5
-const maximumNotificationThresholdWindow time.Duration = 30 * time.Minute
+// how often ended spams are checked (to send digests without waiting for a new message)
+const filterCheckInterval = 10 * time.Second
 
 type metricFilter struct {
-	metricId          string
-	lastNotifications utils.CircularBuffer[time.Time]
-	spamCount         int
+	notifications []time.Time // received within window (forwarded or not)
+	isSpamming    bool
+	suppressed    messageDigest
 }
 
 type alertFilters struct {
+	cfg     FilteringConfig
 	filters map[string]*metricFilter
-	input   <-chan metricIdWithMsg
-	output  chan<- notifier.Message
+
+	// global rate limit
+	notifications []time.Time // forwarded within window
+	isLimited     bool
+	suppressed    messageDigest
 }
 
 type metricIdWithMsg struct {
@@ -28,49 +32,107 @@ type metricIdWithMsg struct {
 	message  notifier.Message
 }
 
-func (mf *metricFilter) forwardToOutputIfAllowed(metricId string, msg notifier.Message, output chan<- notifier.Message) {
-	now := time.Now()
-	mf.lastNotifications.Push(now)
-	oldestEntry := mf.lastNotifications.Front()
+func pruneNotifications(notifications []time.Time, now time.Time, window time.Duration) []time.Time {
+	return slices.DeleteFunc(notifications, func(notification time.Time) bool {
+		return now.Sub(notification) > window
+	})
+}
+
+func makeAlertFilters(cfg FilteringConfig) *alertFilters {
+	return &alertFilters{
+		cfg:     cfg,
+		filters: map[string]*metricFilter{},
+	}
+}
 
-	if now.Sub(oldestEntry) <= maximumNotificationThresholdWindow && mf.lastNotifications.Full() {
-		if mf.spamCount > 0 {
-			logging.Debug("Metric %v: still spamming", mf.metricId)
-		} else {
-			output <- notifier.MakeMessage(notifier.Failure, "Notification spam detected (metricId: %v), skipping notifications", metricId)
-			logging.Warning("Metric %v: spam detected", mf.metricId)
+// process returns messages to forward: msg (unless suppressed) and spam notices
+func (af *alertFilters) process(metricId string, msg notifier.Message, now time.Time) []notifier.Message {
+	output := []notifier.Message{}
+	if af.cfg.MaxMessages > 0 {
+		if af.filters[metricId] == nil {
+			af.filters[metricId] = &metricFilter{}
+		}
+		mf := af.filters[metricId]
+		mf.notifications = append(pruneNotifications(mf.notifications, now, af.cfg.Window.AsDuration()), now)
+		if !mf.isSpamming && uint(len(mf.notifications)) > af.cfg.MaxMessages {
+			logging.Warning("Metric %v: spam detected", metricId)
+			mf.isSpamming = true
+			mf.suppressed = makeMessageDigest()
+			spamMsg := notifier.MakeMessage(notifier.Failure, "Notification spam detected (metricId: %v), skipping notifications", metricId)
+			spamMsg.Scraper, spamMsg.MetricID = msg.Scraper, msg.MetricID
+			output = append(output, spamMsg)
+		}
+		if mf.isSpamming {
+			logging.Debug("Filtering : %v", msg)
+			mf.suppressed.add(msg)
+			return output
 		}
-		mf.spamCount++
-	} else if mf.spamCount > 0 {
-		logging.Info("Metric %v: end of spam (%v message lost)", mf.metricId, mf.spamCount)
-		output <- notifier.MakeMessage(notifier.Recovery, "Notification spam has ended (metricId: %v, lost: %v)", metricId, mf.spamCount)
-		mf.spamCount = 0
 	}
 
-	if mf.spamCount == 0 {
-		logging.Debug("Forwarding : %v", msg)
-		output <- msg
-	} else {
-		logging.Debug("Filtering : %v", msg)
+	if af.cfg.GlobalMaxMessages > 0 {
+		af.notifications = pruneNotifications(af.notifications, now, af.cfg.Window.AsDuration())
+		if !af.isLimited && uint(len(af.notifications)) >= af.cfg.GlobalMaxMessages {
+			logging.Warning("Global notification rate limit reached")
+			af.isLimited = true
+			af.suppressed = makeMessageDigest()
+			output = append(output, notifier.MakeMessage(notifier.Failure, "Notification rate limit reached (%v messages within %v), skipping notifications", af.cfg.GlobalMaxMessages, af.cfg.Window))
+		}
+		if af.isLimited {
+			logging.Debug("Rate limiting : %v", msg)
+			af.suppressed.add(msg)
+			return output
+		}
+		af.notifications = append(af.notifications, now)
 	}
+
+	logging.Debug("Forwarding : %v", msg)
+	return append(output, msg)
 }
 
-func (af *alertFilters) forwardToOutputIfAllowed(metricId string, msg notifier.Message) {
-	if af.filters[metricId] == nil {
-		af.filters[metricId] = &metricFilter{
-			lastNotifications: utils.MakeCircularBuffer[time.Time](maximumNotificationThreshold),
+// update returns digests of ended spams (last message of each metric is never dropped)
+func (af *alertFilters) update(now time.Time) []notifier.Message {
+	output := []notifier.Message{}
+	for metricId, mf := range af.filters {
+		mf.notifications = pruneNotifications(mf.notifications, now, af.cfg.Window.AsDuration())
+		if len(mf.notifications) == 0 {
+			delete(af.filters, metricId)
+		}
+		if mf.isSpamming && uint(len(mf.notifications)) <= af.cfg.MaxMessages {
+			logging.Info("Metric %v: end of spam (%v message(s) suppressed)", metricId, mf.suppressed.count)
+			mf.isSpamming = false
+			mf.notifications = nil
+			output = append(output, mf.suppressed.message("Notification spam has ended (metricId: %v)", metricId))
 		}
 	}
-	af.filters[metricId].forwardToOutputIfAllowed(metricId, msg, af.output)
-}
 
-func MakeAndRunAlertFilters(input <-chan metricIdWithMsg, output chan<- notifier.Message) {
-	filtering := &alertFilters{
-		filters: make(map[string]*metricFilter),
-		input:   input,
-		output:  output,
+	af.notifications = pruneNotifications(af.notifications, now, af.cfg.Window.AsDuration())
+	if af.isLimited && uint(len(af.notifications)) < af.cfg.GlobalMaxMessages {
+		logging.Info("End of global notification rate limit (%v message(s) suppressed)", af.suppressed.count)
+		af.isLimited = false
+		if af.suppressed.count > 0 {
+			output = append(output, af.suppressed.message("Notification rate limit has ended"))
+		}
 	}
-	for inputMsg := range filtering.input {
-		filtering.forwardToOutputIfAllowed(inputMsg.metricId, inputMsg.message)
+	return output
+}
+
+func MakeAndRunAlertFilters(cfg FilteringConfig, input <-chan metricIdWithMsg, output chan<- notifier.Message) {
+	filtering := makeAlertFilters(cfg)
+	ticker := time.NewTicker(filterCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case inputMsg, ok := <-input:
+			if !ok {
+				return
+			}
+			for _, msg := range filtering.process(inputMsg.metricId, inputMsg.message, time.Now()) {
+				output <- msg
+			}
+		case now := <-ticker.C:
+			for _, msg := range filtering.update(now) {
+				output <- msg
+			}
+		}
 	}
 }
@@ -0,0 +1,69 @@
+package alert
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+func makeFilteredMessage(msgType notifier.MessageType, metricID, text string) notifier.Message {
+	msg := notifier.MakeMessage(msgType, "%v", text)
+	msg.MetricID = metricID
+	return msg
+}
+
+func TestAlertFilters_Spam(t *testing.T) {
+	filters := makeAlertFilters(FilteringConfig{MaxMessages: 3, Window: customtypes.Duration(30 * time.Minute)})
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	for index := range 3 {
+		output := filters.process("web", makeFilteredMessage(notifier.Failure, "web", "web failed"), start.Add(time.Duration(index)*time.Minute))
+		assert.Equal(t, 1, len(output))
+	}
+	// other metrics aren't affected
+	assert.Equal(t, 1, len(filters.process("db", makeFilteredMessage(notifier.Failure, "db", "db failed"), start.Add(3*time.Minute))))
+
+	output := filters.process("web", makeFilteredMessage(notifier.Recovery, "web", "web recovered"), start.Add(4*time.Minute))
+	assert.Equal(t, 1, len(output))
+	assert.Equal(t, "Notification spam detected (metricId: web), skipping notifications", output[0].Message)
+	assert.Equal(t, 0, len(filters.process("web", makeFilteredMessage(notifier.Failure, "web", "web failed"), start.Add(5*time.Minute))))
+	assert.Equal(t, 0, len(filters.process("web", makeFilteredMessage(notifier.Recovery, "web", "web recovered"), start.Add(6*time.Minute))))
+	assert.Equal(t, 0, len(filters.update(start.Add(20*time.Minute))))
+	assert.Equal(t, 0, len(filters.update(start.Add(32*time.Minute)))) // 4 messages within window
+
+	// final recovery is sent in the digest once spam has ended
+	output = filters.update(start.Add(34 * time.Minute))
+	assert.Equal(t, 1, len(output))
+	assert.Equal(t, notifier.Recovery, output[0].Type)
+	assert.Equal(t, "web", output[0].MetricID)
+	assert.Equal(t, "Notification spam has ended (metricId: web), 3 message(s) suppressed. Last status:\n - web recovered", output[0].Message)
+	assert.Equal(t, 0, len(filters.update(start.Add(35*time.Minute))))
+
+	assert.Equal(t, 1, len(filters.process("web", makeFilteredMessage(notifier.Failure, "web", "web failed"), start.Add(36*time.Minute))))
+}
+
+func TestAlertFilters_GlobalRateLimit(t *testing.T) {
+	filters := makeAlertFilters(FilteringConfig{MaxMessages: 5, Window: customtypes.Duration(10 * time.Minute), GlobalMaxMessages: 3})
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	for index := range 3 {
+		metricID := fmt.Sprintf("container_%v", index)
+		assert.Equal(t, 1, len(filters.process(metricID, makeFilteredMessage(notifier.Failure, metricID, metricID+" failed"), start)))
+	}
+	output := filters.process("container_3", makeFilteredMessage(notifier.Failure, "container_3", "container_3 failed"), start.Add(time.Minute))
+	assert.Equal(t, 1, len(output))
+	assert.Equal(t, "Notification rate limit reached (3 messages within 10m0s), skipping notifications", output[0].Message)
+	assert.Equal(t, 0, len(filters.process("container_4", makeFilteredMessage(notifier.Warning, "container_4", "container_4 warning"), start.Add(2*time.Minute))))
+	assert.Equal(t, 0, len(filters.process("container_3", makeFilteredMessage(notifier.Recovery, "container_3", "container_3 recovered"), start.Add(3*time.Minute))))
+	assert.Equal(t, 0, len(filters.update(start.Add(5*time.Minute))))
+
+	output = filters.update(start.Add(11 * time.Minute))
+	assert.Equal(t, 1, len(output))
+	assert.Equal(t, notifier.Warning, output[0].Type)
+	assert.Equal(t, "Notification rate limit has ended, 3 message(s) suppressed. Last status:\n - container_3 recovered\n - container_4 warning", output[0].Message)
+	assert.Equal(t, 1, len(filters.process("container_5", makeFilteredMessage(notifier.Failure, "container_5", "container_5 failed"), start.Add(12*time.Minute))))
+}
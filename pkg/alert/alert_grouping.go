@@ -1,41 +1,140 @@
 package alert
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
 )
 
-const maxAggregatedMessages int = 10
+var ErrInvalidGrouping = errors.New("invalid grouping")
 
-func MakeAndRunAlertGrouping(cfg GroupingConfig, input <-chan notifier.Message, output chan<- notifier.Message) {
-	currentGroup := make([]notifier.Message, 0, maxAggregatedMessages)
-	nextDeadline := time.Now().Add(cfg.Window.AsDuration())
+const (
+	groupByScraper      = "scraper"
+	groupByType         = "type"
+	groupByMetricPrefix = "metric_prefix" // metric ID without its last '_' separated part (ie. <scraper>_container_image_update)
+	groupBySeverity     = "severity"
+)
+
+var groupByKeys = []string{groupByScraper, groupByType, groupByMetricPrefix, groupBySeverity}
+
+type messageGroup struct {
+	messages []notifier.Message
+	deadline time.Time // zero when there is no pending message
+	lastSent time.Time
+}
+
+func checkGroupBy(groupBy []string) error {
+	for _, key := range groupBy {
+		if !slices.Contains(groupByKeys, key) {
+			return fmt.Errorf("%w: unknown group_by key %v (expected one of %v)", ErrInvalidGrouping, key, groupByKeys)
+		}
+	}
+	return nil
+}
+
+// parseSeverityOrder parses message type names, unlisted types are sorted last
+func parseSeverityOrder(names []string) ([]notifier.MessageType, error) {
+	order := []notifier.MessageType{}
+	for _, name := range names {
+		type_, ok := notifier.ParseMessageType(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown severity_order type %v", ErrInvalidGrouping, name)
+		}
+		if slices.Contains(order, type_) {
+			return nil, fmt.Errorf("%w: duplicated severity_order type %v", ErrInvalidGrouping, name)
+		}
+		order = append(order, type_)
+	}
+	return order, nil
+}
+
+func metricPrefix(metricID string) string {
+	if index := strings.LastIndex(metricID, "_"); index >= 0 {
+		return metricID[:index]
+	}
+	return metricID
+}
+
+// Messages are always grouped by destination (routed notifiers), then by group_by keys
+func groupKey(groupBy []string, msg notifier.Message) string {
+	parts := []string{strings.Join(msg.Notifiers, ",")}
+	for _, key := range groupBy {
+		switch key {
+		case groupByScraper:
+			parts = append(parts, msg.Scraper)
+		case groupByType:
+			parts = append(parts, msg.Type.String())
+		case groupByMetricPrefix:
+			parts = append(parts, metricPrefix(msg.MetricID))
+		case groupBySeverity:
+			parts = append(parts, msg.Severity.String())
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// The first message of a group is sent after group_wait, following ones are batched every group_interval.
+// A group is sent as soon as max_group_size messages are pending. Grouped messages are sorted by severityOrder.
+func MakeAndRunAlertGrouping(cfg GroupingConfig, severityOrder []notifier.MessageType, input <-chan notifier.Message, output chan<- notifier.Message) {
+	groups := map[string]*messageGroup{}
+	groupWait := cfg.GroupWait.AsDuration()
+	if cfg.Window != nil {
+		groupWait = cfg.Window.AsDuration()
+	}
 
-	sendAndFlush := func() {
-		logging.Info("Sending a grouped message of size %v", len(currentGroup))
-		output <- notifier.MakeAggregatedMessage(currentGroup)
-		currentGroup = currentGroup[:0]
+	sendAndFlush := func(key string, now time.Time) {
+		group := groups[key]
+		logging.Info("Sending a grouped message of size %v", len(group.messages))
+		output <- notifier.MakeAggregatedMessage(group.messages, severityOrder)
+		group.messages = nil
+		group.deadline = time.Time{}
+		group.lastSent = now
+	}
+
+	nextDeadline := func() <-chan time.Time {
+		var earliest time.Time
+		for _, group := range groups {
+			if !group.deadline.IsZero() && (earliest.IsZero() || group.deadline.Before(earliest)) {
+				earliest = group.deadline
+			}
+		}
+		if earliest.IsZero() {
+			return nil // wait for input only
+		}
+		return time.After(time.Until(earliest))
 	}
 
 	for {
 		select {
 		case msg := <-input:
-			if len(currentGroup) == 0 {
-				nextDeadline = time.Now().Add(cfg.Window.AsDuration())
+			now := time.Now()
+			key := groupKey(cfg.GroupBy, msg)
+			group := groups[key]
+			if group == nil || (group.deadline.IsZero() && !now.Before(group.lastSent.Add(cfg.GroupInterval.AsDuration()))) {
+				// new (or idle) group
+				group = &messageGroup{deadline: now.Add(groupWait)}
+				groups[key] = group
+			} else if group.deadline.IsZero() {
+				group.deadline = group.lastSent.Add(cfg.GroupInterval.AsDuration())
 			}
-			currentGroup = append(currentGroup, msg)
-		case <-time.After(time.Until(nextDeadline)):
-			if len(currentGroup) > 0 {
-				sendAndFlush()
-			} else {
-				nextDeadline = time.Now().Add(cfg.Window.AsDuration())
+			group.messages = append(group.messages, msg)
+			if cfg.MaxGroupSize > 0 && uint(len(group.messages)) >= cfg.MaxGroupSize {
+				sendAndFlush(key, now)
+			}
+		case <-nextDeadline():
+			now := time.Now()
+			for key, group := range groups {
+				if !group.deadline.IsZero() && !group.deadline.After(now) {
+					sendAndFlush(key, now)
+				} else if group.deadline.IsZero() && !now.Before(group.lastSent.Add(cfg.GroupInterval.AsDuration())) {
+					delete(groups, key)
+				}
 			}
-		}
-
-		if len(currentGroup) >= maxAggregatedMessages {
-			sendAndFlush()
 		}
 	}
 }
@@ -0,0 +1,121 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+func TestAlertGroupingByDestination(t *testing.T) {
+	input := make(chan notifier.Message)
+	output := make(chan notifier.Message, 10)
+	severityOrder, err := parseSeverityOrder([]string{"warning", "failure"})
+	assert.NilError(t, err)
+	go MakeAndRunAlertGrouping(GroupingConfig{GroupWait: customtypes.Duration(50 * time.Millisecond), MaxGroupSize: 10}, severityOrder, input, output)
+
+	send := func(msgType notifier.MessageType, text string, notifiers ...string) {
+		msg := notifier.MakeMessage(msgType, "%v", text)
+		msg.Notifiers = notifiers
+		input <- msg
+	}
+	send(notifier.Failure, "disk full", "chat", "pager")
+	send(notifier.Notification, "image updated", "chat")
+	send(notifier.Warning, "disk almost full", "chat", "pager")
+
+	received := map[string]notifier.Message{}
+	for range 2 {
+		select {
+		case msg := <-output:
+			received[msg.Message] = msg
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for grouped messages")
+		}
+	}
+	// sorted by configured severity order
+	grouped := received[" - disk almost full\n - disk full\n"]
+	assert.DeepEqual(t, []string{"chat", "pager"}, grouped.Notifiers)
+	assert.Equal(t, notifier.SeverityCritical, grouped.Severity)
+	assert.DeepEqual(t, []string{"chat"}, received[" - image updated\n"].Notifiers)
+}
+
+func TestAlertGroupingKeys(t *testing.T) {
+	failure := notifier.MakeMessage(notifier.Failure, "web state failed")
+	failure.Scraper, failure.MetricID, failure.Notifiers = "docker", "docker_container_state_1", []string{"chat"}
+	update := notifier.MakeMessage(notifier.Notification, "web image updated")
+	update.Scraper, update.MetricID, update.Notifiers = "docker", "docker_container_image_update_1", []string{"chat"}
+	otherUpdate := notifier.MakeMessage(notifier.Notification, "db image updated")
+	otherUpdate.Scraper, otherUpdate.MetricID, otherUpdate.Notifiers = "docker", "docker_container_image_update_2", []string{"chat"}
+
+	assert.Equal(t, groupKey(nil, failure), groupKey(nil, update))
+	assert.Equal(t, groupKey([]string{groupByScraper}, failure), groupKey([]string{groupByScraper}, update))
+	assert.Assert(t, groupKey([]string{groupByType}, failure) != groupKey([]string{groupByType}, update))
+	assert.Assert(t, groupKey([]string{groupBySeverity}, failure) != groupKey([]string{groupBySeverity}, update))
+	assert.Assert(t, groupKey([]string{groupByMetricPrefix}, failure) != groupKey([]string{groupByMetricPrefix}, update))
+	assert.Equal(t, groupKey([]string{groupByMetricPrefix}, update), groupKey([]string{groupByMetricPrefix}, otherUpdate))
+
+	assert.NilError(t, checkGroupBy([]string{groupByScraper, groupByType}))
+	assert.ErrorIs(t, checkGroupBy([]string{"host"}), ErrInvalidGrouping)
+
+	_, err := parseSeverityOrder([]string{"failure", "critical"})
+	assert.ErrorIs(t, err, ErrInvalidGrouping)
+	_, err = parseSeverityOrder([]string{"failure", "failure"})
+	assert.ErrorIs(t, err, ErrInvalidGrouping)
+}
+
+func TestAlertGroupingIntervalAndSize(t *testing.T) {
+	input := make(chan notifier.Message)
+	output := make(chan notifier.Message, 10)
+	go MakeAndRunAlertGrouping(GroupingConfig{
+		GroupWait:     customtypes.Duration(50 * time.Millisecond),
+		GroupInterval: customtypes.Duration(300 * time.Millisecond),
+		MaxGroupSize:  3,
+		GroupBy:       []string{groupByType},
+	}, nil, input, output)
+
+	send := func(msgType notifier.MessageType, text string) {
+		input <- notifier.MakeMessage(msgType, "%v", text)
+	}
+	receive := func(timeout time.Duration) *notifier.Message {
+		select {
+		case msg := <-output:
+			return &msg
+		case <-time.After(timeout):
+			return nil
+		}
+	}
+
+	// sorted by severity, image updates in their own group
+	send(notifier.Recovery, "ping recovered")
+	send(notifier.Notification, "image updated")
+	send(notifier.Failure, "disk full")
+	send(notifier.Warning, "disk almost full")
+	received := map[string]string{}
+	for range 4 {
+		msg := receive(time.Second)
+		assert.Assert(t, msg != nil)
+		received[msg.Title] = msg.Message
+	}
+	assert.DeepEqual(t, map[string]string{
+		"recovery: 1":     " - ping recovered\n",
+		"notification: 1": " - image updated\n",
+		"failure: 1":      " - disk full\n",
+		"warning: 1":      " - disk almost full\n",
+	}, received)
+
+	// following failures wait for group_interval, unless max_group_size is reached
+	send(notifier.Failure, "disk still full")
+	assert.Assert(t, receive(150*time.Millisecond) == nil)
+	msg := receive(time.Second)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, " - disk still full\n", msg.Message)
+
+	send(notifier.Failure, "a")
+	send(notifier.Failure, "b")
+	send(notifier.Failure, "c")
+	msg = receive(100 * time.Millisecond)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, " - a\n - b\n - c\n", msg.Message)
+}
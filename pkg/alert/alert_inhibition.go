@@ -0,0 +1,160 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+)
+
+var ErrInvalidDependency = errors.New("invalid dependency")
+
+// Dependents of a dependency are inhibited while any parent is failing.
+// A metric is a dependent when it matches any of scrapers, metric_ids or tags.
+type Dependency struct {
+	Parents   []string `json:"parents"`                 // glob patterns on parent metric ID
+	Scrapers  []string `json:"scrapers" default:"[]"`   // glob patterns on dependent scraper name
+	MetricIDs []string `json:"metric_ids" default:"[]"` // glob patterns on dependent metric ID
+	Tags      []string `json:"tags" default:"[]"`       // dependent scraper tags
+}
+
+type compiledDependency struct {
+	parents   []*regexp.Regexp
+	scrapers  []*regexp.Regexp
+	metricIDs []*regexp.Regexp
+	tags      []string
+}
+
+// Inhibitor suppresses messages of dependent metrics while a parent is failing.
+// The last suppressed message of each dependent is sent once released if the dependent is still failing,
+// or if it's a recovery (or removal) of a failure notified before inhibition.
+// A failure and its recovery both suppressed are dropped.
+type Inhibitor struct {
+	dependencies []compiledDependency
+	scraperTags  map[string][]string
+	inhibited    map[string]notifier.Message
+	notified     map[string]bool // metrics whose failure (or warning) was delivered and not yet recovered
+}
+
+func compileDependency(dependency Dependency, scraperTags map[string][]string) (compiledDependency, error) {
+	compiled := compiledDependency{
+		tags: dependency.Tags,
+	}
+	if len(dependency.Parents) == 0 {
+		return compiled, fmt.Errorf("%w: no parent", ErrInvalidDependency)
+	}
+	if len(dependency.Scrapers) == 0 && len(dependency.MetricIDs) == 0 && len(dependency.Tags) == 0 {
+		return compiled, fmt.Errorf("%w: no dependent (scrapers, metric_ids or tags)", ErrInvalidDependency)
+	}
+	for _, tag := range dependency.Tags {
+		isKnown := false
+		for _, tags := range scraperTags {
+			isKnown = isKnown || slices.Contains(tags, tag)
+		}
+		if !isKnown {
+			return compiled, fmt.Errorf("%w: no scraper tagged %v", ErrInvalidDependency, tag)
+		}
+	}
+	var err error
+	if compiled.parents, err = utils.CompileGlobList(dependency.Parents); err != nil {
+		return compiled, fmt.Errorf("%w: %v", ErrInvalidDependency, err)
+	}
+	if compiled.scrapers, err = utils.CompileGlobList(dependency.Scrapers); err != nil {
+		return compiled, fmt.Errorf("%w: %v", ErrInvalidDependency, err)
+	}
+	if compiled.metricIDs, err = utils.CompileGlobList(dependency.MetricIDs); err != nil {
+		return compiled, fmt.Errorf("%w: %v", ErrInvalidDependency, err)
+	}
+	return compiled, nil
+}
+
+// scraperTags maps scraper names to their tags (see provider.Config)
+func MakeInhibitor(dependencies []Dependency, scraperTags map[string][]string) (*Inhibitor, error) {
+	inhibitor := &Inhibitor{
+		scraperTags: scraperTags,
+		inhibited:   map[string]notifier.Message{},
+		notified:    map[string]bool{},
+	}
+	for index, dependency := range dependencies {
+		compiled, err := compileDependency(dependency, scraperTags)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %v: %w", index, err)
+		}
+		inhibitor.dependencies = append(inhibitor.dependencies, compiled)
+	}
+	return inhibitor, nil
+}
+
+func (dependency *compiledDependency) isDependent(scraper, metricID string, tags []string) bool {
+	return (len(dependency.scrapers) > 0 && utils.MatchGlobList(dependency.scrapers, scraper)) ||
+		(len(dependency.metricIDs) > 0 && utils.MatchGlobList(dependency.metricIDs, metricID)) ||
+		slices.ContainsFunc(dependency.tags, func(tag string) bool { return slices.Contains(tags, tag) })
+}
+
+// failingParent returns a failing parent of a metric (a metric never inhibits itself)
+func (inhibitor *Inhibitor) failingParent(scraper, metricID string, metricStateMachines map[string]*MetricStateMachine) (string, bool) {
+	for _, dependency := range inhibitor.dependencies {
+		if !dependency.isDependent(scraper, metricID, inhibitor.scraperTags[scraper]) {
+			continue
+		}
+		for parentID, stateMachine := range metricStateMachines {
+			if parentID != metricID && stateMachine.currentStatus() == provider.Unhealthy && utils.MatchGlobList(dependency.parents, parentID) {
+				return parentID, true
+			}
+		}
+	}
+	return "", false
+}
+
+// delivered tracks failures notified to the user
+func (inhibitor *Inhibitor) delivered(msg notifier.Message) {
+	switch msg.Type {
+	case notifier.Failure, notifier.Warning:
+		inhibitor.notified[msg.MetricID] = true
+	case notifier.Recovery:
+		delete(inhibitor.notified, msg.MetricID)
+	}
+}
+
+// Inhibit reports whether a message must be suppressed
+func (inhibitor *Inhibitor) Inhibit(msg notifier.Message, metricStateMachines map[string]*MetricStateMachine) bool {
+	if msg.MetricID == "" {
+		return false
+	}
+	parentID, isInhibited := inhibitor.failingParent(msg.Scraper, msg.MetricID, metricStateMachines)
+	if isInhibited {
+		logging.Debug("Message of %v inhibited by %v: %v", msg.MetricID, parentID, msg.Message)
+		inhibitor.inhibited[msg.MetricID] = msg
+	} else {
+		inhibitor.delivered(msg)
+	}
+	return isInhibited
+}
+
+// Release returns the last suppressed message of dependents no longer inhibited, when still failing or
+// when recovering from a notified failure
+func (inhibitor *Inhibitor) Release(metricStateMachines map[string]*MetricStateMachine) []notifier.Message {
+	messages := []notifier.Message{}
+	for metricID, msg := range inhibitor.inhibited {
+		if _, isInhibited := inhibitor.failingParent(msg.Scraper, metricID, metricStateMachines); isInhibited {
+			continue
+		}
+		delete(inhibitor.inhibited, metricID)
+		stateMachine, exists := metricStateMachines[metricID]
+		isFailing := exists && stateMachine.currentStatus() != provider.Healthy
+		if isFailing || (msg.Type == notifier.Recovery && inhibitor.notified[metricID]) {
+			inhibitor.delivered(msg)
+			messages = append(messages, msg)
+		}
+	}
+	slices.SortFunc(messages, func(a, b notifier.Message) int {
+		return strings.Compare(a.MetricID, b.MetricID)
+	})
+	return messages
+}
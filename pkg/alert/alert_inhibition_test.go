@@ -0,0 +1,92 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+func TestInhibitor(t *testing.T) {
+	inhibitor, err := MakeInhibitor([]Dependency{
+		{Parents: []string{"ping_nas"}, Tags: []string{"nas"}},
+		{Parents: []string{"docker_general_list_container"}, Scrapers: []string{"docker"}},
+	}, map[string][]string{"backup": {"nas"}, "ping": {}, "docker": {}})
+	assert.NilError(t, err)
+
+	metricStateMachines := map[string]*MetricStateMachine{}
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	update := func(state provider.MetricState) *notifier.Message {
+		if metricStateMachines[state.MetricID] == nil {
+			metricStateMachines[state.MetricID] = MakeMetricStateMachine(1, 1, time.Hour, 3, customtypes.TimeOfDay{Hour: 8})
+		}
+		msg := metricStateMachines[state.MetricID].Update(state, now)
+		if msg == nil {
+			return nil
+		}
+		msg.Scraper = state.Scraper
+		msg.MetricID = state.MetricID
+		if inhibitor.Inhibit(*msg, metricStateMachines) {
+			return nil
+		}
+		return msg
+	}
+
+	nasDown := provider.MetricState{Scraper: "ping", MetricID: "ping_nas", Name: "ping nas", Status: provider.Unhealthy}
+	nasUp := provider.MetricState{Scraper: "ping", MetricID: "ping_nas", Name: "ping nas", Status: provider.Healthy}
+	backupFailed := provider.MetricState{Scraper: "backup", MetricID: "backup_fileage_db", Name: "db backup", Status: provider.Unhealthy}
+	backupOK := provider.MetricState{Scraper: "backup", MetricID: "backup_fileage_db", Name: "db backup", Status: provider.Healthy}
+	listFailed := provider.MetricState{Scraper: "docker", MetricID: "docker_general_list_container", Name: "container provider", Status: provider.Unhealthy}
+	containerFailed := provider.MetricState{Scraper: "docker", MetricID: "docker_container_state_1", Name: "web state", Status: provider.Unhealthy}
+
+	// parent failing: dependents are inhibited, parent is not
+	assert.Assert(t, update(nasDown) != nil)
+	assert.Assert(t, update(backupFailed) == nil)
+	assert.Assert(t, update(listFailed) != nil)
+	assert.Assert(t, update(containerFailed) == nil)
+	assert.Equal(t, 0, len(inhibitor.Release(metricStateMachines)))
+
+	// dependent recovered while inhibited: nothing to send
+	assert.Assert(t, update(backupOK) == nil)
+	assert.Assert(t, update(nasUp) != nil)
+	assert.Equal(t, 0, len(inhibitor.Release(metricStateMachines)))
+
+	// dependent still failing once released: last suppressed message is sent
+	assert.Assert(t, update(nasDown) != nil)
+	assert.Assert(t, update(backupFailed) == nil)
+	assert.Assert(t, update(nasUp) != nil)
+	released := inhibitor.Release(metricStateMachines)
+	assert.Equal(t, 1, len(released))
+	assert.Equal(t, notifier.Failure, released[0].Type)
+	assert.Equal(t, "backup_fileage_db", released[0].MetricID)
+
+	// dependent failed (notified) -> parent fails -> both recover: recovery is sent once released
+	assert.Assert(t, update(nasDown) != nil)
+	assert.Assert(t, update(backupOK) == nil)
+	assert.Assert(t, update(nasUp) != nil)
+	released = inhibitor.Release(metricStateMachines)
+	assert.Equal(t, 1, len(released))
+	assert.Equal(t, notifier.Recovery, released[0].Type)
+	assert.Equal(t, "backup_fileage_db", released[0].MetricID)
+	assert.Equal(t, 0, len(inhibitor.Release(metricStateMachines)))
+
+	// failure and recovery both suppressed: dropped
+	assert.Assert(t, update(nasDown) != nil)
+	assert.Assert(t, update(backupFailed) == nil)
+	assert.Assert(t, update(backupOK) == nil)
+	assert.Assert(t, update(nasUp) != nil)
+	assert.Equal(t, 0, len(inhibitor.Release(metricStateMachines)))
+}
+
+func TestInhibitor_InvalidDependency(t *testing.T) {
+	scraperTags := map[string][]string{"backup": {"nas"}}
+	_, err := MakeInhibitor([]Dependency{{Tags: []string{"nas"}}}, scraperTags)
+	assert.ErrorIs(t, err, ErrInvalidDependency)
+	_, err = MakeInhibitor([]Dependency{{Parents: []string{"ping_nas"}}}, scraperTags)
+	assert.ErrorIs(t, err, ErrInvalidDependency)
+	_, err = MakeInhibitor([]Dependency{{Parents: []string{"ping_nas"}, Tags: []string{"unknown"}}}, scraperTags)
+	assert.ErrorIs(t, err, ErrInvalidDependency)
+}
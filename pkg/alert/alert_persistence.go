@@ -0,0 +1,159 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+)
+
+const metricStatesStorageKey = "metric_states"
+
+// restored states not updated within this delay are dropped (ie. scraper removed from configuration)
+const restoredStateExpiry = 24 * time.Hour
+
+type persistedMetricState struct {
+	MetricStateSnapshot
+	Scraper     string `json:"scraper"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (state persistedMetricState) equal(other persistedMetricState) bool {
+	return state.MetricStateSnapshot.Equal(other.MetricStateSnapshot) &&
+		state.Scraper == other.Scraper &&
+		state.Name == other.Name &&
+		state.Description == other.Description
+}
+
+type metricStatePersistence struct {
+	storage  storage.Storager
+	states   map[string]persistedMetricState
+	restored map[string]bool // restored but not updated yet
+	expiry   time.Time
+}
+
+func loadMetricStatePersistence(storage storage.Storager, now time.Time) *metricStatePersistence {
+	persistence := &metricStatePersistence{
+		storage:  storage,
+		states:   map[string]persistedMetricState{},
+		restored: map[string]bool{},
+		expiry:   now.Add(restoredStateExpiry),
+	}
+	if raw, exists := storage.Get(metricStatesStorageKey); exists {
+		if err := json.Unmarshal([]byte(raw), &persistence.states); err != nil {
+			logging.Warning("Unable to restore metric states: %v", err)
+			persistence.states = map[string]persistedMetricState{}
+		}
+	}
+	for metricID := range persistence.states {
+		persistence.restored[metricID] = true
+	}
+	return persistence
+}
+
+// restore state machines, created with makeStateMachine
+func (persistence *metricStatePersistence) restore(makeStateMachine func(scraper, metricID string) *MetricStateMachine) map[string]*MetricStateMachine {
+	metricStateMachines := map[string]*MetricStateMachine{}
+	for metricID, state := range persistence.states {
+		metricStateMachines[metricID] = makeStateMachine(state.Scraper, metricID)
+		metricStateMachines[metricID].Restore(state.MetricStateSnapshot)
+	}
+	if len(persistence.states) > 0 {
+		logging.Info("Restored %v metric state(s)", len(persistence.states))
+	}
+	return metricStateMachines
+}
+
+// summary of restored failures, nil if there is none
+func (persistence *metricStatePersistence) summary() *notifier.Message {
+	failures := []persistedMetricState{}
+	for _, state := range persistence.states {
+		if !state.IsHealthy {
+			failures = append(failures, state)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	slices.SortFunc(failures, func(a, b persistedMetricState) int {
+		if cmp := a.FailingSince.Compare(b.FailingSince); cmp != 0 {
+			return cmp
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	lines := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		state := "still failing"
+		if failure.Severity == provider.Warning {
+			state = "still in warning"
+		}
+		line := fmt.Sprintf(" - %v %v since %v", failure.Name, state, failure.FailingSince.Local().Format(time.DateTime))
+		if failure.Description != "" {
+			line += ": " + failure.Description
+		}
+		lines = append(lines, line)
+	}
+	msg := notifier.MakeMessage(notifier.Failure, "%v ongoing failure(s) restored:\n%v", len(failures), strings.Join(lines, "\n"))
+	return &msg
+}
+
+func (persistence *metricStatePersistence) update(metricState provider.MetricState, metricStateMachine *MetricStateMachine) {
+	delete(persistence.restored, metricState.MetricID)
+	if metricStateMachine.IsDefault() {
+		if _, exists := persistence.states[metricState.MetricID]; !exists {
+			return
+		}
+		delete(persistence.states, metricState.MetricID)
+	} else {
+		state := persistedMetricState{
+			MetricStateSnapshot: metricStateMachine.Snapshot(),
+			Scraper:             metricState.Scraper,
+			Name:                metricState.Name,
+			Description:         metricState.Description,
+		}
+		if previous, exists := persistence.states[metricState.MetricID]; exists && previous.equal(state) {
+			return // most updates don't change anything, don't rewrite every state
+		}
+		persistence.states[metricState.MetricID] = state
+	}
+	persistence.save()
+}
+
+// drop restored states that were never updated once expiry is reached (a recovery is returned for failures)
+func (persistence *metricStatePersistence) expire(now time.Time, metricStateMachines map[string]*MetricStateMachine) []metricIdWithMsg {
+	if len(persistence.restored) == 0 || now.Before(persistence.expiry) {
+		return nil
+	}
+	messages := []metricIdWithMsg{}
+	for metricID := range persistence.restored {
+		logging.Info("Dropping restored state of %v (no longer updated)", metricID)
+		if state := persistence.states[metricID]; !state.IsHealthy {
+			messages = append(messages, metricIdWithMsg{
+				metricId: metricID,
+				message:  *makeMessage(notifier.Recovery, "removed", state.Name, "no longer monitored"),
+			})
+		}
+		delete(persistence.states, metricID)
+		delete(metricStateMachines, metricID)
+	}
+	persistence.restored = map[string]bool{}
+	persistence.save()
+	return messages
+}
+
+func (persistence *metricStatePersistence) save() {
+	raw, err := json.Marshal(persistence.states)
+	if err != nil {
+		logging.Error("Unable to persist metric states: %v", err)
+		return
+	}
+	persistence.storage.Set(metricStatesStorageKey, string(raw))
+}
@@ -0,0 +1,130 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+type countingStorage struct {
+	storage.Storager
+	setCount int
+}
+
+func (s *countingStorage) Set(key, value string) bool {
+	s.setCount++
+	return s.Storager.Set(key, value)
+}
+
+func TestMetricStatePersistence_SaveOnChange(t *testing.T) {
+	countingStorage := &countingStorage{Storager: storage.NewMemoryStorage()}
+	stateMachine := MakeMetricStateMachine(1, 2, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0})
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	failure := provider.MetricState{MetricID: "disk_root", Name: "disk /", Status: provider.Unhealthy, Description: "95% used"}
+	healthy := provider.MetricState{MetricID: "disk_root", Name: "disk /", Status: provider.Healthy}
+
+	persistence := loadMetricStatePersistence(countingStorage, start)
+	for i := range 3 {
+		stateMachine.Update(healthy, start.Add(time.Duration(i)*time.Minute))
+		persistence.update(healthy, stateMachine)
+	}
+	assert.Equal(t, 0, countingStorage.setCount)
+
+	// Pending failure, then confirmed failure
+	stateMachine.Update(failure, start.Add(3*time.Minute))
+	persistence.update(failure, stateMachine)
+	stateMachine.Update(failure, start.Add(4*time.Minute))
+	persistence.update(failure, stateMachine)
+	assert.Equal(t, 2, countingStorage.setCount)
+
+	// Ongoing failure: nothing changes until description does
+	for i := range 3 {
+		stateMachine.Update(failure, start.Add(time.Duration(5+i)*time.Minute))
+		persistence.update(failure, stateMachine)
+	}
+	assert.Equal(t, 2, countingStorage.setCount)
+	failure.Description = "96% used"
+	stateMachine.Update(failure, start.Add(10*time.Minute))
+	persistence.update(failure, stateMachine)
+	assert.Equal(t, 3, countingStorage.setCount)
+}
+
+func TestMetricStatePersistence(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	makeStateMachine := func(scraper, metricID string) *MetricStateMachine {
+		return MakeMetricStateMachine(1, 1, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0})
+	}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	failure := provider.MetricState{MetricID: "disk_root", Name: "disk /", Status: provider.Unhealthy, Description: "95% used"}
+	healthy := provider.MetricState{MetricID: "ping_router", Name: "ping router", Status: provider.Healthy}
+
+	// First run: one failure, one healthy metric (not persisted)
+	persistence := loadMetricStatePersistence(memoryStorage, start)
+	metricStateMachines := persistence.restore(makeStateMachine)
+	assert.Assert(t, persistence.summary() == nil)
+	for _, state := range []provider.MetricState{failure, healthy} {
+		metricStateMachines[state.MetricID] = makeStateMachine(state.Scraper, state.MetricID)
+		metricStateMachines[state.MetricID].Update(state, start)
+		persistence.update(state, metricStateMachines[state.MetricID])
+	}
+	assert.Equal(t, 1, len(persistence.states))
+
+	// Restart: failure is restored, no new "failed" message, reminders continue
+	restart := start.Add(30 * time.Minute)
+	persistence = loadMetricStatePersistence(memoryStorage, restart)
+	metricStateMachines = persistence.restore(makeStateMachine)
+	assert.Equal(t, 1, len(metricStateMachines))
+	summary := persistence.summary()
+	assert.Assert(t, summary != nil)
+	assert.Equal(t, notifier.Failure, summary.Type)
+	assert.Equal(t, "1 ongoing failure(s) restored:\n - disk / still failing since 2024-01-01 10:00:00: 95% used", summary.Message)
+
+	assert.Assert(t, metricStateMachines["disk_root"].Update(failure, restart) == nil)
+	msg := metricStateMachines["disk_root"].Update(failure, start.Add(time.Hour))
+	assert.Assert(t, msg != nil)
+	assert.Assert(t, isContains(msg.Message, "reminder"))
+	persistence.update(failure, metricStateMachines["disk_root"])
+
+	// Recovered while down
+	persistence = loadMetricStatePersistence(memoryStorage, restart)
+	metricStateMachines = persistence.restore(makeStateMachine)
+	msg = metricStateMachines["disk_root"].Update(provider.MetricState{MetricID: "disk_root", Name: "disk /", Status: provider.Healthy}, restart)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Recovery, msg.Type)
+	persistence.update(failure, metricStateMachines["disk_root"])
+	_, exists := memoryStorage.Get(metricStatesStorageKey)
+	assert.Assert(t, exists)
+	assert.Equal(t, 0, len(loadMetricStatePersistence(memoryStorage, restart).states))
+}
+
+func TestMetricStatePersistence_Expiry(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	makeStateMachine := func(scraper, metricID string) *MetricStateMachine {
+		return MakeMetricStateMachine(1, 1, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0})
+	}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	failure := provider.MetricState{MetricID: "old_scraper_metric", Name: "old metric", Status: provider.Unhealthy}
+
+	persistence := loadMetricStatePersistence(memoryStorage, start)
+	metricStateMachines := persistence.restore(makeStateMachine)
+	metricStateMachines[failure.MetricID] = makeStateMachine(failure.Scraper, failure.MetricID)
+	metricStateMachines[failure.MetricID].Update(failure, start)
+	persistence.update(failure, metricStateMachines[failure.MetricID])
+
+	persistence = loadMetricStatePersistence(memoryStorage, start)
+	metricStateMachines = persistence.restore(makeStateMachine)
+	assert.Equal(t, 0, len(persistence.expire(start.Add(time.Hour), metricStateMachines)))
+	assert.Equal(t, 1, len(metricStateMachines))
+
+	messages := persistence.expire(start.Add(restoredStateExpiry), metricStateMachines)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, notifier.Recovery, messages[0].message.Type)
+	assert.Equal(t, "old metric removed: no longer monitored", messages[0].message.Message)
+	assert.Equal(t, 0, len(metricStateMachines))
+	assert.Equal(t, 0, len(loadMetricStatePersistence(memoryStorage, start).states))
+}
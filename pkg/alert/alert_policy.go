@@ -0,0 +1,89 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+)
+
+var ErrInvalidPolicy = errors.New("invalid alert policy")
+
+// Alert policy applied to metrics matching any glob pattern
+type PolicyOverride struct {
+	MetricIDs []string             `json:"metric_ids"` // glob patterns on metric ID
+	Policy    provider.AlertPolicy `json:"policy"`
+}
+
+type compiledPolicyOverride struct {
+	metricIDs []*regexp.Regexp
+	policy    provider.AlertPolicy
+}
+
+// Policies resolves thresholds and reminders of a metric: alert configuration, then scraper policy,
+// then matching overrides (in order, last one wins).
+type Policies struct {
+	global    Config
+	scrapers  map[string]provider.AlertPolicy
+	overrides []compiledPolicyOverride
+}
+
+// scraperPolicies maps scraper names to their alert policy (see provider.Config)
+func MakePolicies(cfg Config, scraperPolicies map[string]provider.AlertPolicy) (*Policies, error) {
+	policies := &Policies{
+		global:   cfg,
+		scrapers: scraperPolicies,
+	}
+	for index, override := range cfg.Overrides {
+		if len(override.MetricIDs) == 0 {
+			return nil, fmt.Errorf("override %v: %w: no metric ID", index, ErrInvalidPolicy)
+		}
+		metricIDs, err := utils.CompileGlobList(override.MetricIDs)
+		if err != nil {
+			return nil, fmt.Errorf("override %v: %w: %v", index, ErrInvalidPolicy, err)
+		}
+		policies.overrides = append(policies.overrides, compiledPolicyOverride{
+			metricIDs: metricIDs,
+			policy:    override.Policy,
+		})
+	}
+	return policies, nil
+}
+
+func applyPolicy(cfg Config, policy provider.AlertPolicy) Config {
+	if policy.UnhealthyThreshold != nil {
+		cfg.UnhealthyThreshold = *policy.UnhealthyThreshold
+	}
+	if policy.HealthyThreshold != nil {
+		cfg.HealthyThreshold = *policy.HealthyThreshold
+	}
+	if policy.FailureReminder != nil {
+		cfg.FailureReminder = *policy.FailureReminder
+	}
+	if policy.FailureReminderCount != nil {
+		cfg.FailureReminderCount = *policy.FailureReminderCount
+	}
+	if policy.DailyReminderTime != nil {
+		cfg.DailyReminderTime = *policy.DailyReminderTime
+	}
+	return cfg
+}
+
+// resolve returns the configuration applied to a metric
+func (policies *Policies) resolve(scraper, metricID string) Config {
+	cfg := applyPolicy(policies.global, policies.scrapers[scraper])
+	for _, override := range policies.overrides {
+		if utils.MatchGlobList(override.metricIDs, metricID) {
+			cfg = applyPolicy(cfg, override.policy)
+		}
+	}
+	return cfg
+}
+
+func (policies *Policies) makeStateMachine(scraper, metricID string) *MetricStateMachine {
+	cfg := policies.resolve(scraper, metricID)
+	return MakeMetricStateMachine(cfg.HealthyThreshold, cfg.UnhealthyThreshold, cfg.FailureReminder.AsDuration(), cfg.FailureReminderCount, cfg.DailyReminderTime).
+		WithFlapDetection(cfg.Flapping.Window.AsDuration(), cfg.Flapping.StartThreshold, cfg.Flapping.StopThreshold)
+}
@@ -0,0 +1,55 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/scraping/provider"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+func TestPolicies(t *testing.T) {
+	cfg, err := configmapper.MapOnStruct[Config](map[string]any{
+		"overrides": []any{
+			map[string]any{"metric_ids": []any{"*_filesystemusage_*"}, "policy": map[string]any{"unhealthy_threshold": uint64(1)}},
+			map[string]any{"metric_ids": []any{"ping_ping_vpn"}, "policy": map[string]any{"unhealthy_threshold": uint64(5), "failure_reminder": "30m"}},
+		},
+	})
+	assert.NilError(t, err)
+	scraperCfg, err := configmapper.MapOnStruct[provider.Config](map[string]any{
+		"type":  "ping",
+		"alert": map[string]any{"unhealthy_threshold": uint64(3), "daily_reminder_time": "09:30"},
+	})
+	assert.NilError(t, err)
+
+	policies, err := MakePolicies(cfg, map[string]provider.AlertPolicy{"ping": scraperCfg.Alert})
+	assert.NilError(t, err)
+
+	// global configuration
+	resolved := policies.resolve("disk", "disk_filesystemusage_/")
+	assert.Equal(t, uint(1), resolved.UnhealthyThreshold)
+	assert.Equal(t, 2*time.Hour, resolved.FailureReminder.AsDuration())
+
+	// scraper policy
+	resolved = policies.resolve("ping", "ping_ping_router")
+	assert.Equal(t, uint(3), resolved.UnhealthyThreshold)
+	assert.Equal(t, uint(1), resolved.HealthyThreshold)
+	assert.Equal(t, customtypes.TimeOfDay{Hour: 9, Minute: 30}, resolved.DailyReminderTime)
+
+	// metric override on top of scraper policy
+	resolved = policies.resolve("ping", "ping_ping_vpn")
+	assert.Equal(t, uint(5), resolved.UnhealthyThreshold)
+	assert.Equal(t, 30*time.Minute, resolved.FailureReminder.AsDuration())
+	assert.Equal(t, customtypes.TimeOfDay{Hour: 9, Minute: 30}, resolved.DailyReminderTime)
+
+	stateMachine := policies.makeStateMachine("ping", "ping_ping_router")
+	assert.Equal(t, uint(3), stateMachine.unhealthyThreshold)
+}
+
+func TestPolicies_InvalidOverride(t *testing.T) {
+	_, err := MakePolicies(Config{Overrides: []PolicyOverride{{Policy: provider.AlertPolicy{UnhealthyThreshold: utils.Ptr(uint(3))}}}}, nil)
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+}
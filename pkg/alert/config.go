@@ -5,7 +5,26 @@ import (
 )
 
 type GroupingConfig struct {
-	Window customtypes.Duration `json:"window" default:"15s"`
+	Window        *customtypes.Duration `json:"window"`                                                                        // deprecated, alias of group_wait
+	GroupWait     customtypes.Duration  `json:"group_wait" default:"15s"`                                                      // delay before sending the first message of a group
+	GroupInterval customtypes.Duration  `json:"group_interval" default:"15s"`                                                  // delay between following messages of a group
+	MaxGroupSize  uint                  `json:"max_group_size" default:"10"`                                                   // send immediately once reached (0 means unlimited)
+	GroupBy       []string              `json:"group_by" default:"[]"`                                                         // scraper, type, metric_prefix, severity (messages are always grouped by destination)
+	SeverityOrder []string              `json:"severity_order" default:"[failure, warning, flapping, notification, recovery]"` // sort order of grouped messages (by type)
+}
+
+// Notification spam filter: messages beyond limits are suppressed, a digest is sent once spam has ended
+type FilteringConfig struct {
+	MaxMessages       uint                 `json:"max_messages" default:"5"`        // per metric within window (0 disables)
+	Window            customtypes.Duration `json:"window" default:"30m"`            // also used by the global rate limit
+	GlobalMaxMessages uint                 `json:"global_max_messages" default:"0"` // across all metrics within window (0 disables)
+}
+
+// Flap detection, based on confirmed state changes (unhealthy/healthy thresholds reached) within window
+type FlappingConfig struct {
+	Window         customtypes.Duration `json:"window" default:"1h"`
+	StartThreshold uint                 `json:"start_threshold" default:"6"` // state changes to start flapping (0 disables flap detection)
+	StopThreshold  uint                 `json:"stop_threshold" default:"2"`  // flapping stops once state changes drop to this value
 }
 
 type Config struct {
@@ -15,4 +34,12 @@ type Config struct {
 	DailyReminderTime    customtypes.TimeOfDay `json:"daily_reminder_time" default:"08:00"`
 	FailureReminderCount uint                  `json:"failure_reminder_count" default:"3"`
 	Grouping             GroupingConfig        `json:"grouping" default:"{}"`
+	Filtering            FilteringConfig       `json:"filtering" default:"{}"`
+	Flapping             FlappingConfig        `json:"flapping" default:"{}"`
+	Silences             []Silence             `json:"silences" default:"[]"`
+	SilenceDir           string                `json:"silence_dir" default:""` // directory watched for runtime silences (one yaml/json file per silence)
+	Dependencies         []Dependency          `json:"dependencies" default:"[]"`
+	Overrides            []PolicyOverride      `json:"overrides" default:"[]"` // per metric ID thresholds and reminders (see also scraper alert policy)
+	Escalations          []EscalationPolicy    `json:"escalations" default:"[]"`
+	AckDir               string                `json:"ack_dir" default:""` // directory watched for escalation acknowledgements (files listing metric IDs)
 }
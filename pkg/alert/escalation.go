@@ -0,0 +1,294 @@
+package alert
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+var ErrInvalidEscalation = errors.New("invalid escalation")
+
+// how often unresolved failures are checked for escalation
+const escalationCheckInterval = 10 * time.Second
+
+type EscalationStep struct {
+	After     customtypes.Duration `json:"after" default:"0s"` // delay since the failure (0 for the first step)
+	Notifiers []string             `json:"notifiers"`
+}
+
+// Escalation chain of failures matching scrapers and metric_ids (empty means any)
+type EscalationPolicy struct {
+	Name      string           `json:"name"`
+	Scrapers  []string         `json:"scrapers" default:"[]"`   // glob patterns on scraper name
+	MetricIDs []string         `json:"metric_ids" default:"[]"` // glob patterns on metric ID
+	Steps     []EscalationStep `json:"steps"`
+}
+
+type compiledEscalationPolicy struct {
+	EscalationPolicy
+	scrapers  []*regexp.Regexp
+	metricIDs []*regexp.Regexp
+}
+
+type escalation struct {
+	policy       *compiledEscalationPolicy
+	since        time.Time
+	step         int // last reached step
+	acknowledged bool
+	lastMessage  notifier.Message
+}
+
+// Escalator sends unresolved failures to the notifiers of each escalation step once its delay is elapsed.
+// Failure, reminder, flapping and recovery messages of an escalated metric are sent to notifiers of all reached steps.
+// An escalation stops when the metric recovers (or is de-escalated to warning), and isn't escalated further once
+// acknowledged (ack file). It is paused while an active silence matches the metric.
+type Escalator struct {
+	mutex       sync.Mutex
+	policies    []*compiledEscalationPolicy
+	silencer    *Silencer
+	ackDir      string
+	clock       utils.Clock
+	escalations map[string]*escalation
+}
+
+func compileEscalationPolicy(policy EscalationPolicy, router *notifier.Router) (*compiledEscalationPolicy, error) {
+	if !utils.IsNameValid(policy.Name) {
+		return nil, fmt.Errorf("%w: forbidden characters in name '%v'", ErrInvalidEscalation, policy.Name)
+	}
+	if len(policy.Steps) == 0 {
+		return nil, fmt.Errorf("%w: %v: no step", ErrInvalidEscalation, policy.Name)
+	}
+	for index, step := range policy.Steps {
+		if len(step.Notifiers) == 0 {
+			return nil, fmt.Errorf("%w: %v: step %v: no notifier", ErrInvalidEscalation, policy.Name, index)
+		}
+		if err := router.CheckNotifiers(step.Notifiers); err != nil {
+			return nil, fmt.Errorf("%w: %v: step %v: %v", ErrInvalidEscalation, policy.Name, index, err)
+		}
+		if index == 0 && step.After != 0 {
+			return nil, fmt.Errorf("%w: %v: first step can't be delayed", ErrInvalidEscalation, policy.Name)
+		}
+		if index > 0 && step.After <= policy.Steps[index-1].After {
+			return nil, fmt.Errorf("%w: %v: step %v: delay must be greater than previous step", ErrInvalidEscalation, policy.Name, index)
+		}
+	}
+	compiled := &compiledEscalationPolicy{EscalationPolicy: policy}
+	var err error
+	if compiled.scrapers, err = utils.CompileGlobList(policy.Scrapers); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidEscalation, policy.Name, err)
+	}
+	if compiled.metricIDs, err = utils.CompileGlobList(policy.MetricIDs); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidEscalation, policy.Name, err)
+	}
+	return compiled, nil
+}
+
+// router is used to check notifier names, silencer (optional) to pause escalation of silenced failures
+func MakeEscalator(policies []EscalationPolicy, router *notifier.Router, silencer *Silencer, clock utils.Clock) (*Escalator, error) {
+	escalator := &Escalator{
+		silencer:    silencer,
+		clock:       clock,
+		escalations: map[string]*escalation{},
+	}
+	for _, policy := range policies {
+		compiled, err := compileEscalationPolicy(policy, router)
+		if err != nil {
+			return nil, err
+		}
+		escalator.policies = append(escalator.policies, compiled)
+	}
+	return escalator, nil
+}
+
+// WithAckDir watches ackDir for acknowledgements: each file lists metric IDs (one per line), and is removed once read
+func (escalator *Escalator) WithAckDir(ackDir string) *Escalator {
+	escalator.ackDir = ackDir
+	return escalator
+}
+
+func (escalator *Escalator) match(msg notifier.Message) *compiledEscalationPolicy {
+	for _, policy := range escalator.policies {
+		if utils.MatchGlobList(policy.scrapers, msg.Scraper) && utils.MatchGlobList(policy.metricIDs, msg.MetricID) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// notifiers of steps from first to last (included)
+func (esc *escalation) notifiers(first, last int) []string {
+	notifiers := []string{}
+	for _, step := range esc.policy.Steps[first : last+1] {
+		notifiers = append(notifiers, step.Notifiers...)
+	}
+	slices.Sort(notifiers)
+	return slices.Compact(notifiers)
+}
+
+func (escalator *Escalator) end(metricID string) {
+	if esc, exists := escalator.escalations[metricID]; exists {
+		logging.Info("Escalation %v ended for %v", esc.policy.Name, metricID)
+		delete(escalator.escalations, metricID)
+	}
+}
+
+// Process sets destination notifiers of escalated messages (other messages are left unchanged)
+func (escalator *Escalator) Process(msg notifier.Message) notifier.Message {
+	escalator.mutex.Lock()
+	defer escalator.mutex.Unlock()
+	// digests (ie. end of spam) summarizing several metrics are routed, but recoveries end escalations
+	for _, metricID := range msg.Recovered {
+		if metricID != msg.MetricID {
+			escalator.end(metricID)
+		}
+	}
+	if msg.MetricID == "" {
+		return msg
+	}
+	esc := escalator.escalations[msg.MetricID]
+	switch {
+	case esc == nil && msg.Type == notifier.Failure:
+		policy := escalator.match(msg)
+		if policy == nil {
+			return msg
+		}
+		logging.Info("Escalation %v started for %v", policy.Name, msg.MetricID)
+		esc = &escalation{
+			policy: policy,
+			since:  escalator.clock.Now(),
+		}
+		escalator.escalations[msg.MetricID] = esc
+	case esc == nil:
+		return msg
+	case msg.Type == notifier.Recovery || msg.Type == notifier.Warning:
+		// escalations start on failures: a warning is a de-escalation (flapping notices have their own type)
+		escalator.end(msg.MetricID)
+	case msg.Type != notifier.Failure && msg.Type != notifier.Flapping:
+		return msg
+	}
+	if msg.Type == notifier.Failure {
+		esc.lastMessage = msg
+	}
+	msg.Notifiers = esc.notifiers(0, esc.step)
+	return msg
+}
+
+// Acknowledge stops escalating an unresolved failure, false if the metric isn't escalated (see WithAckDir)
+func (escalator *Escalator) Acknowledge(metricID string) bool {
+	escalator.mutex.Lock()
+	defer escalator.mutex.Unlock()
+	esc, exists := escalator.escalations[metricID]
+	if exists {
+		logging.Info("Escalation %v acknowledged for %v", esc.policy.Name, metricID)
+		esc.acknowledged = true
+	}
+	return exists
+}
+
+// Update returns escalated messages of failures whose next step is due
+func (escalator *Escalator) Update() []notifier.Message {
+	escalator.mutex.Lock()
+	defer escalator.mutex.Unlock()
+	now := escalator.clock.Now()
+	messages := []notifier.Message{}
+	for _, metricID := range slices.Sorted(maps.Keys(escalator.escalations)) {
+		esc := escalator.escalations[metricID]
+		if esc.acknowledged {
+			continue
+		}
+		if escalator.silencer != nil && escalator.silencer.Matches(esc.lastMessage, now) {
+			// paused while silenced: steps reached meanwhile are sent once the silence ends
+			continue
+		}
+		previousStep := esc.step
+		for esc.step+1 < len(esc.policy.Steps) && !now.Before(esc.since.Add(esc.policy.Steps[esc.step+1].After.AsDuration())) {
+			esc.step++
+		}
+		if esc.step == previousStep {
+			continue
+		}
+		reached := esc.notifiers(0, previousStep)
+		newNotifiers := slices.DeleteFunc(esc.notifiers(previousStep+1, esc.step), func(name string) bool {
+			return slices.Contains(reached, name)
+		})
+		if len(newNotifiers) == 0 {
+			continue
+		}
+		logging.Info("Escalation %v: %v escalated to step %v", esc.policy.Name, metricID, esc.step)
+		msg := notifier.MakeMessage(notifier.Failure, "%v (escalated after %v)", esc.lastMessage.Message, esc.policy.Steps[esc.step].After)
+		msg.Scraper, msg.MetricID, msg.Notifiers = esc.lastMessage.Scraper, metricID, newNotifiers
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// loadAckDir acknowledges metric IDs listed in files of the ack directory, then removes files
+func (escalator *Escalator) loadAckDir() {
+	if escalator.ackDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(escalator.ackDir)
+	if err != nil {
+		logging.Warning("Unable to read ack directory: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(escalator.ackDir, entry.Name())
+		if err := escalator.loadAckFile(path); err != nil {
+			logging.Error("Unable to load acknowledgement %v: %v", entry.Name(), err)
+		}
+		if err := os.Remove(path); err != nil {
+			logging.Error("Unable to remove %v: %v", path, err)
+		}
+	}
+}
+
+func (escalator *Escalator) loadAckFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer utils.SafeClose(file)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if metricID := strings.TrimSpace(scanner.Text()); metricID != "" && !escalator.Acknowledge(metricID) {
+			logging.Warning("Unable to acknowledge %v: not escalated", metricID)
+		}
+	}
+	return scanner.Err()
+}
+
+func MakeAndRunEscalation(escalator *Escalator, input <-chan notifier.Message, output chan<- notifier.Message) {
+	ticker := escalator.clock.NewTicker(escalationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-input:
+			if !ok {
+				return
+			}
+			output <- escalator.Process(msg)
+		case <-ticker.C():
+			escalator.loadAckDir()
+			for _, msg := range escalator.Update() {
+				output <- msg
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+type fakeClock struct {
+	now   time.Time
+	ticks chan time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) NewTicker(interval time.Duration) utils.Ticker {
+	return clock
+}
+
+func (clock *fakeClock) C() <-chan time.Time {
+	return clock.ticks
+}
+
+func (clock *fakeClock) Stop() {
+}
+
+func makeTestEscalator(t *testing.T, silencer *Silencer, clock utils.Clock) *Escalator {
+	router, err := notifier.MakeRouter(notifier.RoutesConfig{}, []string{"chat", "sms", "phone"})
+	assert.NilError(t, err)
+	escalator, err := MakeEscalator([]EscalationPolicy{
+		{
+			Name:     "oncall",
+			Scrapers: []string{"web"},
+			Steps: []EscalationStep{
+				{Notifiers: []string{"chat"}},
+				{After: customtypes.Duration(15 * time.Minute), Notifiers: []string{"sms"}},
+				{After: customtypes.Duration(time.Hour), Notifiers: []string{"phone", "sms"}},
+			},
+		},
+	}, router, silencer, clock)
+	assert.NilError(t, err)
+	return escalator
+}
+
+func TestEscalation(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)}
+	escalator := makeTestEscalator(t, nil, clock)
+
+	// not escalated: routed later
+	assert.Assert(t, escalator.Process(makeSilencedMessage(notifier.Failure, "disk", "disk_filesystemusage_/", "disk full")).Notifiers == nil)
+	assert.Assert(t, escalator.Process(makeSilencedMessage(notifier.Notification, "web", "web_image", "image updated")).Notifiers == nil)
+
+	msg := escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"))
+	assert.DeepEqual(t, []string{"chat"}, msg.Notifiers)
+
+	clock.now = clock.now.Add(14 * time.Minute)
+	assert.Equal(t, 0, len(escalator.Update()))
+
+	clock.now = clock.now.Add(time.Minute)
+	messages := escalator.Update()
+	assert.Equal(t, 1, len(messages))
+	assert.DeepEqual(t, []string{"sms"}, messages[0].Notifiers)
+	assert.Equal(t, notifier.Failure, messages[0].Type)
+	assert.Equal(t, "web_http", messages[0].MetricID)
+	assert.Equal(t, "http failed (escalated after 15m0s)", messages[0].Message)
+	assert.Equal(t, 0, len(escalator.Update()))
+
+	// reminders are sent to reached steps
+	msg = escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed (reminder)"))
+	assert.DeepEqual(t, []string{"chat", "sms"}, msg.Notifiers)
+
+	clock.now = clock.now.Add(time.Hour)
+	messages = escalator.Update()
+	assert.Equal(t, 1, len(messages))
+	assert.DeepEqual(t, []string{"phone"}, messages[0].Notifiers)
+	assert.Equal(t, "http failed (reminder) (escalated after 1h0m0s)", messages[0].Message)
+
+	// flapping notice isn't a severity change: still escalated
+	msg = escalator.Process(makeSilencedMessage(notifier.Flapping, "web", "web_http", "http flapping"))
+	assert.DeepEqual(t, []string{"chat", "phone", "sms"}, msg.Notifiers)
+	assert.Equal(t, 1, len(escalator.escalations))
+
+	// recovery is sent to reached steps, escalation ends
+	msg = escalator.Process(makeSilencedMessage(notifier.Recovery, "web", "web_http", "http recovered"))
+	assert.DeepEqual(t, []string{"chat", "phone", "sms"}, msg.Notifiers)
+	assert.Assert(t, escalator.Process(makeSilencedMessage(notifier.Recovery, "web", "web_http", "http recovered")).Notifiers == nil)
+	assert.Equal(t, 0, len(escalator.escalations))
+
+	// de-escalation to warning ends escalation
+	escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"))
+	msg = escalator.Process(makeSilencedMessage(notifier.Warning, "web", "web_http", "http slow"))
+	assert.DeepEqual(t, []string{"chat"}, msg.Notifiers)
+	assert.Equal(t, 0, len(escalator.escalations))
+}
+
+func TestEscalation_Digest(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)}
+	escalator := makeTestEscalator(t, nil, clock)
+
+	escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"))
+	escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_db", "db failed"))
+
+	// spam digest of several metrics: recovered ones end their escalation, the digest is routed
+	digest := makeMessageDigest()
+	digest.add(makeSilencedMessage(notifier.Recovery, "web", "web_http", "http recovered"))
+	digest.add(makeSilencedMessage(notifier.Failure, "web", "web_db", "db failed"))
+	msg := escalator.Process(digest.message("Notification rate limit has ended"))
+	assert.DeepEqual(t, []string{"web_http"}, msg.Recovered)
+	assert.Assert(t, msg.Notifiers == nil)
+
+	clock.now = clock.now.Add(15 * time.Minute)
+	messages := escalator.Update()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "web_db", messages[0].MetricID)
+}
+
+func TestEscalation_Acknowledge(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	silencer, err := MakeSilencer(nil, "", storage.NewMemoryStorage())
+	assert.NilError(t, err)
+	escalator := makeTestEscalator(t, silencer, clock)
+
+	escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"))
+	escalator.Process(makeSilencedMessage(notifier.Failure, "web", "web_db", "db failed"))
+	assert.Assert(t, escalator.Acknowledge("web_http"))
+	assert.Assert(t, !escalator.Acknowledge("web_unknown"))
+
+	// an active silence pauses the escalation
+	assert.NilError(t, silencer.Add(Silence{Name: "maintenance", MetricIDs: []string{"web_db"}, End: utils.Ptr(customtypes.DateTime(clock.now.Add(3 * time.Hour)))}))
+	clock.now = clock.now.Add(2 * time.Hour)
+	assert.Equal(t, 0, len(escalator.Update()))
+
+	// silence ended: escalation resumes with steps reached meanwhile, acknowledged failure stays quiet
+	clock.now = clock.now.Add(90 * time.Minute)
+	messages := escalator.Update()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "web_db", messages[0].MetricID)
+	assert.DeepEqual(t, []string{"phone", "sms"}, messages[0].Notifiers)
+	assert.Equal(t, "db failed (escalated after 1h0m0s)", messages[0].Message)
+	assert.Equal(t, 0, len(escalator.Update()))
+}
+
+func TestEscalation_AckDir(t *testing.T) {
+	ackDir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local), ticks: make(chan time.Time)}
+	escalator := makeTestEscalator(t, nil, clock).WithAckDir(ackDir)
+
+	input := make(chan notifier.Message)
+	output := make(chan notifier.Message, 10)
+	go MakeAndRunEscalation(escalator, input, output)
+	defer close(input)
+
+	input <- makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed")
+	input <- makeSilencedMessage(notifier.Failure, "web", "web_db", "db failed")
+	assert.DeepEqual(t, []string{"chat"}, (<-output).Notifiers)
+	assert.DeepEqual(t, []string{"chat"}, (<-output).Notifiers)
+
+	writeAck := func(name, content string) {
+		assert.NilError(t, os.WriteFile(filepath.Join(ackDir, name), []byte(content), 0644))
+	}
+	writeAck("ack", "web_http\nweb_unknown\n")
+
+	// escalation is checked on ticks of the injected clock
+	clock.now = clock.now.Add(15 * time.Minute)
+	clock.ticks <- clock.now
+	msg := <-output
+	assert.Equal(t, "web_db", msg.MetricID)
+	assert.DeepEqual(t, []string{"sms"}, msg.Notifiers)
+	entries, err := os.ReadDir(ackDir)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestEscalation_InvalidPolicy(t *testing.T) {
+	router, err := notifier.MakeRouter(notifier.RoutesConfig{}, []string{"chat", "sms"})
+	assert.NilError(t, err)
+	for _, steps := range [][]EscalationStep{
+		{},
+		{{Notifiers: []string{"unknown"}}},
+		{{After: customtypes.Duration(time.Minute), Notifiers: []string{"chat"}}},
+		{{Notifiers: []string{"chat"}}, {Notifiers: []string{"sms"}}},
+	} {
+		_, err = MakeEscalator([]EscalationPolicy{{Name: "oncall", Steps: steps}}, router, nil, utils.SystemClock)
+		assert.ErrorIs(t, err, ErrInvalidEscalation)
+	}
+}
@@ -0,0 +1,78 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+)
+
+const maxDigestLines int = 10
+
+// messageDigest keeps the last message of each metric, to summarize suppressed messages
+type messageDigest struct {
+	count        int
+	lastMessages map[string]notifier.Message
+	metricOrder  []string
+}
+
+func makeMessageDigest() messageDigest {
+	return messageDigest{
+		lastMessages: map[string]notifier.Message{},
+	}
+}
+
+func (digest *messageDigest) add(msg notifier.Message) {
+	digest.count++
+	if _, exists := digest.lastMessages[msg.MetricID]; !exists {
+		digest.metricOrder = append(digest.metricOrder, msg.MetricID)
+	}
+	digest.lastMessages[msg.MetricID] = msg
+}
+
+// rank message types by importance in a digest
+func digestTypeRank(msgType notifier.MessageType) int {
+	switch msgType {
+	case notifier.Failure:
+		return 3
+	case notifier.Warning, notifier.Flapping:
+		return 2
+	case notifier.Notification:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// message is the header followed by the last status of each metric. It has the type and severity of
+// the most important last message (a recovery if every metric recovered), recovered metrics are listed.
+func (digest *messageDigest) message(headerFormat string, args ...any) notifier.Message {
+	msgType := notifier.Recovery
+	severity := notifier.SeverityNone
+	lines := []string{}
+	recovered := []string{}
+	for index, metricID := range digest.metricOrder {
+		lastMessage := digest.lastMessages[metricID]
+		if digestTypeRank(lastMessage.Type) > digestTypeRank(msgType) {
+			msgType = lastMessage.Type
+		}
+		severity = max(severity, lastMessage.Severity)
+		if lastMessage.Type == notifier.Recovery && metricID != "" {
+			recovered = append(recovered, metricID)
+		}
+		if index < maxDigestLines {
+			lines = append(lines, " - "+lastMessage.Message)
+		}
+	}
+	if len(digest.metricOrder) > maxDigestLines {
+		lines = append(lines, fmt.Sprintf(" - ... and %v more", len(digest.metricOrder)-maxDigestLines))
+	}
+	msg := notifier.MakeMessage(msgType, "%v, %v message(s) suppressed. Last status:\n%v", fmt.Sprintf(headerFormat, args...), digest.count, strings.Join(lines, "\n"))
+	msg.Severity = severity
+	msg.Recovered = recovered
+	if len(digest.metricOrder) == 1 {
+		msg.Scraper = digest.lastMessages[digest.metricOrder[0]].Scraper
+		msg.MetricID = digest.metricOrder[0]
+	}
+	return msg
+}
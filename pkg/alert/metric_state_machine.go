@@ -1,6 +1,8 @@
 package alert
 
 import (
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
@@ -14,14 +16,35 @@ type MetricStateMachine struct {
 	unhealthyThreshold uint // how many consecutive failed tests to mark metric as unhealthy (min 1)
 
 	isHealthy      bool
+	severity       provider.MetricStatus // Warning or Unhealthy (only meaningful when unhealthy)
 	oppositeInARow uint
 
 	failureReminder      time.Duration
 	failureReminderCount uint
 	dailyReminder        customtypes.TimeOfDay
 
+	failingSince       time.Time
 	lastFailureMessage time.Time
 	reminderCounter    uint
+
+	// flap detection (disabled when flapStartThreshold is 0)
+	flapWindow         time.Duration
+	flapStartThreshold uint        // state changes within flapWindow to start flapping
+	flapStopThreshold  uint        // flapping stops once state changes within flapWindow drop to this value
+	stateChanges       []time.Time // confirmed transitions (thresholds reached)
+	isFlapping         bool
+}
+
+// Persisted part of a MetricStateMachine (thresholds and reminder settings come from configuration)
+type MetricStateSnapshot struct {
+	IsHealthy          bool                  `json:"is_healthy"`
+	Severity           provider.MetricStatus `json:"severity"`
+	OppositeInARow     uint                  `json:"opposite_in_a_row"`
+	FailingSince       time.Time             `json:"failing_since"`
+	LastFailureMessage time.Time             `json:"last_failure_message"`
+	ReminderCounter    uint                  `json:"reminder_counter"`
+	StateChanges       []time.Time           `json:"state_changes,omitempty"`
+	IsFlapping         bool                  `json:"is_flapping"`
 }
 
 func MakeMetricStateMachine(healthyThreshold, unhealthyThreshold uint, failureReminderDelay time.Duration, failureReminderCount uint, dailyReminder customtypes.TimeOfDay) *MetricStateMachine {
@@ -29,15 +52,70 @@ func MakeMetricStateMachine(healthyThreshold, unhealthyThreshold uint, failureRe
 		healthyThreshold:     max(1, healthyThreshold),
 		unhealthyThreshold:   max(1, unhealthyThreshold),
 		isHealthy:            true,
+		severity:             provider.Unhealthy,
 		oppositeInARow:       0,
 		failureReminder:      failureReminderDelay,
 		failureReminderCount: failureReminderCount,
 		dailyReminder:        dailyReminder,
+		failingSince:         time.Time{},
 		lastFailureMessage:   time.Time{},
 		reminderCounter:      0,
 	}
 }
 
+// WithFlapDetection enables flap detection: once confirmed state changes (those reaching healthy/unhealthy
+// thresholds, whether notified or not) within window reach startThreshold,
+// a single "flapping" message is sent and other messages are suppressed until state changes drop to stopThreshold.
+func (msm *MetricStateMachine) WithFlapDetection(window time.Duration, startThreshold, stopThreshold uint) *MetricStateMachine {
+	msm.flapWindow = window
+	msm.flapStartThreshold = startThreshold
+	msm.flapStopThreshold = min(stopThreshold, startThreshold)
+	return msm
+}
+
+func (msm *MetricStateMachine) Snapshot() MetricStateSnapshot {
+	return MetricStateSnapshot{
+		IsHealthy:          msm.isHealthy,
+		Severity:           msm.severity,
+		OppositeInARow:     msm.oppositeInARow,
+		FailingSince:       msm.failingSince,
+		LastFailureMessage: msm.lastFailureMessage,
+		ReminderCounter:    msm.reminderCounter,
+		StateChanges:       slices.Clone(msm.stateChanges),
+		IsFlapping:         msm.isFlapping,
+	}
+}
+
+func (snapshot MetricStateSnapshot) Equal(other MetricStateSnapshot) bool {
+	return snapshot.IsHealthy == other.IsHealthy &&
+		snapshot.Severity == other.Severity &&
+		snapshot.OppositeInARow == other.OppositeInARow &&
+		snapshot.FailingSince.Equal(other.FailingSince) &&
+		snapshot.LastFailureMessage.Equal(other.LastFailureMessage) &&
+		snapshot.ReminderCounter == other.ReminderCounter &&
+		slices.EqualFunc(snapshot.StateChanges, other.StateChanges, time.Time.Equal) &&
+		snapshot.IsFlapping == other.IsFlapping
+}
+
+func (msm *MetricStateMachine) Restore(snapshot MetricStateSnapshot) {
+	msm.isHealthy = snapshot.IsHealthy
+	msm.severity = provider.Unhealthy
+	if snapshot.Severity == provider.Warning {
+		msm.severity = provider.Warning
+	}
+	msm.oppositeInARow = snapshot.OppositeInARow
+	msm.failingSince = snapshot.FailingSince
+	msm.lastFailureMessage = snapshot.LastFailureMessage
+	msm.reminderCounter = snapshot.ReminderCounter
+	msm.stateChanges = snapshot.StateChanges
+	msm.isFlapping = snapshot.IsFlapping
+}
+
+// IsDefault is true when there is nothing worth persisting (healthy, no pending transition)
+func (msm *MetricStateMachine) IsDefault() bool {
+	return msm.isHealthy && msm.oppositeInARow == 0 && !msm.isFlapping
+}
+
 func makeMessage(msgType notifier.MessageType, what, name, description string) *notifier.Message {
 	if description == "" {
 		return utils.Ptr(notifier.MakeMessage(msgType, "%v %v", name, what))
@@ -69,11 +147,51 @@ func (msm *MetricStateMachine) shouldRemind(now time.Time) bool {
 	return false
 }
 
+// rank statuses by severity (Removed is handled as Healthy)
+func severityRank(status provider.MetricStatus) int {
+	switch status {
+	case provider.Warning:
+		return 1
+	case provider.Unhealthy:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func severityMessageType(severity provider.MetricStatus) (notifier.MessageType, string) {
+	if severity == provider.Warning {
+		return notifier.Warning, "warning"
+	}
+	return notifier.Failure, "failed"
+}
+
+func (msm *MetricStateMachine) currentStatus() provider.MetricStatus {
+	if msm.isHealthy {
+		return provider.Healthy
+	}
+	return msm.severity
+}
+
 func (msm *MetricStateMachine) Update(metricState provider.MetricState, now time.Time) *notifier.Message {
+	updateStatus := metricState.Status
+	if updateStatus == provider.Removed {
+		updateStatus = provider.Healthy
+		msm.isFlapping = false
+		msm.stateChanges = nil
+	}
+
+	previousStatus := msm.currentStatus()
+	msg := msm.updateState(metricState, updateStatus, now)
+	if msm.flapStartThreshold == 0 || metricState.Status == provider.Removed {
+		return msg
+	}
+	return msm.updateFlapping(metricState, msm.currentStatus() != previousStatus, msg, now)
+}
 
-	isHealthyUpdate := metricState.Status == provider.Healthy || metricState.Status == provider.Removed
+func (msm *MetricStateMachine) updateState(metricState provider.MetricState, updateStatus provider.MetricStatus, now time.Time) *notifier.Message {
 
-	if msm.isHealthy != isHealthyUpdate {
+	if msm.currentStatus() != updateStatus {
 		msm.oppositeInARow++
 	} else {
 		msm.oppositeInARow = 0
@@ -84,35 +202,97 @@ func (msm *MetricStateMachine) Update(metricState provider.MetricState, now time
 		return msm.transitionToHealthy(metricState.Name, metricState.Description, "removed")
 	}
 
-	if msm.isHealthy {
-		if msm.oppositeInARow >= msm.unhealthyThreshold {
-			return msm.transitionToUnhealthy(metricState.Name, metricState.Description, now)
-		}
-	} else {
-		if msm.oppositeInARow >= msm.healthyThreshold {
+	// escalation (more severe) requires unhealthyThreshold, recovery and de-escalation require healthyThreshold
+	threshold := msm.healthyThreshold
+	if severityRank(updateStatus) > severityRank(msm.currentStatus()) {
+		threshold = msm.unhealthyThreshold
+	}
+
+	if msm.oppositeInARow >= threshold {
+		if updateStatus == provider.Healthy {
 			return msm.transitionToHealthy(metricState.Name, metricState.Description, "recovered")
-		} else if msm.shouldRemind(now) {
-			msm.lastFailureMessage = now
-			msm.reminderCounter++
-			return makeMessage(notifier.Failure, "failed (reminder)", metricState.Name, metricState.Description)
+		} else if msm.isHealthy {
+			return msm.transitionToUnhealthy(updateStatus, metricState.Name, metricState.Description, now)
+		} else {
+			return msm.transitionSeverity(updateStatus, metricState.Name, metricState.Description, now)
 		}
-
+	} else if !msm.isHealthy && msm.shouldRemind(now) {
+		msm.lastFailureMessage = now
+		msm.reminderCounter++
+		msgType, what := severityMessageType(msm.severity)
+		return makeMessage(msgType, what+" (reminder)", metricState.Name, metricState.Description)
 	}
 	return nil
 }
 
-func (msm *MetricStateMachine) transitionToUnhealthy(name, description string, now time.Time) *notifier.Message {
+func (msm *MetricStateMachine) transitionToUnhealthy(severity provider.MetricStatus, name, description string, now time.Time) *notifier.Message {
 	msm.isHealthy = false
+	msm.severity = severity
 	msm.oppositeInARow = 0
+	msm.failingSince = now
 	msm.lastFailureMessage = now
 	msm.reminderCounter = 0
-	return makeMessage(notifier.Failure, "failed", name, description)
+	msgType, what := severityMessageType(severity)
+	return makeMessage(msgType, what, name, description)
+}
+
+// transitionSeverity switches between Warning and Unhealthy (reminders restart)
+func (msm *MetricStateMachine) transitionSeverity(severity provider.MetricStatus, name, description string, now time.Time) *notifier.Message {
+	reason := " (de-escalated)"
+	if severityRank(severity) > severityRank(msm.severity) {
+		reason = " (escalated)"
+	}
+	msm.severity = severity
+	msm.oppositeInARow = 0
+	msm.lastFailureMessage = now
+	msm.reminderCounter = 0
+	msgType, what := severityMessageType(severity)
+	return makeMessage(msgType, what+reason, name, description)
 }
 
 func (msm *MetricStateMachine) transitionToHealthy(name, description string, reason string) *notifier.Message {
+	recoveredType, _ := severityMessageType(msm.severity)
 	msm.isHealthy = true
+	msm.severity = provider.Unhealthy
 	msm.oppositeInARow = 0
 	msm.reminderCounter = 0
+	msm.failingSince = time.Time{}
 	msm.lastFailureMessage = time.Time{}
-	return makeMessage(notifier.Recovery, reason, name, description)
+	msg := makeMessage(notifier.Recovery, reason, name, description)
+	msg.Severity = notifier.SeverityFromType(recoveredType)
+	return msg
+}
+
+// updateFlapping tracks confirmed state changes: msg is suppressed while flapping, a single message is sent when flapping starts and stops
+func (msm *MetricStateMachine) updateFlapping(metricState provider.MetricState, changed bool, msg *notifier.Message, now time.Time) *notifier.Message {
+	if changed {
+		msm.stateChanges = append(msm.stateChanges, now)
+	}
+	msm.stateChanges = slices.DeleteFunc(msm.stateChanges, func(change time.Time) bool {
+		return now.Sub(change) >= msm.flapWindow
+	})
+	stateChangeCount := uint(len(msm.stateChanges))
+
+	switch {
+	case !msm.isFlapping && stateChangeCount >= msm.flapStartThreshold:
+		msm.isFlapping = true
+		what := fmt.Sprintf("flapping (%v state changes within %v)", stateChangeCount, msm.flapWindow)
+		return makeMessage(notifier.Flapping, what, metricState.Name, metricState.Description)
+	case msm.isFlapping && stateChangeCount <= msm.flapStopThreshold:
+		msm.isFlapping = false
+		// current state is announced, reminders restart
+		if msm.isHealthy {
+			msg := makeMessage(notifier.Recovery, "stopped flapping (healthy)", metricState.Name, metricState.Description)
+			msg.Severity = notifier.SeverityWarning
+			return msg
+		}
+		msm.lastFailureMessage = now
+		msm.reminderCounter = 0
+		msgType, what := severityMessageType(msm.severity)
+		return makeMessage(msgType, "stopped flapping ("+what+")", metricState.Name, metricState.Description)
+	case msm.isFlapping:
+		return nil
+	default:
+		return msg
+	}
 }
@@ -174,3 +174,120 @@ func TestMetricStateMachine_Removal(t *testing.T) {
 func isContains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestMetricStateMachine_Severity(t *testing.T) {
+	// Setup: escalation requires 2, recovery and de-escalation are immediate
+	msm := MakeMetricStateMachine(1, 2, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	update := func(status provider.MetricStatus, timeOffset time.Duration) *notifier.Message {
+		return msm.Update(provider.MetricState{MetricID: "test_metric", Name: "Test Metric", Status: status, Description: "description"}, now.Add(timeOffset))
+	}
+
+	// 1. Warning after 2 updates
+	assert.Assert(t, update(provider.Warning, 0) == nil)
+	msg := update(provider.Warning, 0)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Warning, msg.Type)
+	assert.Equal(t, "Test Metric warning: description", msg.Message)
+
+	// 2. Warning reminder
+	msg = update(provider.Warning, 1*time.Hour)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Warning, msg.Type)
+	assert.Assert(t, isContains(msg.Message, "warning (reminder)"))
+
+	// 3. Escalation after 2 updates, reminders restart
+	assert.Assert(t, update(provider.Unhealthy, 90*time.Minute) == nil)
+	msg = update(provider.Unhealthy, 90*time.Minute)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Failure, msg.Type)
+	assert.Assert(t, isContains(msg.Message, "failed (escalated)"))
+	assert.Assert(t, update(provider.Unhealthy, 2*time.Hour) == nil)
+	msg = update(provider.Unhealthy, 150*time.Minute)
+	assert.Assert(t, msg != nil)
+	assert.Assert(t, isContains(msg.Message, "failed (reminder)"))
+
+	// 4. De-escalation is immediate (healthy threshold)
+	msg = update(provider.Warning, 3*time.Hour)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Warning, msg.Type)
+	assert.Assert(t, isContains(msg.Message, "warning (de-escalated)"))
+
+	// 5. Recovery
+	msg = update(provider.Healthy, 4*time.Hour)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Recovery, msg.Type)
+	assert.Equal(t, true, msm.isHealthy)
+
+	// 6. Direct failure (skipping warning)
+	assert.Assert(t, update(provider.Unhealthy, 5*time.Hour) == nil)
+	msg = update(provider.Unhealthy, 5*time.Hour)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, "Test Metric failed: description", msg.Message)
+}
+
+func TestMetricStateMachine_Flapping(t *testing.T) {
+	msm := MakeMetricStateMachine(1, 1, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0}).WithFlapDetection(1*time.Hour, 4, 1)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	update := func(status provider.MetricStatus, timeOffset time.Duration) *notifier.Message {
+		return msm.Update(provider.MetricState{MetricID: "test_metric", Name: "Test Metric", Status: status}, start.Add(timeOffset))
+	}
+
+	// state changes are notified until the start threshold is reached
+	assert.Equal(t, notifier.Failure, update(provider.Unhealthy, 0).Type)
+	assert.Equal(t, notifier.Recovery, update(provider.Healthy, 5*time.Minute).Type)
+	assert.Equal(t, notifier.Failure, update(provider.Unhealthy, 10*time.Minute).Type)
+	msg := update(provider.Healthy, 15*time.Minute)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Flapping, msg.Type)
+	assert.Equal(t, notifier.SeverityWarning, msg.Severity)
+	assert.Equal(t, "Test Metric flapping (4 state changes within 1h0m0s)", msg.Message)
+
+	// suppressed while flapping (state is still tracked)
+	assert.Assert(t, update(provider.Unhealthy, 20*time.Minute) == nil)
+	assert.Assert(t, update(provider.Healthy, 25*time.Minute) == nil)
+	assert.Assert(t, update(provider.Unhealthy, 30*time.Minute) == nil)
+	assert.Equal(t, provider.Unhealthy, msm.currentStatus())
+
+	// persisted
+	restored := MakeMetricStateMachine(1, 1, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0}).WithFlapDetection(1*time.Hour, 4, 1)
+	restored.Restore(msm.Snapshot())
+	assert.Assert(t, restored.isFlapping)
+	assert.Equal(t, 7, len(restored.stateChanges))
+
+	// stable again once state changes drop to the stop threshold: current state is announced
+	for offset := 35 * time.Minute; offset < 85*time.Minute; offset += 5 * time.Minute {
+		assert.Assert(t, update(provider.Unhealthy, offset) == nil)
+	}
+	msg = update(provider.Unhealthy, 85*time.Minute)
+	assert.Assert(t, msg != nil)
+	assert.Equal(t, notifier.Failure, msg.Type)
+	assert.Equal(t, "Test Metric stopped flapping (failed)", msg.Message)
+
+	// reminders restart
+	assert.Assert(t, update(provider.Unhealthy, 2*time.Hour) == nil)
+	assert.Assert(t, isContains(update(provider.Unhealthy, 85*time.Minute+time.Hour).Message, "reminder"))
+
+	// removal ends flapping immediately
+	for offset := 3 * time.Hour; offset < 3*time.Hour+30*time.Minute; offset += 5 * time.Minute {
+		update(provider.Healthy, offset)
+		update(provider.Unhealthy, offset+time.Minute)
+	}
+	assert.Assert(t, msm.isFlapping)
+	msg = update(provider.Removed, 4*time.Hour)
+	assert.Equal(t, notifier.Recovery, msg.Type)
+	assert.Assert(t, !msm.isFlapping)
+}
+
+func TestMetricStateMachine_FlappingBelowThreshold(t *testing.T) {
+	// flaky metric never reaching unhealthy_threshold: no confirmed state change, nothing to notify
+	msm := MakeMetricStateMachine(1, 3, 1*time.Hour, 3, customtypes.TimeOfDay{Hour: 8, Minute: 0}).WithFlapDetection(1*time.Hour, 4, 1)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for offset := time.Duration(0); offset < time.Hour; offset += 2 * time.Minute {
+		assert.Assert(t, msm.Update(provider.MetricState{MetricID: "test_metric", Name: "Test Metric", Status: provider.Unhealthy}, start.Add(offset)) == nil)
+		assert.Assert(t, msm.Update(provider.MetricState{MetricID: "test_metric", Name: "Test Metric", Status: provider.Healthy}, start.Add(offset+time.Minute)) == nil)
+	}
+	assert.Assert(t, !msm.isFlapping)
+	assert.Equal(t, 0, len(msm.stateChanges))
+}
@@ -0,0 +1,347 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+const silencesStorageKey = "silences"
+const silenceCheckInterval = 10 * time.Second
+
+var (
+	ErrInvalidSilence = errors.New("invalid silence")
+	ErrSilenceExists  = errors.New("silence already defined in configuration")
+)
+
+var weekDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type SilenceSchedule struct {
+	Days []string              `json:"days" default:"[]"` // mon, tue, wed, thu, fri, sat, sun (empty means every day)
+	From customtypes.TimeOfDay `json:"from"`
+	To   customtypes.TimeOfDay `json:"to"` // window crosses midnight when lower than from
+}
+
+type Silence struct {
+	Name      string                `json:"name"`
+	Scrapers  []string              `json:"scrapers" default:"[]"`   // glob patterns on scraper name (empty means any)
+	MetricIDs []string              `json:"metric_ids" default:"[]"` // glob patterns on metric ID (empty means any)
+	Start     *customtypes.DateTime `json:"start"`
+	End       *customtypes.DateTime `json:"end"`
+	Schedule  *SilenceSchedule      `json:"schedule"` // recurring window (ie. nightly backups)
+	Comment   string                `json:"comment" default:""`
+}
+
+type activeSilence struct {
+	Silence
+	scrapers  []*regexp.Regexp
+	metricIDs []*regexp.Regexp
+	days      []time.Weekday
+	runtime   bool // created at runtime (persisted), not from configuration
+
+	active     bool
+	suppressed messageDigest
+}
+
+// Silencer suppresses messages of silenced metrics, and sends a summary when a silence ends
+type Silencer struct {
+	mutex      sync.Mutex
+	storage    storage.Storager
+	silenceDir string
+	silences   []*activeSilence
+}
+
+func compileSilence(silence Silence, runtime bool) (*activeSilence, error) {
+	if !utils.IsNameValid(silence.Name) || silence.Name == "" {
+		return nil, fmt.Errorf("%w: forbidden characters in name '%v'", ErrInvalidSilence, silence.Name)
+	}
+	if silence.End == nil && silence.Schedule == nil {
+		return nil, fmt.Errorf("%w: %v: end or schedule is required", ErrInvalidSilence, silence.Name)
+	}
+	if silence.Start != nil && silence.End != nil && !silence.End.AsTime().After(silence.Start.AsTime()) {
+		return nil, fmt.Errorf("%w: %v: end must be after start", ErrInvalidSilence, silence.Name)
+	}
+	compiled := &activeSilence{
+		Silence:    silence,
+		runtime:    runtime,
+		suppressed: makeMessageDigest(),
+	}
+	var err error
+	if compiled.scrapers, err = utils.CompileGlobList(silence.Scrapers); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidSilence, silence.Name, err)
+	}
+	if compiled.metricIDs, err = utils.CompileGlobList(silence.MetricIDs); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidSilence, silence.Name, err)
+	}
+	if silence.Schedule != nil {
+		for _, day := range silence.Schedule.Days {
+			weekDay, exists := weekDays[strings.ToLower(day)]
+			if !exists {
+				return nil, fmt.Errorf("%w: %v: unknown day %v", ErrInvalidSilence, silence.Name, day)
+			}
+			compiled.days = append(compiled.days, weekDay)
+		}
+	}
+	return compiled, nil
+}
+
+func (silence *activeSilence) inSchedule(now time.Time) bool {
+	schedule := silence.Schedule
+	minutes := now.Hour()*60 + now.Minute()
+	from := schedule.From.Hour*60 + schedule.From.Minute
+	to := schedule.To.Hour*60 + schedule.To.Minute
+
+	day := now.Weekday()
+	switch {
+	case from < to:
+		if minutes < from || minutes >= to {
+			return false
+		}
+	case from > to:
+		if minutes < to {
+			day = (day + 6) % 7 // window started the day before
+		} else if minutes < from {
+			return false
+		}
+	}
+	return len(silence.days) == 0 || slices.Contains(silence.days, day)
+}
+
+func (silence *activeSilence) isActive(now time.Time) bool {
+	if silence.Start != nil && now.Before(silence.Start.AsTime()) {
+		return false
+	}
+	if silence.End != nil && !now.Before(silence.End.AsTime()) {
+		return false
+	}
+	return silence.Schedule == nil || silence.inSchedule(now)
+}
+
+// expired silences will never be active again
+func (silence *activeSilence) isExpired(now time.Time) bool {
+	return silence.End != nil && !now.Before(silence.End.AsTime())
+}
+
+func (silence *activeSilence) match(msg notifier.Message) bool {
+	return utils.MatchGlobList(silence.scrapers, msg.Scraper) && utils.MatchGlobList(silence.metricIDs, msg.MetricID)
+}
+
+// summary of suppressed messages (last message of each metric), nil if nothing was suppressed
+func (silence *activeSilence) summary() *notifier.Message {
+	if silence.suppressed.count == 0 {
+		return nil
+	}
+	msg := silence.suppressed.message("Silence %v ended", silence.Name)
+	return &msg
+}
+
+func (silence *activeSilence) reset() {
+	silence.active = false
+	silence.suppressed = makeMessageDigest()
+}
+
+func MakeSilencer(configSilences []Silence, silenceDir string, storage storage.Storager) (*Silencer, error) {
+	silencer := &Silencer{storage: storage, silenceDir: silenceDir}
+	for _, silence := range configSilences {
+		compiled, err := compileSilence(silence, false)
+		if err != nil {
+			return nil, err
+		}
+		silencer.silences = append(silencer.silences, compiled)
+	}
+
+	if raw, exists := storage.Get(silencesStorageKey); exists {
+		runtimeSilences := []Silence{}
+		if err := json.Unmarshal([]byte(raw), &runtimeSilences); err != nil {
+			logging.Warning("Unable to restore silences: %v", err)
+		}
+		for _, silence := range runtimeSilences {
+			compiled, err := compileSilence(silence, true)
+			if err != nil {
+				logging.Warning("Unable to restore silence: %v", err)
+				continue
+			}
+			silencer.silences = append(silencer.silences, compiled)
+		}
+	}
+	return silencer, nil
+}
+
+// Add a runtime silence (persisted), replacing any runtime silence with the same name
+func (silencer *Silencer) Add(silence Silence) error {
+	compiled, err := compileSilence(silence, true)
+	if err != nil {
+		return err
+	}
+	silencer.mutex.Lock()
+	defer silencer.mutex.Unlock()
+	for index, existing := range silencer.silences {
+		if existing.Name == silence.Name {
+			if !existing.runtime {
+				return fmt.Errorf("%w: %v", ErrSilenceExists, silence.Name)
+			}
+			// keep suppressed messages for the summary
+			compiled.active, compiled.suppressed = existing.active, existing.suppressed
+			silencer.silences[index] = compiled
+			silencer.save()
+			return nil
+		}
+	}
+	logging.Info("Adding silence %v", silence.Name)
+	silencer.silences = append(silencer.silences, compiled)
+	silencer.save()
+	return nil
+}
+
+func (silencer *Silencer) save() {
+	runtimeSilences := []Silence{}
+	for _, silence := range silencer.silences {
+		if silence.runtime {
+			runtimeSilences = append(runtimeSilences, silence.Silence)
+		}
+	}
+	raw, err := json.Marshal(runtimeSilences)
+	if err != nil {
+		logging.Error("Unable to persist silences: %v", err)
+		return
+	}
+	silencer.storage.Set(silencesStorageKey, string(raw))
+}
+
+// Suppress returns true if msg belongs to an active silence (it is then kept for the summary)
+func (silencer *Silencer) Suppress(msg notifier.Message, now time.Time) bool {
+	silencer.mutex.Lock()
+	defer silencer.mutex.Unlock()
+	for _, silence := range silencer.silences {
+		if silence.match(msg) && silence.isActive(now) {
+			silence.active = true
+			silence.suppressed.add(msg)
+			logging.Debug("Silenced by %v: %v", silence.Name, msg)
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns true if msg belongs to an active silence (msg is not suppressed)
+func (silencer *Silencer) Matches(msg notifier.Message, now time.Time) bool {
+	silencer.mutex.Lock()
+	defer silencer.mutex.Unlock()
+	return slices.ContainsFunc(silencer.silences, func(silence *activeSilence) bool {
+		return silence.match(msg) && silence.isActive(now)
+	})
+}
+
+// Update silences: returns summaries of ended silences, and drops expired runtime silences
+func (silencer *Silencer) Update(now time.Time) []notifier.Message {
+	silencer.mutex.Lock()
+	defer silencer.mutex.Unlock()
+	summaries := []notifier.Message{}
+	for _, silence := range silencer.silences {
+		isActive := silence.isActive(now)
+		if isActive && !silence.active {
+			logging.Info("Silence %v started", silence.Name)
+			silence.active = true
+		} else if !isActive && silence.active {
+			logging.Info("Silence %v ended (%v message(s) suppressed)", silence.Name, silence.suppressed.count)
+			if summary := silence.summary(); summary != nil {
+				summaries = append(summaries, *summary)
+			}
+			silence.reset()
+		}
+	}
+
+	expiredCount := len(silencer.silences)
+	silencer.silences = slices.DeleteFunc(silencer.silences, func(silence *activeSilence) bool {
+		return silence.runtime && silence.isExpired(now)
+	})
+	if expiredCount != len(silencer.silences) {
+		silencer.save()
+	}
+	return summaries
+}
+
+// loadSilenceDir adds silences dropped (yaml or json, one silence per file) in the silence directory, then removes files
+func (silencer *Silencer) loadSilenceDir() {
+	if silencer.silenceDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(silencer.silenceDir)
+	if err != nil {
+		logging.Warning("Unable to read silence directory: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		extension := filepath.Ext(entry.Name())
+		if entry.IsDir() || !slices.Contains([]string{".yml", ".yaml", ".json"}, extension) {
+			continue
+		}
+		path := filepath.Join(silencer.silenceDir, entry.Name())
+		if err := silencer.loadSilenceFile(path); err != nil {
+			logging.Error("Unable to load silence %v: %v", entry.Name(), err)
+		}
+		if err := os.Remove(path); err != nil {
+			logging.Error("Unable to remove %v: %v", path, err)
+		}
+	}
+}
+
+func (silencer *Silencer) loadSilenceFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return err
+	}
+	silence, err := configmapper.MapOnStruct[Silence](raw)
+	if err != nil {
+		return err
+	}
+	return silencer.Add(silence)
+}
+
+func MakeAndRunSilencing(silencer *Silencer, input <-chan notifier.Message, output chan<- notifier.Message) {
+	ticker := time.NewTicker(silenceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-input:
+			if !ok {
+				return
+			}
+			if !silencer.Suppress(msg, time.Now()) {
+				output <- msg
+			}
+		case now := <-ticker.C:
+			silencer.loadSilenceDir()
+			for _, summary := range silencer.Update(now) {
+				output <- summary
+			}
+		}
+	}
+}
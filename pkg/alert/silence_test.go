@@ -0,0 +1,114 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+func makeSilencedMessage(msgType notifier.MessageType, scraper, metricID, text string) notifier.Message {
+	msg := notifier.MakeMessage(msgType, "%v", text)
+	msg.Scraper = scraper
+	msg.MetricID = metricID
+	return msg
+}
+
+func TestSilenceSchedule(t *testing.T) {
+	silencer, err := MakeSilencer([]Silence{
+		{
+			Name:     "backups",
+			Scrapers: []string{"backup*"},
+			Schedule: &SilenceSchedule{From: customtypes.TimeOfDay{Hour: 23, Minute: 30}, To: customtypes.TimeOfDay{Hour: 1, Minute: 0}, Days: []string{"mon"}},
+		},
+	}, "", storage.NewMemoryStorage())
+	assert.NilError(t, err)
+
+	msg := makeSilencedMessage(notifier.Failure, "backups", "backups_fileage_db", "db failed")
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local) // monday
+
+	assert.Assert(t, !silencer.Suppress(msg, monday.Add(30*time.Minute)))             // monday 00:30, window started on sunday
+	assert.Assert(t, !silencer.Suppress(msg, monday.Add(23*time.Hour)))               // monday 23:00
+	assert.Assert(t, silencer.Suppress(msg, monday.Add(23*time.Hour+45*time.Minute))) // monday 23:45
+	assert.Assert(t, silencer.Suppress(msg, monday.Add(24*time.Hour+30*time.Minute))) // tuesday 00:30
+	assert.Assert(t, !silencer.Suppress(msg, monday.Add(25*time.Hour)))               // tuesday 01:00
+	assert.Assert(t, !silencer.Suppress(makeSilencedMessage(notifier.Failure, "disk", "disk_filesystemusage_/", "disk full"), monday.Add(23*time.Hour+45*time.Minute)))
+}
+
+func TestSilenceSummary(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	silencer, err := MakeSilencer([]Silence{
+		{
+			Name:      "maintenance",
+			MetricIDs: []string{"web_*"},
+			Start:     utils.Ptr(customtypes.DateTime(start)),
+			End:       utils.Ptr(customtypes.DateTime(start.Add(time.Hour))),
+		},
+	}, "", storage.NewMemoryStorage())
+	assert.NilError(t, err)
+
+	assert.Equal(t, 0, len(silencer.Update(start)))
+	assert.Assert(t, silencer.Suppress(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"), start.Add(time.Minute)))
+	assert.Assert(t, silencer.Suppress(makeSilencedMessage(notifier.Failure, "web", "web_db", "db failed"), start.Add(2*time.Minute)))
+	assert.Assert(t, silencer.Suppress(makeSilencedMessage(notifier.Recovery, "web", "web_http", "http recovered"), start.Add(3*time.Minute)))
+	assert.Equal(t, 0, len(silencer.Update(start.Add(30*time.Minute))))
+
+	summaries := silencer.Update(start.Add(time.Hour))
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, notifier.Failure, summaries[0].Type)
+	assert.Equal(t, "Silence maintenance ended, 3 message(s) suppressed. Last status:\n - http recovered\n - db failed", summaries[0].Message)
+
+	// ended silence only once
+	assert.Equal(t, 0, len(silencer.Update(start.Add(2*time.Hour))))
+	assert.Assert(t, !silencer.Suppress(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"), start.Add(2*time.Hour)))
+}
+
+func TestSilenceRuntime(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	now := time.Now()
+	silencer, err := MakeSilencer([]Silence{
+		{Name: "backups", Schedule: &SilenceSchedule{From: customtypes.TimeOfDay{Hour: 2}, To: customtypes.TimeOfDay{Hour: 3}}},
+	}, "", memoryStorage)
+	assert.NilError(t, err)
+
+	assert.ErrorIs(t, silencer.Add(Silence{Name: "backups", End: utils.Ptr(customtypes.DateTime(now.Add(time.Hour)))}), ErrSilenceExists)
+	assert.ErrorIs(t, silencer.Add(Silence{Name: "forever"}), ErrInvalidSilence)
+	assert.NilError(t, silencer.Add(Silence{Name: "upgrade", Scrapers: []string{"web"}, End: utils.Ptr(customtypes.DateTime(now.Add(time.Hour)))}))
+
+	// restored from storage
+	silencer, err = MakeSilencer(nil, "", memoryStorage)
+	assert.NilError(t, err)
+	assert.Assert(t, silencer.Suppress(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"), now))
+
+	// dropped once expired
+	summaries := silencer.Update(now.Add(time.Hour))
+	assert.Equal(t, 1, len(summaries))
+	silencer, err = MakeSilencer(nil, "", memoryStorage)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(silencer.silences))
+}
+
+func TestSilenceDir(t *testing.T) {
+	silenceDir := t.TempDir()
+	silencer, err := MakeSilencer(nil, silenceDir, storage.NewMemoryStorage())
+	assert.NilError(t, err)
+
+	end := time.Now().Add(time.Hour).Format(time.DateTime)
+	assert.NilError(t, os.WriteFile(filepath.Join(silenceDir, "upgrade.yml"), []byte("name: upgrade\nscrapers: [web]\nend: \""+end+"\"\n"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(silenceDir, "invalid.yml"), []byte("name: invalid\n"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(silenceDir, "README"), []byte("ignored"), 0o600))
+	silencer.loadSilenceDir()
+
+	assert.Equal(t, 1, len(silencer.silences))
+	assert.Equal(t, "upgrade", silencer.silences[0].Name)
+	entries, err := os.ReadDir(silenceDir)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Assert(t, silencer.Suppress(makeSilencedMessage(notifier.Failure, "web", "web_http", "http failed"), time.Now()))
+}
@@ -15,6 +15,7 @@ import (
 type Config struct {
 	MachineName    string                     `json:"machine_name" default:""`
 	Notifiers      map[string]notifier.Config `json:"notifiers"`
+	Routes         notifier.RoutesConfig      `json:"routes" default:"{}"`
 	Alert          alert.Config               `json:"alert" default:"{}"`
 	Scrapers       map[string]provider.Config `json:"scrapers"`
 	CachePath      string                     `json:"cache"`
@@ -2,6 +2,7 @@ package notifier
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -14,44 +15,127 @@ const (
 	Failure
 	Recovery
 	Aggregate
+	Warning  // less severe than Failure
+	Flapping // metric state changes too often (state notifications are suppressed until stable)
 )
 
+type Severity uint
+
+const (
+	SeverityNone Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityNone:     "none",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (severity Severity) String() string {
+	return severityNames[severity]
+}
+
 type Message struct {
-	Type    MessageType
-	Title   string
-	Message string
+	Type     MessageType
+	Severity Severity // for recoveries, severity of the recovered alert
+	Title    string
+	Message  string
+
+	// Routing information
+	Scraper   string
+	MetricID  string
+	Recovered []string // digests: metric IDs whose last status is a recovery
+	Notifiers []string // destination, nil until routed
+}
+
+// SeverityFromType is the severity carried by a message type (none for notifications and recoveries)
+func SeverityFromType(type_ MessageType) Severity {
+	switch type_ {
+	case Failure:
+		return SeverityCritical
+	case Warning, Flapping:
+		return SeverityWarning
+	default:
+		return SeverityNone
+	}
 }
 
 func MakeMessage(type_ MessageType, descriptionFormat string, args ...any) Message {
 	return Message{
-		Type:    type_,
-		Title:   strings.ToLower(fmt.Sprintf("%v", type_)),
-		Message: fmt.Sprintf(descriptionFormat, args...),
+		Type:     type_,
+		Severity: SeverityFromType(type_),
+		Title:    strings.ToLower(fmt.Sprintf("%v", type_)),
+		Message:  fmt.Sprintf(descriptionFormat, args...),
+	}
+}
+
+// DefaultSeverityOrder ranks message types in aggregated messages (most important first)
+var DefaultSeverityOrder = []MessageType{Failure, Warning, Flapping, Notification, Recovery}
+
+// ParseMessageType parses a lower case message type name (ie. failure)
+func ParseMessageType(name string) (MessageType, bool) {
+	index := slices.IndexFunc(DefaultSeverityOrder, func(type_ MessageType) bool {
+		return strings.EqualFold(type_.String(), name)
+	})
+	if index < 0 {
+		return Undefined, false
+	}
+	return DefaultSeverityOrder[index], true
+}
+
+// rank of a message type in severityOrder, types missing from severityOrder come last (in default order)
+func aggregationRank(severityOrder []MessageType, type_ MessageType) int {
+	if index := slices.Index(severityOrder, type_); index >= 0 {
+		return index
+	}
+	if index := slices.Index(DefaultSeverityOrder, type_); index >= 0 {
+		return len(severityOrder) + index
 	}
+	return len(severityOrder) + len(DefaultSeverityOrder)
 }
 
-func MakeAggregatedMessage(msgList []Message) Message {
+// MakeAggregatedMessage sorts messages by severityOrder (DefaultSeverityOrder when nil: failures first, then warnings,
+// flapping, notifications and recoveries)
+func MakeAggregatedMessage(msgList []Message, severityOrder []MessageType) Message {
+	if severityOrder == nil {
+		severityOrder = DefaultSeverityOrder
+	}
+	msgList = slices.Clone(msgList)
+	slices.SortStableFunc(msgList, func(a, b Message) int {
+		return aggregationRank(severityOrder, a.Type) - aggregationRank(severityOrder, b.Type)
+	})
+
 	msgTypeMap := make(map[MessageType]int)
-	var title, description string
+	msgTypes := []MessageType{}
+	var description string
+	severity := SeverityNone
 	for _, msg := range msgList {
+		if msgTypeMap[msg.Type] == 0 {
+			msgTypes = append(msgTypes, msg.Type)
+		}
 		msgTypeMap[msg.Type]++
+		severity = max(severity, msg.Severity)
 		description += " - " + msg.Message + "\n"
 	}
 
-	first := true
-	for type_, cnt := range msgTypeMap {
-		if first {
-			title += fmt.Sprintf("%v: %v", type_, cnt)
-		} else {
-			title += fmt.Sprintf(", %v: %v", type_, cnt)
-		}
-		first = false
+	titleParts := make([]string, 0, len(msgTypes))
+	for _, type_ := range msgTypes {
+		titleParts = append(titleParts, fmt.Sprintf("%v: %v", type_, msgTypeMap[type_]))
+	}
+	title := strings.ToLower(strings.Join(titleParts, ", "))
+
+	var notifiers []string
+	if len(msgList) > 0 {
+		notifiers = msgList[0].Notifiers // grouped by destination
 	}
-	title = strings.ToLower(title)
 
 	return Message{
-		Type:    Aggregate,
-		Title:   title,
-		Message: description,
+		Type:      Aggregate,
+		Severity:  severity,
+		Title:     title,
+		Message:   description,
+		Notifiers: notifiers,
 	}
 }
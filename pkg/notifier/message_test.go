@@ -0,0 +1,40 @@
+package notifier_test
+
+import (
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"gotest.tools/v3/assert"
+)
+
+func TestMakeAggregatedMessage(t *testing.T) {
+	msg := notifier.MakeAggregatedMessage([]notifier.Message{
+		notifier.MakeMessage(notifier.Recovery, "ping recovered"),
+		notifier.MakeMessage(notifier.Notification, "image updated"),
+		notifier.MakeMessage(notifier.Failure, "disk full"),
+		notifier.MakeMessage(notifier.Warning, "disk almost full"),
+		notifier.MakeMessage(notifier.Failure, "service failed"),
+	}, nil)
+	assert.Equal(t, notifier.Aggregate, msg.Type)
+	assert.Equal(t, notifier.SeverityCritical, msg.Severity)
+	assert.Equal(t, "failure: 2, warning: 1, notification: 1, recovery: 1", msg.Title)
+	assert.Equal(t, " - disk full\n - service failed\n - disk almost full\n - image updated\n - ping recovered\n", msg.Message)
+}
+
+func TestMakeAggregatedMessage_SeverityOrder(t *testing.T) {
+	msg := notifier.MakeAggregatedMessage([]notifier.Message{
+		notifier.MakeMessage(notifier.Failure, "disk full"),
+		notifier.MakeMessage(notifier.Notification, "image updated"),
+		notifier.MakeMessage(notifier.Recovery, "ping recovered"),
+		notifier.MakeMessage(notifier.Warning, "disk almost full"),
+	}, []notifier.MessageType{notifier.Recovery, notifier.Failure})
+	// unlisted types come last, in default order
+	assert.Equal(t, "recovery: 1, failure: 1, warning: 1, notification: 1", msg.Title)
+	assert.Equal(t, " - ping recovered\n - disk full\n - disk almost full\n - image updated\n", msg.Message)
+
+	type_, ok := notifier.ParseMessageType("flapping")
+	assert.Assert(t, ok)
+	assert.Equal(t, notifier.Flapping, type_)
+	_, ok = notifier.ParseMessageType("aggregate")
+	assert.Assert(t, !ok)
+}
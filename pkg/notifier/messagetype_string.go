@@ -13,11 +13,13 @@ func _() {
 	_ = x[Failure-2]
 	_ = x[Recovery-3]
 	_ = x[Aggregate-4]
+	_ = x[Warning-5]
+	_ = x[Flapping-6]
 }
 
-const _MessageType_name = "UndefinedNotificationFailureRecoveryAggregate"
+const _MessageType_name = "UndefinedNotificationFailureRecoveryAggregateWarningFlapping"
 
-var _MessageType_index = [...]uint8{0, 9, 21, 28, 36, 45}
+var _MessageType_index = [...]uint8{0, 9, 21, 28, 36, 45, 52, 60}
 
 func (i MessageType) String() string {
 	if i >= MessageType(len(_MessageType_index)-1) {
@@ -19,7 +19,7 @@ func LoadNotifierFromConfig(cfg Config) (Notifier, error) {
 	return factory(cfg)
 }
 
-func LoadAndRunNotifiers(ctx context.Context, machineName string, notifierCfgList map[string]Config, messageChan <-chan Message) {
+func LoadAndRunNotifiers(ctx context.Context, machineName string, notifierCfgList map[string]Config, router *Router, messageChan <-chan Message) {
 	notifierList := make(map[string]Notifier, len(notifierCfgList))
 	var err error
 	for notifierName, notifierCfg := range notifierCfgList {
@@ -40,10 +40,13 @@ mainloop:
 			break mainloop
 		case msg := <-messageChan:
 			msg.Title = machineName + " " + msg.Title
-			for _, notifier := range notifierList {
-				err = notifier.Send(msg)
+			if msg.Notifiers == nil {
+				msg.Notifiers = router.Route(msg)
+			}
+			for _, notifierName := range msg.Notifiers {
+				err = notifierList[notifierName].Send(msg)
 				if err != nil {
-					logging.Error("Failed to notify: %v", err)
+					logging.Error("Failed to notify %v: %v", notifierName, err)
 				}
 			}
 		}
@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+)
+
+var (
+	ErrUnknownNotifier = errors.New("unknown notifier")
+	ErrInvalidRoute    = errors.New("invalid route")
+)
+
+type RouteRule struct {
+	Scrapers   []string `json:"scrapers" default:"[]"`    // glob patterns on scraper name
+	MetricIDs  []string `json:"metric_ids" default:"[]"`  // glob patterns on metric ID (* also matches /)
+	Types      []string `json:"types" default:"[]"`       // notification, failure, warning, flapping, recovery
+	Severities []string `json:"severities" default:"[]"`  // warning, critical (recoveries keep the severity of the recovered alert)
+	Notifiers  []string `json:"notifiers"`                // empty list drops matching messages
+	Continue   bool     `json:"continue" default:"false"` // keep evaluating next rules once matched
+}
+
+type RoutesConfig struct {
+	Default []string    `json:"default" default:"[]"` // notifiers used when no rule matches, empty means all notifiers
+	Rules   []RouteRule `json:"rules" default:"[]"`
+}
+
+type compiledRouteRule struct {
+	scrapers         []*regexp.Regexp
+	metricIDs        []*regexp.Regexp
+	types            []MessageType
+	severities       []Severity
+	notifiers        []string
+	continueMatching bool
+}
+
+// Router selects destination notifiers of a message. Rules are evaluated in order, the first matching rule
+// wins unless `continue` is set. Messages matching no rule are sent to default notifiers.
+type Router struct {
+	notifierNames    []string
+	defaultNotifiers []string
+	rules            []compiledRouteRule
+}
+
+func checkNotifierNames(names, notifierNames []string) error {
+	for _, name := range names {
+		if !slices.Contains(notifierNames, name) {
+			return fmt.Errorf("%w: %v", ErrUnknownNotifier, name)
+		}
+	}
+	return nil
+}
+
+func compileRouteRule(rule RouteRule, notifierNames []string) (compiledRouteRule, error) {
+	compiled := compiledRouteRule{
+		notifiers:        rule.Notifiers,
+		continueMatching: rule.Continue,
+	}
+	var err error
+	if err = checkNotifierNames(rule.Notifiers, notifierNames); err != nil {
+		return compiled, err
+	}
+	if compiled.scrapers, err = utils.CompileGlobList(rule.Scrapers); err != nil {
+		return compiled, fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	if compiled.metricIDs, err = utils.CompileGlobList(rule.MetricIDs); err != nil {
+		return compiled, fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	for _, typeName := range rule.Types {
+		type_, ok := ParseMessageType(typeName)
+		if !ok {
+			return compiled, fmt.Errorf("%w: unknown message type %v", ErrInvalidRoute, typeName)
+		}
+		compiled.types = append(compiled.types, type_)
+	}
+	for _, severityName := range rule.Severities {
+		switch severityName {
+		case SeverityWarning.String():
+			compiled.severities = append(compiled.severities, SeverityWarning)
+		case SeverityCritical.String():
+			compiled.severities = append(compiled.severities, SeverityCritical)
+		default:
+			return compiled, fmt.Errorf("%w: unknown severity %v", ErrInvalidRoute, severityName)
+		}
+	}
+	return compiled, nil
+}
+
+func MakeRouter(cfg RoutesConfig, notifierNames []string) (*Router, error) {
+	router := &Router{
+		notifierNames:    notifierNames,
+		defaultNotifiers: cfg.Default,
+	}
+	if len(router.defaultNotifiers) == 0 {
+		router.defaultNotifiers = slices.Sorted(slices.Values(notifierNames))
+	} else if err := checkNotifierNames(cfg.Default, notifierNames); err != nil {
+		return nil, err
+	}
+	for index, rule := range cfg.Rules {
+		compiled, err := compileRouteRule(rule, notifierNames)
+		if err != nil {
+			return nil, fmt.Errorf("rule %v: %w", index, err)
+		}
+		router.rules = append(router.rules, compiled)
+	}
+	return router, nil
+}
+
+// CheckNotifiers returns ErrUnknownNotifier if a name isn't a configured notifier
+func (router *Router) CheckNotifiers(names []string) error {
+	return checkNotifierNames(names, router.notifierNames)
+}
+
+func (rule *compiledRouteRule) match(msg Message) bool {
+	return utils.MatchGlobList(rule.scrapers, msg.Scraper) &&
+		utils.MatchGlobList(rule.metricIDs, msg.MetricID) &&
+		(len(rule.types) == 0 || slices.Contains(rule.types, msg.Type)) &&
+		(len(rule.severities) == 0 || slices.Contains(rule.severities, msg.Severity))
+}
+
+// Route returns the sorted list of destination notifiers
+func (router *Router) Route(msg Message) []string {
+	notifiers := []string{}
+	matched := false
+	for _, rule := range router.rules {
+		if rule.match(msg) {
+			matched = true
+			notifiers = append(notifiers, rule.notifiers...)
+			if !rule.continueMatching {
+				break
+			}
+		}
+	}
+	if !matched {
+		notifiers = append(notifiers, router.defaultNotifiers...)
+	}
+	slices.Sort(notifiers)
+	return slices.Compact(notifiers)
+}
@@ -0,0 +1,63 @@
+package notifier_test
+
+import (
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/notifier"
+	"gotest.tools/v3/assert"
+)
+
+func TestRouter(t *testing.T) {
+	router, err := notifier.MakeRouter(notifier.RoutesConfig{
+		Default: []string{"chat"},
+		Rules: []notifier.RouteRule{
+			{MetricIDs: []string{"*_filesystemusage_*"}, Types: []string{"failure", "recovery"}, Severities: []string{"critical"}, Notifiers: []string{"pager"}, Continue: true},
+			{Scrapers: []string{"containers"}, Types: []string{"notification"}, Notifiers: []string{"lowpriority"}},
+			{Scrapers: []string{"test*"}, Notifiers: []string{}},
+		},
+	}, []string{"chat", "pager", "lowpriority"})
+	assert.NilError(t, err)
+
+	makeMessage := func(type_ notifier.MessageType, scraper, metricID string) notifier.Message {
+		msg := notifier.MakeMessage(type_, "message")
+		msg.Scraper = scraper
+		msg.MetricID = metricID
+		return msg
+	}
+
+	// disk failure: pager, then no other rule matches (default isn't used once a rule matched)
+	assert.DeepEqual(t, []string{"pager"}, router.Route(makeMessage(notifier.Failure, "disk", "disk_filesystemusage_/")))
+	// disk warning: not critical
+	assert.DeepEqual(t, []string{"chat"}, router.Route(makeMessage(notifier.Warning, "disk", "disk_filesystemusage_/")))
+	// recovery of a critical failure
+	recovery := makeMessage(notifier.Recovery, "disk", "disk_filesystemusage_/")
+	recovery.Severity = notifier.SeverityCritical
+	assert.DeepEqual(t, []string{"pager"}, router.Route(recovery))
+	// image update
+	assert.DeepEqual(t, []string{"lowpriority"}, router.Route(makeMessage(notifier.Notification, "containers", "containers_image_nginx")))
+	assert.DeepEqual(t, []string{"chat"}, router.Route(makeMessage(notifier.Failure, "containers", "containers_nginx")))
+	// dropped
+	assert.DeepEqual(t, []string{}, router.Route(makeMessage(notifier.Failure, "testing", "testing_ping")))
+	// startup message (no scraper)
+	assert.DeepEqual(t, []string{"chat"}, router.Route(notifier.MakeMessage(notifier.Notification, "started")))
+}
+
+func TestRouterDefaultsToAllNotifiers(t *testing.T) {
+	router, err := notifier.MakeRouter(notifier.RoutesConfig{}, []string{"b", "a"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"a", "b"}, router.Route(notifier.MakeMessage(notifier.Failure, "failure")))
+}
+
+func TestRouterInvalidConfig(t *testing.T) {
+	_, err := notifier.MakeRouter(notifier.RoutesConfig{Default: []string{"unknown"}}, []string{"chat"})
+	assert.ErrorIs(t, err, notifier.ErrUnknownNotifier)
+
+	_, err = notifier.MakeRouter(notifier.RoutesConfig{Rules: []notifier.RouteRule{{Notifiers: []string{"unknown"}}}}, []string{"chat"})
+	assert.ErrorIs(t, err, notifier.ErrUnknownNotifier)
+
+	_, err = notifier.MakeRouter(notifier.RoutesConfig{Rules: []notifier.RouteRule{{Types: []string{"aggregate"}, Notifiers: []string{"chat"}}}}, []string{"chat"})
+	assert.ErrorIs(t, err, notifier.ErrInvalidRoute)
+
+	_, err = notifier.MakeRouter(notifier.RoutesConfig{Rules: []notifier.RouteRule{{Severities: []string{"info"}, Notifiers: []string{"chat"}}}}, []string{"chat"})
+	assert.ErrorIs(t, err, notifier.ErrInvalidRoute)
+}
@@ -2,14 +2,34 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
 )
 
+var ErrSSHUnsupported = errors.New("ssh transport not supported")
+
+// Alert policy of a scraper, overrides alert thresholds and reminders (unset fields use alert configuration)
+type AlertPolicy struct {
+	UnhealthyThreshold   *uint                  `json:"unhealthy_threshold"`
+	HealthyThreshold     *uint                  `json:"healthy_threshold"`
+	FailureReminder      *customtypes.Duration  `json:"failure_reminder"`
+	FailureReminderCount *uint                  `json:"failure_reminder_count"`
+	DailyReminderTime    *customtypes.TimeOfDay `json:"daily_reminder_time"`
+}
+
 type Config struct {
 	Type           string               `json:"type"`
 	ScrapeInterval customtypes.Duration `json:"scrape_interval" default:"120s"` // scrape interval
 	Params         map[string]any       `json:"params" default:"{}"`            // extra parameters
+	SSH            *sshclient.Config    `json:"ssh"`                            // monitor a remote host (optional)
+	Tags           []string             `json:"tags" default:"[]"`              // used by alert dependencies
+	Alert          AlertPolicy          `json:"alert" default:"{}"`             // alert policy overrides
 }
 
 func LoadProviderFromConfig(ctx context.Context, cfg Config) (Provider, error) {
@@ -17,5 +37,26 @@ func LoadProviderFromConfig(ctx context.Context, cfg Config) (Provider, error) {
 	if err != nil {
 		return nil, err
 	}
-	return factory(ctx, cfg)
+	providerInstance, err := factory(ctx, cfg)
+	if err == nil && cfg.SSH != nil {
+		if remote, ok := providerInstance.(RemoteProvider); !ok || !remote.IsRemote() {
+			providerInstance.Destroy()
+			return nil, fmt.Errorf("%w: %v", ErrSSHUnsupported, cfg.Type)
+		}
+	}
+	return providerInstance, err
+}
+
+// Context with custom field parsers shared by providers
+func makeConfigMapperContext() (configmapper.Context, error) {
+	mapperCtx := configmapper.MakeContext()
+	err := mapperCtx.RegisterCustomFieldParser("relative_absolute_value", func(s string) (reflect.Value, error) {
+		value, err := utils.RelativeAbsoluteValueFromString(s)
+		if err != nil {
+			return reflect.Value{}, err
+		} else {
+			return reflect.ValueOf(value), nil
+		}
+	})
+	return mapperCtx, err
 }
@@ -4,37 +4,76 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/containerapi"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
 )
 
+const containerEventsRetryDelay = 10 * time.Second
+
+var containerEventFilters = containerapi.EventFilters{
+	"type":  {"container", "image"},
+	"event": {"start", "die", "oom", "health_status", "restart", "pull", "tag"},
+}
+
 type ContainerClient interface {
 	ContainerList(ctx context.Context) ([]containerapi.Container, error)
 	ContainerInspect(ctx context.Context, containerId string) (containerapi.ContainerInspect, error)
+	Events(ctx context.Context, filters containerapi.EventFilters, handler func(containerapi.Event)) error
 }
 
 type ProviderContainer struct {
-	client ContainerClient
+	client    ContainerClient
+	sshClient *sshclient.Client
+	Socket    string `json:"socket" default:"/var/run/docker.sock"` // docker/podman API socket
+	Events    bool   `json:"events" default:"false"`                // subscribe to engine events to push state changes immediately
 
+	mutex                 sync.Mutex // update task and event handler may run concurrently
 	containerRestartCount map[string]int
 	containerState        map[string]string
+	containerHealth       map[string]string
 
 	knownContainerList []containerapi.Container
 }
 
-func NewProviderContainer() (Provider, error) {
-	cli, err := containerapi.NewClient()
+func NewProviderContainer(params map[string]any, sshCfg *sshclient.Config) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderContainer](params)
 	if err != nil {
 		return nil, err
 	}
-	return &ProviderContainer{
-		client:                cli,
-		containerRestartCount: make(map[string]int),
-		containerState:        make(map[string]string),
-	}, nil
+
+	var dialer net.Dialer
+	dialContext := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", cfg.Socket)
+	}
+	if sshCfg != nil {
+		cfg.sshClient, err = sshclient.NewClient(*sshCfg)
+		if err != nil {
+			return nil, err
+		}
+		// Forward the remote socket
+		dialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return cfg.sshClient.DialContext(ctx, "unix", cfg.Socket)
+		}
+	}
+
+	cli, err := containerapi.NewClientWithDialer(dialContext)
+	if err != nil {
+		return nil, err
+	}
+	cfg.client = cli
+	cfg.containerRestartCount = make(map[string]int)
+	cfg.containerState = make(map[string]string)
+	cfg.containerHealth = make(map[string]string)
+	return &cfg, nil
 }
 
 func containerPrettyName(ctr containerapi.Container) string {
@@ -54,8 +93,13 @@ func (containerProvider *ProviderContainer) updateStateMetric(resultWrapper *Scr
 func (containerProvider *ProviderContainer) removeStateMetric(resultWrapper *ScrapeResultWrapper, ctr containerapi.Container) {
 	metric := resultWrapper.Metric("container_state_"+ctr.ID, containerPrettyName(ctr)+" state")
 	metric.PushRemoved("container removed")
+	if _, exists := containerProvider.containerHealth[ctr.ID]; exists {
+		metricHealth := resultWrapper.Metric("container_health_"+ctr.ID, containerPrettyName(ctr)+" health")
+		metricHealth.PushRemoved("container removed")
+	}
 	delete(containerProvider.containerState, ctr.ID)
 	delete(containerProvider.containerRestartCount, ctr.ID)
+	delete(containerProvider.containerHealth, ctr.ID)
 }
 
 func (containerProvider *ProviderContainer) updateImageMetric(resultWrapper *ScrapeResultWrapper, storage storage.Storager, ctr containerapi.Container) {
@@ -81,65 +125,164 @@ func (containerProvider *ProviderContainer) updateRestartCountMetric(resultWrapp
 	}
 }
 
+func (containerProvider *ProviderContainer) update(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) {
+	containers, err := containerProvider.client.ContainerList(ctx)
+
+	metricListContainer := resultWrapper.Metric("general_list_container", "container provider")
+	if err != nil {
+		metricListContainer.PushFailure("failed to list containers: %v", err)
+		return
+	} else {
+		metricListContainer.PushOK("")
+	}
+
+	// For O(1) lookup
+	currentContainersMap := make(map[string]struct{}, len(containers))
+
+	var inspectErrorList []error
+
+	for _, ctr := range containers {
+		currentContainersMap[ctr.ID] = struct{}{}
+		containerProvider.updateStateMetric(resultWrapper, ctr)
+		containerProvider.updateImageMetric(resultWrapper, storage, ctr)
+
+		inspect, err := containerProvider.client.ContainerInspect(ctx, ctr.ID)
+		if err == nil {
+			containerProvider.updateRestartCountMetric(resultWrapper, ctr, inspect)
+			if inspect.State.Health != nil {
+				// reconcile health status (event missed, or already unhealthy at startup)
+				containerProvider.updateHealthMetric(resultWrapper, ctr, inspect.State.Health.Status)
+			}
+		} else if errors.Is(err, containerapi.ErrContainerNotFound) {
+			logging.Info("Container %v does not exist anymore, ignoring", ctr.ID)
+		} else {
+			inspectErrorList = append(inspectErrorList, err)
+		}
+	}
+
+	metricInspectContainer := resultWrapper.Metric("general_inspect_container", "container provider")
+	if len(inspectErrorList) > 0 {
+		metricInspectContainer.PushFailure("unable to inspect containers: %v", inspectErrorList)
+	} else {
+		metricInspectContainer.PushOK("")
+	}
+
+	// Clean up missing containers
+	for _, knownContainer := range containerProvider.knownContainerList {
+		if _, exists := currentContainersMap[knownContainer.ID]; !exists {
+			containerProvider.removeStateMetric(resultWrapper, knownContainer)
+		}
+	}
+	containerProvider.knownContainerList = containers
+}
+
 func (containerProvider *ProviderContainer) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
 	return UpdateTaskList{
 		func() {
-			containers, err := containerProvider.client.ContainerList(ctx)
-
-			metricListContainer := resultWrapper.Metric("general_list_container", "container provider")
-			if err != nil {
-				metricListContainer.PushFailure("failed to list containers: %v", err)
-				return
-			} else {
-				metricListContainer.PushOK("")
-			}
+			containerProvider.mutex.Lock()
+			defer containerProvider.mutex.Unlock()
+			containerProvider.update(ctx, resultWrapper, storage)
+		},
+	}
+}
 
-			// For O(1) lookup
-			currentContainersMap := make(map[string]struct{}, len(containers))
+// Lookup a known container, or build a minimal description from event attributes.
+func (containerProvider *ProviderContainer) findContainer(event containerapi.Event) containerapi.Container {
+	for _, ctr := range containerProvider.knownContainerList {
+		if ctr.ID == event.Actor.ID {
+			return ctr
+		}
+	}
+	return containerapi.Container{
+		ID:    event.Actor.ID,
+		Names: []string{event.Actor.Attributes["name"]},
+		Image: event.Actor.Attributes["image"],
+	}
+}
 
-			var inspectErrorList []error
+func (containerProvider *ProviderContainer) updateHealthMetric(resultWrapper *ScrapeResultWrapper, ctr containerapi.Container, health string) {
+	metric := resultWrapper.Metric("container_health_"+ctr.ID, containerPrettyName(ctr)+" health")
+	containerProvider.containerHealth[ctr.ID] = health
+	switch health {
+	case "unhealthy":
+		metric.PushFailure("container is unhealthy")
+	case "healthy":
+		metric.PushOK("")
+	}
+}
 
-			for _, ctr := range containers {
-				currentContainersMap[ctr.ID] = struct{}{}
-				containerProvider.updateStateMetric(resultWrapper, ctr)
-				containerProvider.updateImageMetric(resultWrapper, storage, ctr)
+func (containerProvider *ProviderContainer) handleEvent(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager, event containerapi.Event) {
+	containerProvider.mutex.Lock()
+	defer containerProvider.mutex.Unlock()
 
-				inspect, err := containerProvider.client.ContainerInspect(ctx, ctr.ID)
-				if err == nil {
-					containerProvider.updateRestartCountMetric(resultWrapper, ctr, inspect)
-				} else if errors.Is(err, containerapi.ErrContainerNotFound) {
-					logging.Info("Container %v does not exist anymore, ignoring", ctr.ID)
-				} else {
-					inspectErrorList = append(inspectErrorList, err)
-				}
-			}
+	logging.Debug("Container event: %v %v (%v)", event.Type, event.Action, event.Actor.ID)
 
-			metricInspectContainer := resultWrapper.Metric("general_inspect_container", "container provider")
-			if len(inspectErrorList) > 0 {
-				metricInspectContainer.PushFailure("unable to inspect containers: %v", inspectErrorList)
-			} else {
-				metricInspectContainer.PushOK("")
-			}
+	if event.Type == "image" {
+		// image pull/tag: containers may have been recreated with a new image
+		containerProvider.update(ctx, resultWrapper, storage)
+		return
+	}
 
-			// Clean up missing containers
-			for _, knownContainer := range containerProvider.knownContainerList {
-				if _, exists := currentContainersMap[knownContainer.ID]; !exists {
-					containerProvider.removeStateMetric(resultWrapper, knownContainer)
-				}
-			}
-			containerProvider.knownContainerList = containers
-		},
+	ctr := containerProvider.findContainer(event)
+	switch action, _, _ := strings.Cut(event.Action, ":"); action {
+	case "start":
+		containerProvider.update(ctx, resultWrapper, storage)
+	case "die":
+		containerProvider.containerState[ctr.ID] = "exited"
+		metric := resultWrapper.Metric("container_state_"+ctr.ID, containerPrettyName(ctr)+" state")
+		metric.PushFailure("container died (exit code %v)", event.Actor.Attributes["exitCode"])
+	case "oom":
+		metric := resultWrapper.Metric("container_oom_"+ctr.ID, containerPrettyName(ctr)+" out of memory")
+		metric.PushMessage("container ran out of memory")
+	case "restart":
+		metric := resultWrapper.Metric("container_restarted_"+ctr.ID, containerPrettyName(ctr)+" restart")
+		metric.PushFailure("container restarted")
+	case "health_status":
+		// docker: "health_status: unhealthy", podman: "health_status" with a health_status attribute
+		health, found := strings.CutPrefix(event.Action, "health_status:")
+		if !found {
+			health = event.Actor.Attributes["health_status"]
+		}
+		containerProvider.updateHealthMetric(resultWrapper, ctr, strings.TrimSpace(health))
+	}
+}
+
+func (containerProvider *ProviderContainer) Watch(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) {
+	if !containerProvider.Events {
+		return
+	}
+
+	for {
+		err := containerProvider.client.Events(ctx, containerEventFilters, func(event containerapi.Event) {
+			containerProvider.handleEvent(ctx, resultWrapper, storage, event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logging.Warning("container event stream interrupted, retrying in %v (%v)", containerEventsRetryDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(containerEventsRetryDelay):
+		}
 	}
 }
 
 func (containerProvider *ProviderContainer) MultipleInstanceAllowed() bool {
-	return false
+	return containerProvider.sshClient != nil
 }
 
-func (*ProviderContainer) Destroy() {
+func (containerProvider *ProviderContainer) IsRemote() bool {
+	return containerProvider.sshClient != nil
+}
+
+func (containerProvider *ProviderContainer) Destroy() {
+	if containerProvider.sshClient != nil {
+		utils.SafeClose(containerProvider.sshClient)
+	}
 }
 func init() {
 	RegisterProvider("container", func(ctx context.Context, cfg Config) (Provider, error) {
-		return NewProviderContainer()
+		return NewProviderContainer(cfg.Params, cfg.SSH)
 	})
 }
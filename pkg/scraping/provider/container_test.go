@@ -13,6 +13,7 @@ import (
 type mockContainerClient struct {
 	ListFunc    func(ctx context.Context) ([]containerapi.Container, error)
 	InspectFunc func(ctx context.Context, containerId string) (containerapi.ContainerInspect, error)
+	EventsFunc  func(ctx context.Context, filters containerapi.EventFilters, handler func(containerapi.Event)) error
 }
 
 func (m *mockContainerClient) ContainerList(ctx context.Context) ([]containerapi.Container, error) {
@@ -29,6 +30,14 @@ func (m *mockContainerClient) ContainerInspect(ctx context.Context, containerId
 	return containerapi.ContainerInspect{}, nil
 }
 
+func (m *mockContainerClient) Events(ctx context.Context, filters containerapi.EventFilters, handler func(containerapi.Event)) error {
+	if m.EventsFunc != nil {
+		return m.EventsFunc(ctx, filters, handler)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
 func TestContainerDisappearance(t *testing.T) {
 	// Setup
 	mockClient := &mockContainerClient{}
@@ -135,3 +144,133 @@ func TestContainerImageUpdate(t *testing.T) {
 	msg := waitForMessage(t, resultChan, "test_container_image_update_container123")
 	assert.Equal(t, "image was updated", msg.Description)
 }
+
+func TestContainerEvents(t *testing.T) {
+	mockClient := &mockContainerClient{}
+	provider := &ProviderContainer{
+		client:                mockClient,
+		Events:                true,
+		containerRestartCount: make(map[string]int),
+		containerState:        make(map[string]string),
+		containerHealth:       make(map[string]string),
+	}
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("test", resultChan)
+	memStorage := storage.NewMemoryStorage()
+
+	mockClient.ListFunc = func(ctx context.Context) ([]containerapi.Container, error) {
+		return []containerapi.Container{
+			{
+				ID:      "container123",
+				Names:   []string{"my-app"},
+				Image:   "my-image:latest",
+				ImageID: "sha256:1111",
+				State:   "running",
+			},
+		}, nil
+	}
+
+	taskList := provider.GetUpdateTaskList(context.Background(), &wrapper, memStorage)
+	taskList[0]()
+	drainChannel(resultChan)
+
+	events := make(chan containerapi.Event)
+	mockClient.EventsFunc = func(ctx context.Context, filters containerapi.EventFilters, handler func(containerapi.Event)) error {
+		for {
+			select {
+			case event := <-events:
+				handler(event)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		provider.Watch(ctx, &wrapper, memStorage)
+	}()
+
+	// 1. Container dies: pushed immediately
+	events <- containerapi.Event{Type: "container", Action: "die", Actor: containerapi.EventActor{ID: "container123", Attributes: map[string]string{"exitCode": "137"}}}
+	stateMetric := waitForMetricState(t, resultChan, "test_container_state_container123")
+	assert.Equal(t, Unhealthy, stateMetric.Status)
+	assert.Equal(t, "my-app@container (my-image:latest) state", stateMetric.Name)
+	assert.Equal(t, "container died (exit code 137)", stateMetric.Description)
+
+	// 2. Out of memory
+	events <- containerapi.Event{Type: "container", Action: "oom", Actor: containerapi.EventActor{ID: "container123"}}
+	msg := waitForMessage(t, resultChan, "test_container_oom_container123")
+	assert.Equal(t, "container ran out of memory", msg.Description)
+
+	// 3. Health status (docker and podman flavors)
+	events <- containerapi.Event{Type: "container", Action: "health_status: unhealthy", Actor: containerapi.EventActor{ID: "container123"}}
+	healthMetric := waitForMetricState(t, resultChan, "test_container_health_container123")
+	assert.Equal(t, Unhealthy, healthMetric.Status)
+
+	events <- containerapi.Event{Type: "container", Action: "health_status", Actor: containerapi.EventActor{ID: "container123", Attributes: map[string]string{"health_status": "healthy"}}}
+	healthMetric = waitForMetricState(t, resultChan, "test_container_health_container123")
+	assert.Equal(t, Healthy, healthMetric.Status)
+
+	// 4. Restart
+	events <- containerapi.Event{Type: "container", Action: "restart", Actor: containerapi.EventActor{ID: "container123"}}
+	restartMetric := waitForMetricState(t, resultChan, "test_container_restarted_container123")
+	assert.Equal(t, Unhealthy, restartMetric.Status)
+	assert.Equal(t, "my-app@container (my-image:latest) restart", restartMetric.Name)
+	assert.Equal(t, "container restarted", restartMetric.Description)
+
+	// 5. Container starts again: reconciliation pass
+	events <- containerapi.Event{Type: "container", Action: "start", Actor: containerapi.EventActor{ID: "container123"}}
+	stateMetric = waitForMetricState(t, resultChan, "test_container_state_container123")
+	assert.Equal(t, Healthy, stateMetric.Status)
+
+	cancel()
+	<-done
+}
+
+func TestContainerHealthReconciliation(t *testing.T) {
+	mockClient := &mockContainerClient{}
+	provider := &ProviderContainer{
+		client:                mockClient,
+		containerRestartCount: make(map[string]int),
+		containerState:        make(map[string]string),
+		containerHealth:       make(map[string]string),
+	}
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("test", resultChan)
+	memStorage := storage.NewMemoryStorage()
+
+	mockClient.ListFunc = func(ctx context.Context) ([]containerapi.Container, error) {
+		return []containerapi.Container{
+			{ID: "container123", Names: []string{"my-app"}, Image: "my-image:latest", State: "running"},
+			{ID: "container456", Names: []string{"no-healthcheck"}, Image: "my-image:latest", State: "running"},
+		}, nil
+	}
+	health := "unhealthy"
+	mockClient.InspectFunc = func(ctx context.Context, id string) (containerapi.ContainerInspect, error) {
+		if id == "container456" {
+			return containerapi.ContainerInspect{}, nil
+		}
+		return containerapi.ContainerInspect{State: containerapi.ContainerState{Health: &containerapi.ContainerHealth{Status: health}}}, nil
+	}
+
+	// 1. Already unhealthy at startup (no event received)
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memStorage)
+	healthMetric := waitForMetricState(t, resultChan, "test_container_health_container123")
+	assert.Equal(t, Unhealthy, healthMetric.Status)
+	assert.Equal(t, "my-app@container (my-image:latest) health", healthMetric.Name)
+	drainChannel(resultChan)
+
+	// 2. Health event missed: next poll reconciles the status
+	health = "healthy"
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memStorage)
+	healthMetric = waitForMetricState(t, resultChan, "test_container_health_container123")
+	assert.Equal(t, Healthy, healthMetric.Status)
+
+	_, exists := provider.containerHealth["container456"]
+	assert.Assert(t, !exists, "containers without healthcheck have no health metric")
+}
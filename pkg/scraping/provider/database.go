@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+type ProviderDatabase struct {
+	driver            DatabaseDriver
+	Driver            string                      `json:"driver"`                                                       // postgres, mysql or redis
+	DSN               string                      `json:"dsn"`                                                          // credentials can be read from environment ($VAR)
+	Query             string                      `json:"query" default:""`                                             // default: SELECT 1 or PING
+	MaxReplicationLag customtypes.Duration        `json:"max_replication_lag" default:"0s"`                             // 0 means disabled
+	MaxConnections    utils.RelativeAbsoluteValue `json:"max_connections" default:"0" custom:"relative_absolute_value"` // relative to server limit, 0 means disabled
+	Timeout           customtypes.Duration        `json:"timeout" default:"10s"`
+}
+
+func NewProviderDatabase(params map[string]any) (Provider, error) {
+	mapperCtx, err := makeConfigMapperContext()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := configmapper.MapOnStructWithContext[ProviderDatabase](&mapperCtx, params)
+	if err != nil {
+		return nil, err
+	}
+	factory, exists := databaseDriverList[cfg.Driver]
+	if !exists {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownDatabaseDriver, cfg.Driver)
+	}
+	if cfg.Query == "" {
+		cfg.Query = factory.defaultQuery
+	}
+	cfg.driver, err = factory.open(cfg.DSN, cfg.Timeout.AsDuration())
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (provider *ProviderDatabase) update(ctx context.Context, resultWrapper *ScrapeResultWrapper) {
+	ctx, cancel := context.WithTimeout(ctx, provider.Timeout.AsDuration())
+	defer cancel()
+
+	metricProbe := resultWrapper.Metric("database", provider.Driver+" database")
+	if err := provider.driver.Probe(ctx, provider.Query); err != nil {
+		metricProbe.PushFailure("probe failed: %v", err)
+		return
+	}
+	metricProbe.PushOK("")
+
+	if provider.MaxReplicationLag > 0 {
+		metricReplication := resultWrapper.Metric("database_replication", provider.Driver+" replication")
+		lag, replica, err := provider.driver.ReplicationLag(ctx)
+		if err != nil {
+			metricReplication.PushFailure("unable to check replication: %v", err)
+		} else if replica && lag > provider.MaxReplicationLag.AsDuration() {
+			metricReplication.PushFailure("replication lag is %v (max %v)", lag.Round(time.Second), provider.MaxReplicationLag)
+		} else {
+			metricReplication.PushOK("")
+		}
+	}
+
+	if !provider.MaxConnections.IsZero() {
+		metricConnections := resultWrapper.Metric("database_connections", provider.Driver+" connections")
+		current, max, err := provider.driver.Connections(ctx)
+		if err != nil {
+			metricConnections.PushFailure("unable to count connections: %v", err)
+		} else if threshold := provider.MaxConnections.GetValue(max); current > threshold {
+			metricConnections.PushFailure("%v connections (max %v, server limit %v)", current, threshold, max)
+		} else {
+			metricConnections.PushOK("")
+		}
+	}
+}
+
+func (provider *ProviderDatabase) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			provider.update(ctx, resultWrapper)
+		},
+	}
+}
+
+func (*ProviderDatabase) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (provider *ProviderDatabase) Destroy() {
+	utils.SafeClose(provider.driver)
+}
+
+func init() {
+	RegisterProvider("database", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderDatabase(cfg.Params)
+	})
+}
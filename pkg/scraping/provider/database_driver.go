@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/redisclient"
+)
+
+var (
+	ErrUnknownDatabaseDriver = errors.New("unknown database driver")
+	ErrReplicationNotRunning = errors.New("replication is not running")
+)
+
+type DatabaseDriver interface {
+	Probe(ctx context.Context, query string) error
+	// ReplicationLag returns replica lag, replica is false when connected to a primary
+	ReplicationLag(ctx context.Context) (lag time.Duration, replica bool, err error)
+	Connections(ctx context.Context) (current, max uint64, err error)
+	Close() error
+}
+
+type databaseDriverFactory struct {
+	defaultQuery string
+	open         func(dsn string, timeout time.Duration) (DatabaseDriver, error)
+}
+
+var databaseDriverList = map[string]databaseDriverFactory{
+	"postgres": {defaultQuery: "SELECT 1", open: openSQLDatabase(func(db *sql.DB) DatabaseDriver { return &postgresDriver{sqlDatabase{db}} }, "postgres")},
+	"mysql":    {defaultQuery: "SELECT 1", open: openSQLDatabase(func(db *sql.DB) DatabaseDriver { return &mysqlDriver{sqlDatabase{db}} }, "mysql")},
+	"redis":    {defaultQuery: "PING", open: openRedisDatabase},
+}
+
+func openSQLDatabase(makeDriver func(db *sql.DB) DatabaseDriver, driverName string) func(dsn string, timeout time.Duration) (DatabaseDriver, error) {
+	return func(dsn string, timeout time.Duration) (DatabaseDriver, error) {
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			return nil, err
+		}
+		// connect on each scrape, connectivity is part of the check
+		db.SetMaxIdleConns(0)
+		db.SetMaxOpenConns(1)
+		return makeDriver(db), nil
+	}
+}
+
+type sqlDatabase struct {
+	db *sql.DB
+}
+
+func (database *sqlDatabase) Probe(ctx context.Context, query string) error {
+	rows, err := database.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer utils.SafeClose(rows)
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func (database *sqlDatabase) Close() error {
+	return database.db.Close()
+}
+
+type postgresDriver struct {
+	sqlDatabase
+}
+
+func (driver *postgresDriver) ReplicationLag(ctx context.Context) (time.Duration, bool, error) {
+	var replica bool
+	var lagSeconds float64
+	err := driver.db.QueryRowContext(ctx,
+		"SELECT pg_is_in_recovery(), COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)::float8").Scan(&replica, &lagSeconds)
+	return time.Duration(lagSeconds * float64(time.Second)), replica, err
+}
+
+func (driver *postgresDriver) Connections(ctx context.Context) (uint64, uint64, error) {
+	var current, max uint64
+	err := driver.db.QueryRowContext(ctx,
+		"SELECT (SELECT count(*) FROM pg_stat_activity), current_setting('max_connections')::int").Scan(&current, &max)
+	return current, max, err
+}
+
+type mysqlDriver struct {
+	sqlDatabase
+}
+
+// queryColumns runs a query returning at most one row, as a map column => value
+func (driver *mysqlDriver) queryColumns(ctx context.Context, query string) (map[string]sql.NullString, error) {
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.SafeClose(rows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+	result := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		result[column] = values[i]
+	}
+	return result, rows.Err()
+}
+
+func (driver *mysqlDriver) ReplicationLag(ctx context.Context) (time.Duration, bool, error) {
+	// SHOW SLAVE STATUS is required before MySQL 8.0.22
+	status, err := driver.queryColumns(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		status, err = driver.queryColumns(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil || status == nil {
+		return 0, false, err
+	}
+	lag, exists := status["Seconds_Behind_Source"]
+	if !exists {
+		lag = status["Seconds_Behind_Master"]
+	}
+	if !lag.Valid {
+		return 0, true, ErrReplicationNotRunning
+	}
+	lagSeconds, err := strconv.ParseUint(lag.String, 10, 64)
+	return time.Duration(lagSeconds) * time.Second, true, err
+}
+
+func (driver *mysqlDriver) Connections(ctx context.Context) (uint64, uint64, error) {
+	var name string
+	var current, max uint64
+	if err := driver.db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Threads_connected'").Scan(&name, &current); err != nil {
+		return 0, 0, err
+	}
+	err := driver.db.QueryRowContext(ctx, "SELECT @@max_connections").Scan(&max)
+	return current, max, err
+}
+
+type redisDriver struct {
+	client *redisclient.Client
+}
+
+// openRedisDatabase parses a redis url: redis://[[username]:password@]host[:port][/database]
+func openRedisDatabase(dsn string, timeout time.Duration) (DatabaseDriver, error) {
+	redisURL, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if redisURL.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis url scheme '%v'", redisURL.Scheme)
+	}
+	database := 0
+	if path := strings.Trim(redisURL.Path, "/"); path != "" {
+		database, err = strconv.Atoi(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database '%v'", path)
+		}
+	}
+	password, _ := redisURL.User.Password()
+	return &redisDriver{
+		client: redisclient.NewClient(redisURL.Host, redisURL.User.Username(), password, database, timeout),
+	}, nil
+}
+
+func (driver *redisDriver) Probe(ctx context.Context, query string) error {
+	conn, err := driver.client.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer utils.SafeClose(conn)
+	_, err = conn.Do(strings.Fields(query)...)
+	return err
+}
+
+func (driver *redisDriver) ReplicationLag(ctx context.Context) (time.Duration, bool, error) {
+	conn, err := driver.client.Open(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer utils.SafeClose(conn)
+	info, err := conn.Info("replication")
+	if err != nil || info["role"] != "slave" {
+		return 0, false, err
+	}
+	if info["master_link_status"] != "up" {
+		return 0, true, fmt.Errorf("%w: link to master is %v", ErrReplicationNotRunning, info["master_link_status"])
+	}
+	// no lag measurement in redis, use the last interaction with master
+	lagSeconds, err := strconv.ParseUint(info["master_last_io_seconds_ago"], 10, 64)
+	return time.Duration(lagSeconds) * time.Second, true, err
+}
+
+func (driver *redisDriver) Connections(ctx context.Context) (uint64, uint64, error) {
+	conn, err := driver.client.Open(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer utils.SafeClose(conn)
+	info, err := conn.Info("clients")
+	if err != nil {
+		return 0, 0, err
+	}
+	current, err := strconv.ParseUint(info["connected_clients"], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	reply, err := conn.Do("CONFIG", "GET", "maxclients")
+	if err != nil {
+		return 0, 0, err
+	}
+	if items, ok := reply.([]any); ok && len(items) == 2 {
+		if value, ok := items[1].(string); ok {
+			max, err := strconv.ParseUint(value, 10, 64)
+			return current, max, err
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: CONFIG GET maxclients", redisclient.ErrProtocol)
+}
+
+func (driver *redisDriver) Close() error {
+	return nil
+}
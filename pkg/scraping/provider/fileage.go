@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+var ErrInvalidFilePattern = errors.New("invalid file pattern")
+
+type fileMatcher struct {
+	Pattern  string               `json:"pattern"`               // glob pattern (see filepath.Match)
+	MaxAge   customtypes.Duration `json:"max_age" default:"0s"`  // maximum age of the newest file, 0 means disabled
+	MinSize  customtypes.Size     `json:"min_size" default:"0"`  // minimum size of the newest file
+	MinCount uint                 `json:"min_count" default:"1"` // minimum number of matching files
+}
+
+type ProviderFileAge struct {
+	MountPrefix string                 `json:"mountprefix" default:""` // Host root filesytem when running inside a container
+	Files       map[string]fileMatcher `json:"files"`
+}
+
+func NewProviderFileAge(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderFileAge](params)
+	if err != nil {
+		return nil, err
+	}
+	for name, matcher := range cfg.Files {
+		if !utils.IsNameValid(name) {
+			return nil, fmt.Errorf("forbidden characters in file name '%v'", name)
+		}
+		if _, err := filepath.Match(matcher.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: %v (%v)", ErrInvalidFilePattern, matcher.Pattern, err)
+		}
+	}
+	return &cfg, nil
+}
+
+func (provider *ProviderFileAge) checkFiles(resultWrapper *ScrapeResultWrapper, name string, matcher fileMatcher, now time.Time) {
+	metric := resultWrapper.Metric("fileage_"+name, "files "+name)
+
+	paths, err := filepath.Glob(filepath.Join(provider.MountPrefix, matcher.Pattern))
+	if err != nil {
+		metric.PushFailure("unable to list files: %v", err)
+		return
+	}
+
+	var newest os.FileInfo
+	var newestPath string
+	count := uint(0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		count++
+		if newest == nil || info.ModTime().After(newest.ModTime()) {
+			newest = info
+			newestPath = path
+		}
+	}
+
+	problems := []string{}
+	if count < matcher.MinCount {
+		problems = append(problems, fmt.Sprintf("%v matching files (min %v)", count, matcher.MinCount))
+	}
+	if newest != nil {
+		prettyPath := strings.TrimPrefix(newestPath, provider.MountPrefix)
+		if age := now.Sub(newest.ModTime()); matcher.MaxAge > 0 && age > matcher.MaxAge.AsDuration() {
+			problems = append(problems, fmt.Sprintf("newest file %v is %v old (max %v)", prettyPath, age.Round(time.Minute), matcher.MaxAge))
+		}
+		if uint64(newest.Size()) < matcher.MinSize.AsBytes() {
+			problems = append(problems, fmt.Sprintf("newest file %v is too small (%v < %v)", prettyPath, humanize.Bytes(uint64(newest.Size())), matcher.MinSize))
+		}
+	}
+
+	if len(problems) > 0 {
+		metric.PushFailure("%v", strings.Join(problems, ", "))
+	} else {
+		metric.PushOK("")
+	}
+}
+
+func (provider *ProviderFileAge) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			now := time.Now()
+			for name, matcher := range provider.Files {
+				provider.checkFiles(resultWrapper, name, matcher, now)
+			}
+		},
+	}
+}
+
+func (*ProviderFileAge) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderFileAge) Destroy() {
+}
+
+func init() {
+	RegisterProvider("fileage", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderFileAge(cfg.Params)
+	})
+}
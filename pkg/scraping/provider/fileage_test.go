@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+func writeFileWithAge(t *testing.T, path, content string, age time.Duration) {
+	writeFile(t, path, content)
+	mtime := time.Now().Add(-age)
+	assert.NilError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestFileAge(t *testing.T) {
+	prefix := t.TempDir()
+	writeFileWithAge(t, filepath.Join(prefix, "backups/db/2026-01-01.tar.zst"), "0123456789", 50*time.Hour)
+	writeFileWithAge(t, filepath.Join(prefix, "backups/db/2026-01-02.tar.zst"), "0123456789", 2*time.Hour)
+	writeFileWithAge(t, filepath.Join(prefix, "backups/db/notes.txt"), "", time.Minute)
+	writeFileWithAge(t, filepath.Join(prefix, "backups/photos/latest.tar"), "01234", 30*time.Hour)
+
+	params := map[string]any{
+		"mountprefix": prefix,
+		"files": map[string]any{
+			"db":      map[string]any{"pattern": "/backups/db/*.tar.zst", "max_age": "26h", "min_size": "10", "min_count": uint64(2)},
+			"db_full": map[string]any{"pattern": "/backups/db/*.tar.zst", "min_count": uint64(3)},
+			"photos":  map[string]any{"pattern": "/backups/photos/*.tar", "max_age": "26h", "min_size": "1k"},
+			"missing": map[string]any{"pattern": "/backups/missing/*"},
+		},
+	}
+	provider, err := NewProviderFileAge(params)
+	assert.NilError(t, err)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("files", resultChan)
+	taskList := provider.GetUpdateTaskList(context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, 1, len(taskList))
+	taskList[0]()
+
+	results := map[string]MetricState{}
+	for len(resultChan) > 0 {
+		if state, ok := (<-resultChan).(MetricState); ok {
+			results[state.MetricID] = state
+		}
+	}
+
+	assert.Equal(t, Healthy, results["files_fileage_db"].Status)
+	assert.Equal(t, Unhealthy, results["files_fileage_db_full"].Status)
+	assert.Equal(t, "2 matching files (min 3)", results["files_fileage_db_full"].Description)
+	assert.Equal(t, Unhealthy, results["files_fileage_photos"].Status)
+	assert.Equal(t, "newest file /backups/photos/latest.tar is 30h0m0s old (max 26h0m0s), newest file /backups/photos/latest.tar is too small (5 B < 1.0 kB)",
+		results["files_fileage_photos"].Description)
+	assert.Equal(t, Unhealthy, results["files_fileage_missing"].Status)
+	assert.Equal(t, "0 matching files (min 1)", results["files_fileage_missing"].Description)
+
+	// Invalid pattern
+	_, err = NewProviderFileAge(map[string]any{"files": map[string]any{"bad": map[string]any{"pattern": "/backups/["}}})
+	assert.ErrorIs(t, err, ErrInvalidFilePattern)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
 	"slices"
 	"strings"
 	"time"
@@ -15,6 +14,7 @@ import (
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/stats"
 	"github.com/moby/sys/mountinfo"
 	"golang.org/x/sys/unix"
@@ -23,43 +23,37 @@ import (
 var ErrInvalidRateThresholdWindow = errors.New("rate_threshold_window must be greater than or equal to scrape_interval")
 
 type FileSystemClient interface {
-	Statfs(path string, buf *unix.Statfs_t) error
-	GetMounts(filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error)
+	Statfs(ctx context.Context, path string, buf *unix.Statfs_t) error
+	GetMounts(ctx context.Context, filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error)
 }
 
 type defaultFileSystemClient struct{}
 
-func (d *defaultFileSystemClient) Statfs(path string, buf *unix.Statfs_t) error {
+func (d *defaultFileSystemClient) Statfs(_ context.Context, path string, buf *unix.Statfs_t) error {
 	return unix.Statfs(path, buf)
 }
 
-func (d *defaultFileSystemClient) GetMounts(filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error) {
+func (d *defaultFileSystemClient) GetMounts(_ context.Context, filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error) {
 	return mountinfo.GetMounts(filter)
 }
 
 type ProviderFileSystemUsage struct {
 	client                  FileSystemClient
+	sshClient               *sshclient.Client
 	MountPrefix             string                      `json:"mountprefix" default:""` // Host root filesytem when running inside a container
 	FSTypeWhitelist         []string                    `json:"fstypes" default:"[ext4, btrfs]"`
 	MountPointBlacklist     []string                    `json:"mountpoint_blacklist" default:"[]"`
 	MountPointWhitelist     []string                    `json:"mountpoint_whitelist" default:"[]"`
 	SpaceRemainingThreshold utils.RelativeAbsoluteValue `json:"threshold" default:"20%" custom:"relative_absolute_value"`
+	SpaceWarningThreshold   utils.RelativeAbsoluteValue `json:"warning_threshold" default:"0" custom:"relative_absolute_value"` // 0 means disabled
 	RateThreshold           utils.RelativeAbsoluteValue `json:"rate_threshold" default:"1g" custom:"relative_absolute_value"`
 	RateThresholdWindow     customtypes.Duration        `json:"rate_threshold_window" default:"5m"`
 
 	mountPointStats map[string]*stats.WindowCollector[uint64]
 }
 
-func NewProviderFileSystemUsage(params map[string]any, scrapeInterval time.Duration) (Provider, error) {
-	mapperCtx := configmapper.MakeContext()
-	err := mapperCtx.RegisterCustomFieldParser("relative_absolute_value", func(s string) (reflect.Value, error) {
-		value, err := utils.RelativeAbsoluteValueFromString(s)
-		if err != nil {
-			return reflect.Value{}, err
-		} else {
-			return reflect.ValueOf(value), nil
-		}
-	})
+func NewProviderFileSystemUsage(params map[string]any, scrapeInterval time.Duration, sshCfg *sshclient.Config) (Provider, error) {
+	mapperCtx, err := makeConfigMapperContext()
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +62,15 @@ func NewProviderFileSystemUsage(params map[string]any, scrapeInterval time.Durat
 	if err != nil {
 		return nil, err
 	}
-	cfg.client = &defaultFileSystemClient{}
+	if sshCfg != nil {
+		cfg.sshClient, err = sshclient.NewClient(*sshCfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.client = &remoteFileSystemClient{runner: cfg.sshClient, timeout: sshCfg.Timeout.AsDuration()}
+	} else {
+		cfg.client = &defaultFileSystemClient{}
+	}
 	cfg.mountPointStats = make(map[string]*stats.WindowCollector[uint64])
 	if cfg.RateThresholdWindow.AsDuration() < scrapeInterval {
 		return nil, fmt.Errorf("%w: (%v < %v)", ErrInvalidRateThresholdWindow, cfg.RateThresholdWindow, scrapeInterval)
@@ -108,10 +110,10 @@ func (provider *ProviderFileSystemUsage) updateSpaceIncreaseStats(metric MetricW
 	}
 }
 
-func (provider *ProviderFileSystemUsage) checkMountPoint(resultWrapper *ScrapeResultWrapper, mountPoint string) {
+func (provider *ProviderFileSystemUsage) checkMountPoint(ctx context.Context, resultWrapper *ScrapeResultWrapper, mountPoint string) {
 	var stat unix.Statfs_t
 
-	err := provider.client.Statfs(mountPoint, &stat)
+	err := provider.client.Statfs(ctx, mountPoint, &stat)
 
 	prettyMountpoint := strings.TrimPrefix(mountPoint, provider.MountPrefix)
 	if !strings.HasPrefix(prettyMountpoint, "/") {
@@ -130,19 +132,21 @@ func (provider *ProviderFileSystemUsage) checkMountPoint(resultWrapper *ScrapeRe
 
 		if remainingSpace < provider.SpaceRemainingThreshold.GetValue(totalSpace) {
 			metric.PushFailure("low space remaining (%v%% / %v)", 100*remainingSpace/totalSpace, humanize.Bytes(remainingSpace))
+		} else if remainingSpace < provider.SpaceWarningThreshold.GetValue(totalSpace) {
+			metric.PushWarning("low space remaining (%v%% / %v)", 100*remainingSpace/totalSpace, humanize.Bytes(remainingSpace))
 		} else {
 			metric.PushOK("")
 		}
 	}
 }
 
-func (provider *ProviderFileSystemUsage) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+func (provider *ProviderFileSystemUsage) discoverMountPoints(ctx context.Context) ([]string, error) {
 	mountpoints := []string{}
 
 	if len(provider.MountPointWhitelist) > 0 {
 		mountpoints = provider.MountPointWhitelist
 	} else {
-		allMountPoints, err := provider.client.GetMounts(func(info *mountinfo.Info) (skip, stop bool) {
+		allMountPoints, err := provider.client.GetMounts(ctx, func(info *mountinfo.Info) (skip, stop bool) {
 			return !slices.Contains(provider.FSTypeWhitelist, info.FSType) || slices.Contains(provider.MountPointBlacklist, info.Mountpoint), false
 		})
 
@@ -164,17 +168,38 @@ func (provider *ProviderFileSystemUsage) GetUpdateTaskList(ctx context.Context,
 		}
 
 		if err != nil {
-			logging.Fatal("Unable to list mountpoints: %v", err)
+			return nil, err
 		}
 	}
 	for _, v := range mountpoints {
 		logging.Info("Monitoring available disk space on %v", v)
 	}
+	return mountpoints, nil
+}
+
+func (provider *ProviderFileSystemUsage) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	mountpoints, err := provider.discoverMountPoints(ctx)
+	if err != nil {
+		if !provider.IsRemote() {
+			logging.Fatal("Unable to list mountpoints: %v", err)
+		}
+		// Remote host may be temporarily unreachable, retry on next scrape
+		logging.Warning("Unable to list mountpoints: %v", err)
+	}
 
 	return UpdateTaskList{
 		func() {
+			if mountpoints == nil {
+				metricListMountPoints := resultWrapper.Metric("list_mountpoints", "list mountpoints")
+				mountpoints, err = provider.discoverMountPoints(ctx)
+				if err != nil {
+					metricListMountPoints.PushFailure("failed to list mountpoints: %v", err)
+					return
+				}
+				metricListMountPoints.PushOK("")
+			}
 			for _, mountpoint := range mountpoints {
-				provider.checkMountPoint(resultWrapper, mountpoint)
+				provider.checkMountPoint(ctx, resultWrapper, mountpoint)
 			}
 		},
 	}
@@ -185,11 +210,18 @@ func (*ProviderFileSystemUsage) MultipleInstanceAllowed() bool {
 	return true
 }
 
-func (*ProviderFileSystemUsage) Destroy() {
+func (provider *ProviderFileSystemUsage) IsRemote() bool {
+	return provider.sshClient != nil
+}
+
+func (provider *ProviderFileSystemUsage) Destroy() {
+	if provider.sshClient != nil {
+		utils.SafeClose(provider.sshClient)
+	}
 }
 
 func init() {
 	RegisterProvider("filesystemusage", func(ctx context.Context, cfg Config) (Provider, error) {
-		return NewProviderFileSystemUsage(cfg.Params, cfg.ScrapeInterval.AsDuration())
+		return NewProviderFileSystemUsage(cfg.Params, cfg.ScrapeInterval.AsDuration(), cfg.SSH)
 	})
 }
@@ -19,14 +19,14 @@ type mockFileSystemClient struct {
 	GetMountsFunc func(filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error)
 }
 
-func (m *mockFileSystemClient) Statfs(path string, buf *unix.Statfs_t) error {
+func (m *mockFileSystemClient) Statfs(_ context.Context, path string, buf *unix.Statfs_t) error {
 	if m.StatfsFunc != nil {
 		return m.StatfsFunc(path, buf)
 	}
 	return nil
 }
 
-func (m *mockFileSystemClient) GetMounts(filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error) {
+func (m *mockFileSystemClient) GetMounts(_ context.Context, filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error) {
 	if m.GetMountsFunc != nil {
 		return m.GetMountsFunc(filter)
 	}
@@ -82,3 +82,48 @@ func TestFileSystemLowSpace(t *testing.T) {
 	assert.Equal(t, Unhealthy, val.Status, "Should be unhealthy due to low space")
 	assert.Assert(t, val.Description == "low space remaining (1% / 41 kB)")
 }
+
+func TestFileSystemWarningSpace(t *testing.T) {
+	mockClient := &mockFileSystemClient{}
+
+	thresh, err := utils.RelativeAbsoluteValueFromString("5%")
+	assert.NilError(t, err)
+	warningThresh, err := utils.RelativeAbsoluteValueFromString("20%")
+	assert.NilError(t, err)
+	rateThresh, err := utils.RelativeAbsoluteValueFromString("1g")
+	assert.NilError(t, err)
+
+	provider := &ProviderFileSystemUsage{
+		client:                  mockClient,
+		MountPointWhitelist:     []string{"/"},
+		SpaceRemainingThreshold: thresh,
+		SpaceWarningThreshold:   warningThresh,
+		RateThreshold:           rateThresh,
+		RateThresholdWindow:     customtypes.Duration(5 * time.Minute),
+		mountPointStats:         make(map[string]*stats.WindowCollector[uint64]),
+	}
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("fs", resultChan)
+
+	available := uint64(100) // 10%
+	mockClient.StatfsFunc = func(path string, buf *unix.Statfs_t) error {
+		buf.Bsize = 4096
+		buf.Blocks = 1000
+		buf.Bavail = available
+		return nil
+	}
+
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	val := waitForMetricState(t, resultChan, "fs_filesystemusage_/")
+	assert.Equal(t, Warning, val.Status)
+	assert.Equal(t, "low space remaining (10% / 410 kB)", val.Description)
+
+	available = 30 // 3%
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, Unhealthy, waitForMetricState(t, resultChan, "fs_filesystemusage_/").Status)
+
+	available = 500 // 50%
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "fs_filesystemusage_/").Status)
+}
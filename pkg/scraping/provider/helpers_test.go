@@ -32,6 +32,21 @@ func waitForType[T any](t *testing.T, ch chan any, expectedID string, getID func
 	}
 }
 
+// Last state of each metric pushed so far (without waiting, tasks run synchronously)
+func collectMetricStates(ch chan any) map[string]MetricState {
+	states := map[string]MetricState{}
+	for {
+		select {
+		case msg := <-ch:
+			if state, ok := msg.(MetricState); ok {
+				states[state.MetricID] = state
+			}
+		default:
+			return states
+		}
+	}
+}
+
 func drainChannel(ch chan any) {
 	for {
 		select {
@@ -0,0 +1,277 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/imapclient"
+)
+
+const (
+	mailRoundTripTagKey      = "round_trip_tag"
+	mailRoundTripSentKey     = "round_trip_sent"
+	mailRoundTripSubjectBase = "[minimal-server-monitoring] round trip "
+)
+
+var (
+	ErrInvalidMailConfig = errors.New("invalid mail configuration")
+	ErrSMTPNoSTARTTLS    = errors.New("server does not support STARTTLS")
+)
+
+type mailSMTPConfig struct {
+	Host     string `json:"host"`
+	Port     uint16 `json:"port" default:"587"`
+	Security string `json:"security" default:"starttls"` // tls, starttls or none
+	Username string `json:"username" default:""`         // empty means no authentication
+	Password string `json:"password" default:""`
+}
+
+type mailIMAPConfig struct {
+	Host     string `json:"host"`
+	Port     uint16 `json:"port" default:"993"`
+	Security string `json:"security" default:"tls"` // tls, starttls or none
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Mailbox  string `json:"mailbox" default:"INBOX"`
+}
+
+type mailRoundTripConfig struct {
+	From    string               `json:"from"`
+	To      string               `json:"to"`
+	Timeout customtypes.Duration `json:"timeout" default:"5m"` // max delay between sending and finding the message
+}
+
+type ProviderMail struct {
+	SMTP               *mailSMTPConfig      `json:"smtp"`
+	IMAP               *mailIMAPConfig      `json:"imap"`
+	RoundTrip          *mailRoundTripConfig `json:"round_trip"` // requires both smtp and imap
+	Timeout            customtypes.Duration `json:"timeout" default:"10s"`
+	InsecureSkipVerify bool                 `json:"insecure_skip_verify" default:"false"`
+}
+
+func NewProviderMail(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderMail](params)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SMTP == nil && cfg.IMAP == nil {
+		return nil, fmt.Errorf("%w: smtp or imap is required", ErrInvalidMailConfig)
+	}
+	if cfg.SMTP != nil {
+		if err := imapclient.Security(cfg.SMTP.Security).Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.IMAP != nil {
+		if err := imapclient.Security(cfg.IMAP.Security).Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RoundTrip != nil && (cfg.SMTP == nil || cfg.IMAP == nil) {
+		return nil, fmt.Errorf("%w: round_trip requires both smtp and imap", ErrInvalidMailConfig)
+	}
+	return &cfg, nil
+}
+
+func (provider *ProviderMail) tlsConfig(host string) *tls.Config {
+	return &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: provider.InsecureSkipVerify, //nolint:gosec // opt-in for self-signed certificates
+	}
+}
+
+// sendSMTP connects to the smtp server and runs a session
+func (provider *ProviderMail) sendSMTP(ctx context.Context, subject string) error {
+	config := provider.SMTP
+	address := net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port)))
+	dialer := &net.Dialer{Timeout: provider.Timeout.AsDuration()}
+
+	var conn net.Conn
+	var err error
+	if imapclient.Security(config.Security) == imapclient.SecurityTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: provider.tlsConfig(config.Host)}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(provider.Timeout.AsDuration()))
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if err := provider.smtpSession(client, subject); err != nil {
+		_ = client.Close() // may already be closed by a failed authentication
+		return err
+	}
+	return client.Quit()
+}
+
+// smtpSession upgrades to TLS if needed, authenticates, then sends a round trip message if subject is set
+func (provider *ProviderMail) smtpSession(client *smtp.Client, subject string) error {
+	config := provider.SMTP
+
+	if imapclient.Security(config.Security) == imapclient.SecuritySTARTTLS {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return ErrSMTPNoSTARTTLS
+		}
+		if err := client.StartTLS(provider.tlsConfig(config.Host)); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if subject != "" {
+		if err := client.Mail(provider.RoundTrip.From); err != nil {
+			return err
+		}
+		if err := client.Rcpt(provider.RoundTrip.To); err != nil {
+			return err
+		}
+		writer, err := client.Data()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(writer, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\nThis message was sent by minimal-server-monitoring to check mail delivery.\r\n",
+			provider.RoundTrip.From, provider.RoundTrip.To, subject, time.Now().Format(time.RFC1123Z))
+		if err != nil {
+			_ = writer.Close()
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (provider *ProviderMail) openIMAP(ctx context.Context) (*imapclient.Client, error) {
+	config := provider.IMAP
+	address := net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port)))
+	client, err := imapclient.Dial(ctx, address, imapclient.Security(config.Security), provider.tlsConfig(config.Host), provider.Timeout.AsDuration())
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Login(config.Username, config.Password); err != nil {
+		utils.SafeClose(client)
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	if err := client.Select(config.Mailbox); err != nil {
+		utils.SafeClose(client)
+		return nil, err
+	}
+	return client, nil
+}
+
+// checkRoundTrip looks for the pending message, if any (failure is only pushed once timeout is reached)
+func (provider *ProviderMail) checkRoundTrip(client *imapclient.Client, resultWrapper *ScrapeResultWrapper, storage storage.Storager, now time.Time) {
+	tag, pending := storage.Get(mailRoundTripTagKey)
+	if !pending {
+		return
+	}
+	metric := resultWrapper.Metric("mail_round_trip", "mail round trip")
+	sent, _ := loadTime(storage, mailRoundTripSentKey)
+
+	ids, err := client.Search("SUBJECT " + imapclient.Quote(mailRoundTripSubjectBase+tag))
+	if err != nil {
+		metric.PushFailure("unable to search mailbox: %v", err)
+		return
+	}
+	if len(ids) > 0 {
+		if err := client.Delete(ids); err != nil {
+			metric.PushFailure("unable to delete round trip message: %v", err)
+		} else {
+			metric.PushOK("")
+		}
+	} else if now.Sub(sent) > provider.RoundTrip.Timeout.AsDuration() {
+		metric.PushFailure("message sent at %v not received after %v", sent.Format(time.DateTime), provider.RoundTrip.Timeout)
+	} else {
+		return
+	}
+	storage.Remove(mailRoundTripTagKey)
+	storage.Remove(mailRoundTripSentKey)
+}
+
+func (provider *ProviderMail) update(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) {
+	now := time.Now()
+
+	if provider.IMAP != nil {
+		metricIMAP := resultWrapper.Metric("mail_imap", "imap ["+provider.IMAP.Host+"]")
+		client, err := provider.openIMAP(ctx)
+		if err != nil {
+			metricIMAP.PushFailure("unable to connect: %v", err)
+		} else {
+			metricIMAP.PushOK("")
+			if provider.RoundTrip != nil {
+				provider.checkRoundTrip(client, resultWrapper, storage, now)
+			}
+			_ = client.Logout()
+			utils.SafeClose(client)
+		}
+	}
+
+	if provider.SMTP != nil {
+		tag := ""
+		if _, pending := storage.Get(mailRoundTripTagKey); provider.RoundTrip != nil && !pending {
+			suffix := make([]byte, 8)
+			_, _ = rand.Read(suffix)
+			tag = hex.EncodeToString(suffix)
+		}
+
+		metricSMTP := resultWrapper.Metric("mail_smtp", "smtp ["+provider.SMTP.Host+"]")
+		subject := ""
+		if tag != "" {
+			subject = mailRoundTripSubjectBase + tag
+		}
+		if err := provider.sendSMTP(ctx, subject); err != nil {
+			metricSMTP.PushFailure("unable to send: %v", err)
+			return
+		}
+		metricSMTP.PushOK("")
+		if tag != "" {
+			storage.Set(mailRoundTripTagKey, tag)
+			storage.Set(mailRoundTripSentKey, strconv.FormatInt(now.Unix(), 10))
+		}
+	}
+}
+
+func (provider *ProviderMail) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			provider.update(ctx, resultWrapper, storage)
+		},
+	}
+}
+
+func (*ProviderMail) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderMail) Destroy() {
+}
+
+func init() {
+	RegisterProvider("mail", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderMail(cfg.Params)
+	})
+}
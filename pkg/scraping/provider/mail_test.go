@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/imapclient"
+	"gotest.tools/v3/assert"
+)
+
+// fakeMailServer is a minimal SMTP and IMAP server sharing a single mailbox (subjects only)
+type fakeMailServer struct {
+	mutex    sync.Mutex
+	subjects []string
+	deliver  bool
+}
+
+func (server *fakeMailServer) listen(t *testing.T, handler func(net.Conn)) (string, uint16) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				handler(conn)
+			}()
+		}
+	}()
+	address := listener.Addr().(*net.TCPAddr)
+	return address.IP.String(), uint16(address.Port)
+}
+
+func (server *fakeMailServer) serveSMTP(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	write := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	write("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			write("250-localhost")
+			write("250 AUTH PLAIN")
+		case command == "AUTH PLAIN AG1vbml0b3JpbmcAc2VjcmV0": // \0monitoring\0secret
+			write("235 authenticated")
+		case strings.HasPrefix(command, "AUTH"):
+			write("535 invalid credentials")
+		case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+			write("250 ok")
+		case command == "DATA":
+			write("354 go ahead")
+			subject := ""
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				if line == "." {
+					break
+				}
+				if value, found := strings.CutPrefix(line, "Subject: "); found {
+					subject = value
+				}
+			}
+			server.mutex.Lock()
+			if server.deliver {
+				server.subjects = append(server.subjects, subject)
+			}
+			server.mutex.Unlock()
+			write("250 queued")
+		case command == "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("502 unknown command")
+		}
+	}
+}
+
+func (server *fakeMailServer) serveIMAP(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	write := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	write("* OK IMAP4rev1 ready")
+	var deleted []int
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimSpace(line), " ")
+		server.mutex.Lock()
+		switch {
+		case command == `LOGIN "monitoring" "secret"`, command == `SELECT "INBOX"`:
+			write(tag + " OK done")
+		case strings.HasPrefix(command, "LOGIN"):
+			write(tag + " NO [AUTHENTICATIONFAILED] invalid credentials")
+		case strings.HasPrefix(command, "SEARCH SUBJECT "):
+			criteria, _ := strconv.Unquote(strings.TrimPrefix(command, "SEARCH SUBJECT "))
+			result := "* SEARCH"
+			for index, subject := range server.subjects {
+				if strings.Contains(subject, criteria) {
+					result += " " + strconv.Itoa(index+1)
+				}
+			}
+			write(result)
+			write(tag + " OK done")
+		case strings.HasPrefix(command, "STORE "):
+			for _, id := range strings.Split(strings.Fields(command)[1], ",") {
+				index, _ := strconv.Atoi(id)
+				deleted = append(deleted, index-1)
+			}
+			write(tag + " OK done")
+		case command == "EXPUNGE":
+			for i := len(deleted) - 1; i >= 0; i-- {
+				server.subjects = append(server.subjects[:deleted[i]], server.subjects[deleted[i]+1:]...)
+			}
+			deleted = nil
+			write(tag + " OK done")
+		case command == "LOGOUT":
+			write("* BYE")
+			write(tag + " OK done")
+			server.mutex.Unlock()
+			return
+		default:
+			write(tag + " BAD unknown command")
+		}
+		server.mutex.Unlock()
+	}
+}
+
+func collectMailResults(resultChan chan any) map[string]MetricState {
+	results := map[string]MetricState{}
+	for {
+		select {
+		case result := <-resultChan:
+			if state, ok := result.(MetricState); ok {
+				results[state.MetricID] = state
+			}
+		default:
+			return results
+		}
+	}
+}
+
+func TestMailRoundTrip(t *testing.T) {
+	server := &fakeMailServer{deliver: true}
+	smtpHost, smtpPort := server.listen(t, server.serveSMTP)
+	imapHost, imapPort := server.listen(t, server.serveIMAP)
+
+	provider, err := NewProviderMail(map[string]any{
+		"smtp": map[string]any{
+			"host":     smtpHost,
+			"port":     uint64(smtpPort),
+			"security": "none",
+			"username": "monitoring",
+			"password": "secret",
+		},
+		"imap": map[string]any{
+			"host":     imapHost,
+			"port":     uint64(imapPort),
+			"security": "none",
+			"username": "monitoring",
+			"password": "secret",
+		},
+		"round_trip": map[string]any{
+			"from":    "monitoring@example.com",
+			"to":      "monitoring@example.com",
+			"timeout": "1m",
+		},
+	})
+	assert.NilError(t, err)
+	defer provider.Destroy()
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("test", resultChan)
+	memoryStorage := storage.NewMemoryStorage()
+
+	// first scrape only sends the message
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	results := collectMailResults(resultChan)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, Healthy, results["test_mail_imap"].Status)
+	assert.Equal(t, Healthy, results["test_mail_smtp"].Status)
+	tag, pending := memoryStorage.Get(mailRoundTripTagKey)
+	assert.Assert(t, pending)
+	assert.DeepEqual(t, []string{mailRoundTripSubjectBase + tag}, server.subjects)
+
+	// second scrape finds and deletes it, then sends a new one
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	results = collectMailResults(resultChan)
+	assert.Equal(t, Healthy, results["test_mail_round_trip"].Status)
+	newTag, pending := memoryStorage.Get(mailRoundTripTagKey)
+	assert.Assert(t, pending)
+	assert.Assert(t, newTag != tag)
+	assert.DeepEqual(t, []string{mailRoundTripSubjectBase + newTag}, server.subjects)
+
+	// message lost: nothing is reported until timeout is reached
+	server.mutex.Lock()
+	server.subjects = nil
+	server.deliver = false
+	server.mutex.Unlock()
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	results = collectMailResults(resultChan)
+	_, exists := results["test_mail_round_trip"]
+	assert.Assert(t, !exists)
+	stillPendingTag, _ := memoryStorage.Get(mailRoundTripTagKey)
+	assert.Equal(t, newTag, stillPendingTag)
+
+	sent := time.Now().Add(-2 * time.Minute)
+	memoryStorage.Set(mailRoundTripSentKey, strconv.FormatInt(sent.Unix(), 10))
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	results = collectMailResults(resultChan)
+	assert.Equal(t, Unhealthy, results["test_mail_round_trip"].Status)
+	assert.Equal(t, "message sent at "+sent.Format(time.DateTime)+" not received after 1m0s", results["test_mail_round_trip"].Description)
+}
+
+func TestMailAuthenticationFailure(t *testing.T) {
+	server := &fakeMailServer{}
+	smtpHost, smtpPort := server.listen(t, server.serveSMTP)
+	imapHost, imapPort := server.listen(t, server.serveIMAP)
+
+	provider, err := NewProviderMail(map[string]any{
+		"smtp": map[string]any{"host": smtpHost, "port": uint64(smtpPort), "security": "none", "username": "monitoring", "password": "wrong"},
+		"imap": map[string]any{"host": imapHost, "port": uint64(imapPort), "security": "none", "username": "monitoring", "password": "wrong"},
+	})
+	assert.NilError(t, err)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("test", resultChan)
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	results := collectMailResults(resultChan)
+	assert.Equal(t, Unhealthy, results["test_mail_smtp"].Status)
+	assert.Assert(t, strings.Contains(results["test_mail_smtp"].Description, "authentication failed"))
+	assert.Equal(t, Unhealthy, results["test_mail_imap"].Status)
+	assert.Assert(t, strings.Contains(results["test_mail_imap"].Description, "AUTHENTICATIONFAILED"))
+}
+
+func TestMailInvalidConfig(t *testing.T) {
+	_, err := NewProviderMail(map[string]any{})
+	assert.ErrorIs(t, err, ErrInvalidMailConfig)
+
+	_, err = NewProviderMail(map[string]any{
+		"imap":       map[string]any{"host": "localhost", "username": "monitoring", "password": "secret"},
+		"round_trip": map[string]any{"from": "a@example.com", "to": "b@example.com"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidMailConfig)
+
+	_, err = NewProviderMail(map[string]any{
+		"smtp": map[string]any{"host": "localhost", "security": "ssl"},
+	})
+	assert.ErrorIs(t, err, imapclient.ErrInvalidSecurity)
+}
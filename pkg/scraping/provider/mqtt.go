@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/comparison"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+const mqttSubscribeTimeout = 10 * time.Second
+
+var (
+	ErrMQTTNotConnected = errors.New("not connected")
+	ErrPayloadField     = errors.New("field not found in payload")
+)
+
+type MQTTClient interface {
+	// Connect in background, with automatic reconnection. Topic filters are subscribed on each connection.
+	Connect(subscriptions map[string]func(topic string, payload []byte, retained bool))
+	// Status returns nil when connected
+	Status() error
+	Disconnect()
+}
+
+type defaultMQTTClient struct {
+	options *mqtt.ClientOptions
+
+	mutex   sync.Mutex
+	client  mqtt.Client
+	lastErr error
+}
+
+func (c *defaultMQTTClient) Connect(subscriptions map[string]func(topic string, payload []byte, retained bool)) {
+	c.options.SetAutoReconnect(true)
+	c.options.SetConnectRetry(true)
+	c.options.SetOnConnectHandler(func(client mqtt.Client) {
+		for topic, handler := range subscriptions {
+			token := client.Subscribe(topic, 1, func(_ mqtt.Client, message mqtt.Message) {
+				handler(message.Topic(), message.Payload(), message.Retained())
+			})
+			if !token.WaitTimeout(mqttSubscribeTimeout) || token.Error() != nil {
+				logging.Warning("unable to subscribe to mqtt topic %v (%v)", topic, token.Error())
+			}
+		}
+	})
+	c.options.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		logging.Warning("mqtt connection lost (%v)", err)
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.lastErr = err
+	})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.client = mqtt.NewClient(c.options)
+	c.client.Connect()
+}
+
+func (c *defaultMQTTClient) Status() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client != nil && c.client.IsConnectionOpen() {
+		return nil
+	}
+	if c.lastErr != nil {
+		return fmt.Errorf("%w: %v", ErrMQTTNotConnected, c.lastErr)
+	}
+	return ErrMQTTNotConnected
+}
+
+func (c *defaultMQTTClient) Disconnect() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client != nil {
+		c.client.Disconnect(250)
+	}
+}
+
+type mqttTopic struct {
+	Topic             string               `json:"topic"`                         // topic filter, wildcards allowed
+	MaxAge            customtypes.Duration `json:"max_age" default:"0s"`          // 0 means disabled
+	FailureExpression string               `json:"failure_expression" default:""` // ie. battery < 10
+
+	failureComparison *comparison.Comparison
+}
+
+type ProviderMQTT struct {
+	client   MQTTClient
+	Broker   string               `json:"broker" default:"tcp://localhost:1883"`
+	Username string               `json:"username" default:""`
+	Password string               `json:"password" default:""`
+	ClientID string               `json:"client_id" default:""` // random when empty
+	Timeout  customtypes.Duration `json:"timeout" default:"10s"`
+	Topics   map[string]mqttTopic `json:"topics"`
+
+	mutex       sync.Mutex
+	lastMessage map[string]map[string]time.Time // by topic name, then received topic
+	startTime   time.Time
+}
+
+func NewProviderMQTT(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderMQTT](params)
+	if err != nil {
+		return nil, err
+	}
+	for name, topic := range cfg.Topics {
+		if !utils.IsNameValid(name) {
+			return nil, fmt.Errorf("forbidden characters in topic name '%v'", name)
+		}
+		if topic.FailureExpression != "" {
+			failureComparison, err := comparison.Parse(topic.FailureExpression)
+			if err != nil {
+				return nil, err
+			}
+			topic.failureComparison = &failureComparison
+		}
+		cfg.Topics[name] = topic
+	}
+
+	if cfg.ClientID == "" {
+		suffix := make([]byte, 4)
+		_, _ = rand.Read(suffix)
+		cfg.ClientID = "minimal-server-monitoring-" + hex.EncodeToString(suffix)
+	}
+	options := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(cfg.Timeout.AsDuration())
+	cfg.client = &defaultMQTTClient{options: options}
+
+	cfg.lastMessage = make(map[string]map[string]time.Time, len(cfg.Topics))
+	for name := range cfg.Topics {
+		cfg.lastMessage[name] = make(map[string]time.Time)
+	}
+	return &cfg, nil
+}
+
+func (topic *mqttTopic) hasWildcard() bool {
+	return strings.ContainsAny(topic.Topic, "+#")
+}
+
+// metricKey returns the metric id suffix and name of a received topic: each topic matching a wildcard gets its own metrics
+func (topic *mqttTopic) metricKey(name, receivedTopic string) (string, string) {
+	if topic.hasWildcard() {
+		return name + "_" + receivedTopic, name + " " + receivedTopic
+	}
+	return name, name
+}
+
+// extractPayloadValue returns the whole payload ("payload") or a field of a json payload (ie. state.battery)
+func extractPayloadValue(payload []byte, field string) (string, error) {
+	if field == "payload" {
+		return string(payload), nil
+	}
+	var value any
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return "", err
+	}
+	for key := range strings.SplitSeq(field, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("%w: %v", ErrPayloadField, field)
+		}
+		if value, ok = object[key]; !ok {
+			return "", fmt.Errorf("%w: %v", ErrPayloadField, field)
+		}
+	}
+	switch typedValue := value.(type) {
+	case string:
+		return typedValue, nil
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64), nil
+	default:
+		encoded, err := json.Marshal(typedValue)
+		return string(encoded), err
+	}
+}
+
+func (provider *ProviderMQTT) handleMessage(resultWrapper *ScrapeResultWrapper, name string, topic mqttTopic, receivedTopic string, payload []byte, retained bool, now time.Time) {
+	provider.mutex.Lock()
+	if !retained {
+		provider.lastMessage[name][receivedTopic] = now
+	} else if _, known := provider.lastMessage[name][receivedTopic]; !known {
+		// retained messages were published before subscribing: topic is considered fresh at startup
+		provider.lastMessage[name][receivedTopic] = provider.startTime
+	}
+	provider.mutex.Unlock()
+
+	if topic.failureComparison == nil {
+		return
+	}
+	key, label := topic.metricKey(name, receivedTopic)
+	metric := resultWrapper.Metric("mqtt_"+key+"_payload", "mqtt "+label+" payload")
+	value, err := extractPayloadValue(payload, topic.failureComparison.Subject)
+	if err != nil {
+		metric.PushFailure("unable to read payload: %v", err)
+		return
+	}
+	matched, err := topic.failureComparison.Match(value)
+	if err != nil {
+		metric.PushFailure("unable to evaluate '%v': %v", topic.FailureExpression, err)
+	} else if matched {
+		metric.PushFailure("%v (%v)", topic.FailureExpression, value)
+	} else {
+		metric.PushOK("")
+	}
+}
+
+func (provider *ProviderMQTT) update(resultWrapper *ScrapeResultWrapper, now time.Time) {
+	metricBroker := resultWrapper.Metric("mqtt_broker", "mqtt broker")
+	if err := provider.client.Status(); err != nil {
+		metricBroker.PushFailure("%v: %v", provider.Broker, err)
+	} else {
+		metricBroker.PushOK("")
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	for name, topic := range provider.Topics {
+		if topic.MaxAge == 0 {
+			continue
+		}
+		for receivedTopic, lastMessage := range provider.lastMessage[name] {
+			key, label := topic.metricKey(name, receivedTopic)
+			metric := resultWrapper.Metric("mqtt_"+key+"_age", "mqtt "+label)
+			if age := now.Sub(lastMessage); age > topic.MaxAge.AsDuration() {
+				metric.PushFailure("no message on %v for %v (max %v)", receivedTopic, age.Round(time.Second), topic.MaxAge)
+			} else {
+				metric.PushOK("")
+			}
+		}
+	}
+}
+
+func (provider *ProviderMQTT) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	// topics are considered fresh at startup (topics matching a wildcard are known once a message is received)
+	provider.mutex.Lock()
+	provider.startTime = time.Now()
+	for name, topic := range provider.Topics {
+		if !topic.hasWildcard() {
+			provider.lastMessage[name][topic.Topic] = provider.startTime
+		}
+	}
+	provider.mutex.Unlock()
+	return UpdateTaskList{
+		func() {
+			provider.update(resultWrapper, time.Now())
+		},
+	}
+}
+
+func (provider *ProviderMQTT) Watch(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) {
+	// several configured topics may share the same topic filter
+	subscriptions := map[string]func(topic string, payload []byte, retained bool){}
+	for name, topic := range provider.Topics {
+		previousHandler := subscriptions[topic.Topic]
+		subscriptions[topic.Topic] = func(receivedTopic string, payload []byte, retained bool) {
+			if previousHandler != nil {
+				previousHandler(receivedTopic, payload, retained)
+			}
+			provider.handleMessage(resultWrapper, name, topic, receivedTopic, payload, retained, time.Now())
+		}
+	}
+
+	provider.client.Connect(subscriptions)
+	<-ctx.Done()
+	provider.client.Disconnect()
+}
+
+func (*ProviderMQTT) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderMQTT) Destroy() {
+}
+
+func init() {
+	RegisterProvider("mqtt", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderMQTT(cfg.Params)
+	})
+}
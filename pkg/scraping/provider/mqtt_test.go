@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+type mockMQTTClient struct {
+	mutex         sync.Mutex
+	subscriptions map[string]func(topic string, payload []byte, retained bool)
+	status        error
+	disconnected  bool
+}
+
+func (m *mockMQTTClient) Connect(subscriptions map[string]func(topic string, payload []byte, retained bool)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subscriptions = subscriptions
+}
+
+func (m *mockMQTTClient) Status() error {
+	return m.status
+}
+
+func (m *mockMQTTClient) Disconnect() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.disconnected = true
+}
+
+func mqttFilterMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+func (m *mockMQTTClient) publish(t *testing.T, topic, payload string, retained bool) {
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for {
+		handlers := []func(topic string, payload []byte, retained bool){}
+		m.mutex.Lock()
+		for filter, handler := range m.subscriptions {
+			if mqttFilterMatches(filter, topic) {
+				handlers = append(handlers, handler)
+			}
+		}
+		m.mutex.Unlock()
+		for _, handler := range handlers {
+			handler(topic, []byte(payload), retained)
+		}
+		if len(handlers) > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no subscription to %v", topic)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExtractPayloadValue(t *testing.T) {
+	payload := []byte(`{"battery": 87, "contact": false, "state": {"temperature": 21.5, "mode": "auto"}}`)
+	value, err := extractPayloadValue(payload, "battery")
+	assert.NilError(t, err)
+	assert.Equal(t, "87", value)
+	value, err = extractPayloadValue(payload, "contact")
+	assert.NilError(t, err)
+	assert.Equal(t, "false", value)
+	value, err = extractPayloadValue(payload, "state.temperature")
+	assert.NilError(t, err)
+	assert.Equal(t, "21.5", value)
+	value, err = extractPayloadValue(payload, "state.mode")
+	assert.NilError(t, err)
+	assert.Equal(t, "auto", value)
+	_, err = extractPayloadValue(payload, "state.humidity")
+	assert.ErrorIs(t, err, ErrPayloadField)
+	value, err = extractPayloadValue([]byte("offline"), "payload")
+	assert.NilError(t, err)
+	assert.Equal(t, "offline", value)
+}
+
+func TestMQTT(t *testing.T) {
+	provider, err := NewProviderMQTT(map[string]any{
+		"topics": map[string]any{
+			"living_room": map[string]any{"topic": "zigbee2mqtt/living_room", "max_age": "1h", "failure_expression": "battery < 10"},
+			"bridge":      map[string]any{"topic": "zigbee2mqtt/bridge/state", "failure_expression": "payload == offline"},
+			"garden":      map[string]any{"topic": "garden/+/temperature", "max_age": "30m", "failure_expression": "payload < 0"},
+		},
+	})
+	assert.NilError(t, err)
+	mqttProvider := provider.(*ProviderMQTT)
+	mockClient := &mockMQTTClient{}
+	mqttProvider.client = mockClient
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("home", resultChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	taskList := provider.GetUpdateTaskList(ctx, &wrapper, storage.NewMemoryStorage())
+	watchDone := make(chan struct{})
+	go func() {
+		mqttProvider.Watch(ctx, &wrapper, storage.NewMemoryStorage())
+		close(watchDone)
+	}()
+
+	// Payload checks
+	mockClient.publish(t, "zigbee2mqtt/living_room", `{"battery": 5, "temperature": 20}`, false)
+	metric := waitForMetricState(t, resultChan, "home_mqtt_living_room_payload")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "battery < 10 (5)", metric.Description)
+	mockClient.publish(t, "zigbee2mqtt/bridge/state", "online", true)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "home_mqtt_bridge_payload").Status)
+
+	// Topics matching a wildcard get their own metrics
+	mockClient.publish(t, "garden/kitchen/temperature", "18.5", false)
+	metric = waitForMetricState(t, resultChan, "home_mqtt_garden_garden/kitchen/temperature_payload")
+	assert.Equal(t, Healthy, metric.Status)
+	assert.Equal(t, "mqtt garden garden/kitchen/temperature payload", metric.Name)
+	mockClient.publish(t, "garden/shed/temperature", "-2", true)
+	assert.Equal(t, Unhealthy, waitForMetricState(t, resultChan, "home_mqtt_garden_garden/shed/temperature_payload").Status)
+
+	// Freshness checks
+	assert.Equal(t, 1, len(taskList))
+	taskList[0]()
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "home_mqtt_broker").Status)
+	drainChannel(resultChan)
+
+	mqttProvider.update(&wrapper, time.Now().Add(45*time.Minute))
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "home_mqtt_living_room_age").Status)
+	drainChannel(resultChan)
+	mqttProvider.update(&wrapper, time.Now().Add(45*time.Minute))
+	states := collectMetricStates(resultChan)
+	assert.Equal(t, Unhealthy, states["home_mqtt_garden_garden/kitchen/temperature_age"].Status)
+	assert.Equal(t, "no message on garden/kitchen/temperature for 45m0s (max 30m0s)", states["home_mqtt_garden_garden/kitchen/temperature_age"].Description)
+	assert.Equal(t, Unhealthy, states["home_mqtt_garden_garden/shed/temperature_age"].Status)
+	_, exists := states["home_mqtt_garden_age"]
+	assert.Equal(t, false, exists)
+
+	// Retained messages don't refresh topics
+	livingRoom := mqttProvider.Topics["living_room"]
+	mqttProvider.handleMessage(&wrapper, "living_room", livingRoom, "zigbee2mqtt/living_room", []byte(`{"battery": 50}`), true, time.Now().Add(50*time.Minute))
+	mqttProvider.update(&wrapper, time.Now().Add(75*time.Minute))
+	assert.Equal(t, Unhealthy, collectMetricStates(resultChan)["home_mqtt_living_room_age"].Status)
+	mqttProvider.handleMessage(&wrapper, "living_room", livingRoom, "zigbee2mqtt/living_room", []byte(`{"battery": 50}`), false, time.Now().Add(50*time.Minute))
+	mqttProvider.update(&wrapper, time.Now().Add(75*time.Minute))
+	assert.Equal(t, Healthy, collectMetricStates(resultChan)["home_mqtt_living_room_age"].Status)
+
+	mockClient.status = ErrMQTTNotConnected
+	taskList[0]()
+	metric = waitForMetricState(t, resultChan, "home_mqtt_broker")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "tcp://localhost:1883: not connected", metric.Description)
+
+	cancel()
+	<-watchDone
+	assert.Equal(t, true, mockClient.disconnected)
+
+	_, err = NewProviderMQTT(map[string]any{"topics": map[string]any{"bad": map[string]any{"topic": "a", "failure_expression": "battery"}}})
+	assert.ErrorContains(t, err, "invalid comparison expression")
+}
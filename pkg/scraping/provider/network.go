@@ -0,0 +1,388 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/stats"
+)
+
+var (
+	ErrInvalidRateWindow   = errors.New("rate_window must be greater than or equal to scrape_interval")
+	ErrInterfaceNotFound   = errors.New("interface not found")
+	ErrInvalidNetDevFormat = errors.New("invalid /proc/net/dev format")
+)
+
+type WireGuardClient interface {
+	// latest handshake for each peer (zero time if none)
+	LatestHandshakes(ctx context.Context, iface string) (map[string]time.Time, error)
+}
+
+type defaultWireGuardClient struct{}
+
+func (*defaultWireGuardClient) LatestHandshakes(ctx context.Context, iface string) (map[string]time.Time, error) {
+	output, err := exec.CommandContext(ctx, "wg", "show", iface, "latest-handshakes").Output()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]time.Time{}
+	for line := range strings.Lines(string(output)) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if timestamp == 0 {
+			result[fields[0]] = time.Time{}
+		} else {
+			result[fields[0]] = time.Unix(timestamp, 0)
+		}
+	}
+	return result, nil
+}
+
+type netCounters struct {
+	rxBytes, txBytes uint64
+	errors, drops    uint64 // rx + tx
+}
+
+type ProviderNetwork struct {
+	wgClient            WireGuardClient
+	MountPrefix         string                      `json:"mountprefix" default:""` // Host root filesytem when running inside a container
+	Interfaces          []string                    `json:"interfaces" default:"[]"`
+	InterfaceBlacklist  []string                    `json:"interface_blacklist" default:"[lo, veth*, docker*, br-*, podman*, cni*]"` // glob patterns
+	ErrorThreshold      uint64                      `json:"error_threshold" default:"10"`                                            // errors + drops over rate_window
+	LinkSpeed           uint64                      `json:"link_speed" default:"0"`                                                  // Mbit/s, 0 means read from sysfs
+	SaturationThreshold utils.RelativeAbsoluteValue `json:"saturation_threshold" default:"90%" custom:"relative_absolute_value"`
+	RateWindow          customtypes.Duration        `json:"rate_window" default:"5m"`
+	WireGuardMaxAge     customtypes.Duration        `json:"wireguard_handshake_max_age" default:"0s"` // 0 means disabled
+
+	interfaceStats     map[string]*stats.WindowCollector[netCounters]
+	knownInterfaceList []string
+	knownPeers         map[string][]string // wireguard peers by interface
+}
+
+func NewProviderNetwork(params map[string]any, scrapeInterval time.Duration) (Provider, error) {
+	mapperCtx, err := makeConfigMapperContext()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := configmapper.MapOnStructWithContext[ProviderNetwork](&mapperCtx, params)
+	if err != nil {
+		return nil, err
+	}
+	cfg.wgClient = &defaultWireGuardClient{}
+	cfg.interfaceStats = make(map[string]*stats.WindowCollector[netCounters])
+	cfg.knownPeers = make(map[string][]string)
+	if cfg.RateWindow.AsDuration() < scrapeInterval {
+		return nil, fmt.Errorf("%w: (%v < %v)", ErrInvalidRateWindow, cfg.RateWindow, scrapeInterval)
+	}
+	return &cfg, nil
+}
+
+func (provider *ProviderNetwork) sysClassNet(elem ...string) string {
+	return filepath.Join(append([]string{provider.MountPrefix, "/sys/class/net"}, elem...)...)
+}
+
+func (provider *ProviderNetwork) readSysFile(iface, name string) (string, error) {
+	content, err := os.ReadFile(provider.sysClassNet(iface, name))
+	return strings.TrimSpace(string(content)), err
+}
+
+func (provider *ProviderNetwork) listInterfaces() ([]string, error) {
+	if len(provider.Interfaces) > 0 {
+		return provider.Interfaces, nil
+	}
+
+	entries, err := os.ReadDir(provider.sysClassNet())
+	if err != nil {
+		return nil, err
+	}
+	interfaces := []string{}
+	for _, entry := range entries {
+		blacklisted := slices.ContainsFunc(provider.InterfaceBlacklist, func(pattern string) bool {
+			matched, _ := path.Match(pattern, entry.Name())
+			return matched
+		})
+		if !blacklisted {
+			interfaces = append(interfaces, entry.Name())
+		}
+	}
+	return interfaces, nil
+}
+
+// Counters are read from PID 1 network namespace, /proc/net being relative to the current process.
+func (provider *ProviderNetwork) readCounters() (map[string]netCounters, error) {
+	file, err := os.Open(filepath.Join(provider.MountPrefix, "/proc/1/net/dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer utils.SafeClose(file)
+
+	result := map[string]netCounters{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		iface, values, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue // header
+		}
+		fields := strings.Fields(values)
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidNetDevFormat, scanner.Text())
+		}
+		parsed := make([]uint64, 16)
+		for i := range parsed {
+			parsed[i], err = strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidNetDevFormat, err)
+			}
+		}
+		// receive: bytes packets errs drop ..., transmit (8+): bytes packets errs drop ...
+		result[strings.TrimSpace(iface)] = netCounters{
+			rxBytes: parsed[0],
+			txBytes: parsed[8],
+			errors:  parsed[2] + parsed[10],
+			drops:   parsed[3] + parsed[11],
+		}
+	}
+	return result, scanner.Err()
+}
+
+func (provider *ProviderNetwork) checkLink(resultWrapper *ScrapeResultWrapper, iface string) bool {
+	metric := resultWrapper.Metric("network_"+iface+"_link", "interface "+iface+" link")
+	operState, err := provider.readSysFile(iface, "operstate")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			metric.PushFailure("%v", ErrInterfaceNotFound)
+		} else {
+			metric.PushFailure("unable to read link state: %v", err)
+		}
+		return false
+	}
+
+	switch operState {
+	case "down", "lowerlayerdown", "notpresent":
+		metric.PushFailure("link is %v", operState)
+		return false
+	default: // up, unknown (ie. wireguard, tun), dormant, testing
+		metric.PushOK("")
+		return true
+	}
+}
+
+// link speed in bytes per second (0 if unknown)
+func (provider *ProviderNetwork) linkSpeed(iface string) uint64 {
+	speedMbit := provider.LinkSpeed
+	if speedMbit == 0 {
+		speed, err := provider.readSysFile(iface, "speed")
+		if err != nil {
+			return 0
+		}
+		parsed, err := strconv.ParseInt(speed, 10, 64)
+		if err != nil || parsed <= 0 {
+			return 0
+		}
+		speedMbit = uint64(parsed)
+	}
+	return speedMbit * 1000 * 1000 / 8
+}
+
+func (provider *ProviderNetwork) updateRateStats(resultWrapper *ScrapeResultWrapper, iface string, counters netCounters) {
+	ifStats, ok := provider.interfaceStats[iface]
+	if !ok {
+		v := stats.MakeWindowCollector[netCounters](provider.RateWindow.AsDuration())
+		ifStats = &v
+		provider.interfaceStats[iface] = ifStats
+	}
+	if ifStats.Count() > 0 {
+		last := ifStats.Last().Data
+		if counters.rxBytes < last.rxBytes || counters.txBytes < last.txBytes || counters.errors < last.errors || counters.drops < last.drops {
+			// counters were reset (ie. interface recreated)
+			v := stats.MakeWindowCollector[netCounters](provider.RateWindow.AsDuration())
+			ifStats = &v
+			provider.interfaceStats[iface] = ifStats
+		}
+	}
+	ifStats.AddNew(counters)
+	if ifStats.Count() < 2 {
+		return
+	}
+
+	first := ifStats.First()
+	last := ifStats.Last()
+	elapsed := last.Timestamp.Sub(first.Timestamp)
+	windowSeconds := provider.RateWindow.AsDuration().Seconds()
+
+	metricErrors := resultWrapper.Metric("network_"+iface+"_errors", "interface "+iface+" errors")
+	deltaErrors := last.Data.errors - first.Data.errors
+	deltaDrops := last.Data.drops - first.Data.drops
+	if float64(deltaErrors+deltaDrops)/elapsed.Seconds() >= float64(provider.ErrorThreshold)/windowSeconds && deltaErrors+deltaDrops > 0 {
+		metricErrors.PushFailure("%v errors and %v drops in %v", deltaErrors, deltaDrops, elapsed.Round(time.Second))
+	} else {
+		metricErrors.PushOK("")
+	}
+
+	speed := provider.linkSpeed(iface)
+	if speed == 0 {
+		return
+	}
+	metricSaturation := resultWrapper.Metric("network_"+iface+"_saturation", "interface "+iface+" bandwidth")
+	rxRate := float64(last.Data.rxBytes-first.Data.rxBytes) / elapsed.Seconds()
+	txRate := float64(last.Data.txBytes-first.Data.txBytes) / elapsed.Seconds()
+	threshold := float64(provider.SaturationThreshold.GetValue(speed))
+	if rxRate >= threshold || txRate >= threshold {
+		metricSaturation.PushFailure("link saturated over %v (rx: %v/s, tx: %v/s, link: %v/s)",
+			elapsed.Round(time.Second),
+			humanize.Bytes(uint64(rxRate)),
+			humanize.Bytes(uint64(txRate)),
+			humanize.Bytes(speed))
+	} else {
+		metricSaturation.PushOK("")
+	}
+}
+
+// Link is down: rates are computed again from scratch once it's back up (not across the outage).
+// Errors and saturation are resolved, the link metric reports the outage.
+func (provider *ProviderNetwork) resetRateStats(resultWrapper *ScrapeResultWrapper, iface string) {
+	ifStats, ok := provider.interfaceStats[iface]
+	if !ok {
+		return
+	}
+	if ifStats.Count() >= 2 {
+		metricErrors := resultWrapper.Metric("network_"+iface+"_errors", "interface "+iface+" errors")
+		metricErrors.PushOK("")
+		metricSaturation := resultWrapper.Metric("network_"+iface+"_saturation", "interface "+iface+" bandwidth")
+		metricSaturation.PushOK("")
+	}
+	delete(provider.interfaceStats, iface)
+}
+
+func (provider *ProviderNetwork) isWireGuard(iface string) bool {
+	uevent, err := provider.readSysFile(iface, "uevent")
+	return err == nil && slices.Contains(strings.Split(uevent, "\n"), "DEVTYPE=wireguard")
+}
+
+func shortPeerKey(publicKey string) string {
+	return publicKey[:min(len(publicKey), 8)]
+}
+
+func (provider *ProviderNetwork) checkWireGuard(ctx context.Context, resultWrapper *ScrapeResultWrapper, iface string) {
+	metricList := resultWrapper.Metric("network_"+iface+"_wireguard", "wireguard "+iface)
+	handshakes, err := provider.wgClient.LatestHandshakes(ctx, iface)
+	if err != nil {
+		metricList.PushFailure("unable to list peers: %v", err)
+		if _, exists := provider.knownPeers[iface]; !exists {
+			provider.knownPeers[iface] = nil // list metric must be removed with the interface
+		}
+		return
+	}
+	metricList.PushOK("")
+
+	now := time.Now()
+	for peer, handshake := range handshakes {
+		metric := resultWrapper.Metric("network_"+iface+"_wireguard_"+peer, "wireguard "+iface+" peer "+shortPeerKey(peer))
+		if handshake.IsZero() {
+			metric.PushFailure("no handshake")
+		} else if age := now.Sub(handshake); age > provider.WireGuardMaxAge.AsDuration() {
+			metric.PushFailure("latest handshake %v ago", age.Round(time.Second))
+		} else {
+			metric.PushOK("")
+		}
+	}
+
+	for _, peer := range provider.knownPeers[iface] {
+		if _, exists := handshakes[peer]; !exists {
+			metric := resultWrapper.Metric("network_"+iface+"_wireguard_"+peer, "wireguard "+iface+" peer "+shortPeerKey(peer))
+			metric.PushRemoved("peer removed")
+		}
+	}
+	provider.knownPeers[iface] = slices.Collect(maps.Keys(handshakes))
+}
+
+func (provider *ProviderNetwork) removeInterface(resultWrapper *ScrapeResultWrapper, iface string) {
+	for suffix, name := range map[string]string{"link": "link", "errors": "errors", "saturation": "bandwidth"} {
+		metric := resultWrapper.Metric("network_"+iface+"_"+suffix, "interface "+iface+" "+name)
+		metric.PushRemoved("interface removed")
+	}
+	if peers, exists := provider.knownPeers[iface]; exists {
+		for _, peer := range peers {
+			metric := resultWrapper.Metric("network_"+iface+"_wireguard_"+peer, "wireguard "+iface+" peer "+shortPeerKey(peer))
+			metric.PushRemoved("interface removed")
+		}
+		metricList := resultWrapper.Metric("network_"+iface+"_wireguard", "wireguard "+iface)
+		metricList.PushRemoved("interface removed")
+	}
+	delete(provider.interfaceStats, iface)
+	delete(provider.knownPeers, iface)
+}
+
+func (provider *ProviderNetwork) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			metricList := resultWrapper.Metric("list_interfaces", "list network interfaces")
+			interfaces, err := provider.listInterfaces()
+			if err == nil {
+				var counters map[string]netCounters
+				counters, err = provider.readCounters()
+				for _, iface := range interfaces {
+					if !provider.checkLink(resultWrapper, iface) {
+						provider.resetRateStats(resultWrapper, iface)
+						continue
+					}
+					if ifCounters, ok := counters[iface]; ok {
+						provider.updateRateStats(resultWrapper, iface, ifCounters)
+					}
+					if provider.WireGuardMaxAge > 0 && provider.isWireGuard(iface) {
+						provider.checkWireGuard(ctx, resultWrapper, iface)
+					}
+				}
+
+				for _, knownInterface := range provider.knownInterfaceList {
+					if !slices.Contains(interfaces, knownInterface) {
+						provider.removeInterface(resultWrapper, knownInterface)
+					}
+				}
+				provider.knownInterfaceList = interfaces
+			}
+
+			if err != nil {
+				metricList.PushFailure("failed to read interfaces: %v", err)
+			} else {
+				metricList.PushOK("")
+			}
+		},
+	}
+}
+
+func (*ProviderNetwork) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderNetwork) Destroy() {
+}
+
+func init() {
+	RegisterProvider("network", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderNetwork(cfg.Params, cfg.ScrapeInterval.AsDuration())
+	})
+}
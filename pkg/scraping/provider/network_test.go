@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/stats"
+	"gotest.tools/v3/assert"
+)
+
+type mockWireGuardClient struct {
+	LatestHandshakesFunc func(ctx context.Context, iface string) (map[string]time.Time, error)
+}
+
+func (m *mockWireGuardClient) LatestHandshakes(ctx context.Context, iface string) (map[string]time.Time, error) {
+	if m.LatestHandshakesFunc != nil {
+		return m.LatestHandshakesFunc(ctx, iface)
+	}
+	return nil, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func writeNetDev(t *testing.T, prefix string, eth0Errors uint64) {
+	writeFile(t, filepath.Join(prefix, "proc/1/net/dev"), fmt.Sprintf(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+  eth0: 1000 10 %v 0 0 0 0 0 2000 20 0 0 0 0 0 0
+   wg0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0
+`, eth0Errors))
+}
+
+func TestNetwork(t *testing.T) {
+	prefix := t.TempDir()
+	writeFile(t, filepath.Join(prefix, "sys/class/net/eth0/operstate"), "up\n")
+	writeFile(t, filepath.Join(prefix, "sys/class/net/eth0/speed"), "1000\n")
+	writeFile(t, filepath.Join(prefix, "sys/class/net/wg0/operstate"), "unknown\n")
+	writeFile(t, filepath.Join(prefix, "sys/class/net/wg0/uevent"), "DEVTYPE=wireguard\nINTERFACE=wg0\n")
+	writeFile(t, filepath.Join(prefix, "sys/class/net/lo/operstate"), "unknown\n")
+	writeNetDev(t, prefix, 0)
+
+	saturationThreshold, err := utils.RelativeAbsoluteValueFromString("90%")
+	assert.NilError(t, err)
+
+	wgClient := &mockWireGuardClient{}
+	provider := &ProviderNetwork{
+		wgClient:            wgClient,
+		MountPrefix:         prefix,
+		Interfaces:          []string{},
+		InterfaceBlacklist:  []string{"lo"},
+		ErrorThreshold:      10,
+		SaturationThreshold: saturationThreshold,
+		RateWindow:          customtypes.Duration(5 * time.Minute),
+		WireGuardMaxAge:     customtypes.Duration(5 * time.Minute),
+		interfaceStats:      make(map[string]*stats.WindowCollector[netCounters]),
+		knownPeers:          make(map[string][]string),
+	}
+
+	wgClient.LatestHandshakesFunc = func(ctx context.Context, iface string) (map[string]time.Time, error) {
+		return map[string]time.Time{
+			"recentPeerKey=": time.Now().Add(-time.Minute),
+			"stalePeerKey=":  time.Now().Add(-time.Hour),
+			"neverPeerKey=":  {},
+		}, nil
+	}
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("net", resultChan)
+	taskList := provider.GetUpdateTaskList(context.Background(), &wrapper, storage.NewMemoryStorage())
+
+	// 1. Link state, wireguard handshakes: a single scrape reports every peer
+	taskList[0]()
+	states := collectMetricStates(resultChan)
+	assert.Equal(t, Healthy, states["net_network_eth0_link"].Status)
+	assert.Equal(t, Healthy, states["net_network_wg0_link"].Status)
+	assert.Equal(t, Healthy, states["net_network_wg0_wireguard"].Status)
+	assert.Equal(t, Healthy, states["net_network_wg0_wireguard_recentPeerKey="].Status)
+	stale := states["net_network_wg0_wireguard_stalePeerKey="]
+	assert.Equal(t, Unhealthy, stale.Status)
+	assert.Equal(t, "wireguard wg0 peer stalePee", stale.Name)
+	assert.Equal(t, "latest handshake 1h0m0s ago", stale.Description)
+	never := states["net_network_wg0_wireguard_neverPeerKey="]
+	assert.Equal(t, Unhealthy, never.Status)
+	assert.Equal(t, "no handshake", never.Description)
+	_, exists := states["net_network_lo_link"]
+	assert.Assert(t, !exists, "blacklisted interface")
+
+	// 2. Errors increase quickly
+	time.Sleep(10 * time.Millisecond)
+	writeNetDev(t, prefix, 50)
+	taskList[0]()
+	states = collectMetricStates(resultChan)
+	assert.Equal(t, Unhealthy, states["net_network_eth0_errors"].Status)
+	assert.Equal(t, Healthy, states["net_network_eth0_saturation"].Status)
+
+	// 3. Link down: rate metrics are resolved, stats are reset
+	writeFile(t, filepath.Join(prefix, "sys/class/net/eth0/operstate"), "down\n")
+	taskList[0]()
+	states = collectMetricStates(resultChan)
+	assert.Equal(t, Unhealthy, states["net_network_eth0_link"].Status)
+	assert.Equal(t, "link is down", states["net_network_eth0_link"].Description)
+	assert.Equal(t, Healthy, states["net_network_eth0_errors"].Status)
+	assert.Equal(t, Healthy, states["net_network_eth0_saturation"].Status)
+	_, exists = provider.interfaceStats["eth0"]
+	assert.Assert(t, !exists)
+
+	// 4. Interfaces disappear: every metric is removed, including wireguard peers
+	assert.NilError(t, os.RemoveAll(filepath.Join(prefix, "sys/class/net/eth0")))
+	assert.NilError(t, os.RemoveAll(filepath.Join(prefix, "sys/class/net/wg0")))
+	taskList[0]()
+	states = collectMetricStates(resultChan)
+	for _, metricID := range []string{
+		"net_network_eth0_link",
+		"net_network_eth0_errors",
+		"net_network_eth0_saturation",
+		"net_network_wg0_link",
+		"net_network_wg0_wireguard",
+		"net_network_wg0_wireguard_recentPeerKey=",
+		"net_network_wg0_wireguard_stalePeerKey=",
+		"net_network_wg0_wireguard_neverPeerKey=",
+	} {
+		assert.Equal(t, Removed, states[metricID].Status, metricID)
+	}
+	assert.Equal(t, 0, len(provider.knownPeers))
+}
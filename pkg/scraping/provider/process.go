@@ -0,0 +1,283 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+)
+
+const clockTicksPerSecond = 100 // USER_HZ, 100 on all supported architectures
+
+const maxCommLength = 15 // TASK_COMM_LEN - 1, the kernel truncates longer names
+
+var (
+	ErrInvalidProcessMatcher = errors.New("either name or regex must be set")
+	ErrInvalidProcStat       = errors.New("invalid /proc/<pid>/stat format")
+	ErrProcessNameTooLong    = fmt.Errorf("process name is truncated to %v characters by the kernel, use regex instead", maxCommLength)
+)
+
+type processMatcher struct {
+	Name     string                      `json:"name" default:""`  // exact match on process name (comm)
+	Regex    string                      `json:"regex" default:""` // match on command line
+	MinCount uint                        `json:"min_count" default:"1"`
+	MaxCount uint                        `json:"max_count" default:"0"`                                // 0 means unlimited
+	MaxRSS   utils.RelativeAbsoluteValue `json:"max_rss" default:"0" custom:"relative_absolute_value"` // relative to total memory, 0 means disabled
+	MaxCPU   uint                        `json:"max_cpu" default:"0"`                                  // percent of a single core, 0 means disabled
+
+	regex *regexp.Regexp
+}
+
+type processInfo struct {
+	pid        int
+	comm       string
+	cmdline    string
+	rss        uint64 // bytes
+	cpuTicks   uint64 // utime + stime
+	cpuPercent float64
+}
+
+type ProviderProcess struct {
+	MountPrefix string                    `json:"mountprefix" default:""` // Host root filesytem when running inside a container
+	Processes   map[string]processMatcher `json:"processes"`
+
+	lastScrape   time.Time
+	lastCPUTicks map[int]uint64
+}
+
+func NewProviderProcess(params map[string]any) (Provider, error) {
+	mapperCtx, err := makeConfigMapperContext()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := configmapper.MapOnStructWithContext[ProviderProcess](&mapperCtx, params)
+	if err != nil {
+		return nil, err
+	}
+	for name, matcher := range cfg.Processes {
+		if !utils.IsNameValid(name) {
+			return nil, fmt.Errorf("forbidden characters in process name '%v'", name)
+		}
+		if matcher.Name == "" && matcher.Regex == "" {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProcessMatcher, name)
+		}
+		if len(matcher.Name) > maxCommLength {
+			return nil, fmt.Errorf("%w: %v", ErrProcessNameTooLong, name)
+		}
+		if matcher.Regex != "" {
+			matcher.regex, err = regexp.Compile(matcher.Regex)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cfg.Processes[name] = matcher
+	}
+	cfg.lastCPUTicks = make(map[int]uint64)
+	return &cfg, nil
+}
+
+func (matcher *processMatcher) match(process processInfo) bool {
+	if matcher.Name != "" && matcher.Name != process.comm {
+		return false
+	}
+	if matcher.regex != nil && !matcher.regex.MatchString(process.cmdline) {
+		return false
+	}
+	return true
+}
+
+func (provider *ProviderProcess) procPath(elem ...string) string {
+	return filepath.Join(append([]string{provider.MountPrefix, "/proc"}, elem...)...)
+}
+
+func parseProcStat(content string) (comm string, cpuTicks, rssPages uint64, err error) {
+	// comm may contain spaces and parenthesis: "pid (comm) state ..."
+	start := strings.IndexByte(content, '(')
+	end := strings.LastIndexByte(content, ')')
+	if start < 0 || end < start {
+		return "", 0, 0, ErrInvalidProcStat
+	}
+	comm = content[start+1 : end]
+	// fields[0] is field 3 (state) in proc(5)
+	fields := strings.Fields(content[end+1:])
+	if len(fields) < 22 {
+		return "", 0, 0, ErrInvalidProcStat
+	}
+	utime, errU := strconv.ParseUint(fields[11], 10, 64)
+	stime, errS := strconv.ParseUint(fields[12], 10, 64)
+	rssPages, errR := strconv.ParseUint(fields[21], 10, 64)
+	if err = errors.Join(errU, errS, errR); err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrInvalidProcStat, err)
+	}
+	return comm, utime + stime, rssPages, nil
+}
+
+func (provider *ProviderProcess) listProcesses(now time.Time) ([]processInfo, error) {
+	entries, err := os.ReadDir(provider.procPath())
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := now.Sub(provider.lastScrape).Seconds()
+	cpuTicks := make(map[int]uint64, len(entries))
+	pageSize := uint64(os.Getpagesize())
+	processes := []processInfo{}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Process may exit at any time, ignore read errors
+		stat, err := os.ReadFile(provider.procPath(entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(provider.procPath(entry.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		comm, ticks, rssPages, err := parseProcStat(string(stat))
+		if err != nil {
+			// one unexpected entry (ie. truncated while exiting) must not fail the whole scan
+			logging.Debug("Ignoring process %v: %v", pid, err)
+			continue
+		}
+
+		process := processInfo{
+			pid:      pid,
+			comm:     comm,
+			cmdline:  strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " ")),
+			rss:      rssPages * pageSize,
+			cpuTicks: ticks,
+		}
+		if lastTicks, ok := provider.lastCPUTicks[pid]; ok && elapsed > 0 && ticks >= lastTicks {
+			process.cpuPercent = 100 * float64(ticks-lastTicks) / clockTicksPerSecond / elapsed
+		}
+		cpuTicks[pid] = ticks
+		processes = append(processes, process)
+	}
+
+	provider.lastCPUTicks = cpuTicks
+	provider.lastScrape = now
+	return processes, nil
+}
+
+func (provider *ProviderProcess) totalMemory() (uint64, error) {
+	file, err := os.Open(provider.procPath("meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer utils.SafeClose(file)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// MemTotal:       16314020 kB (kB means KiB here)
+		if value, found := strings.CutPrefix(scanner.Text(), "MemTotal:"); found {
+			kib, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			return kib * 1024, err
+		}
+	}
+	return 0, scanner.Err()
+}
+
+func (provider *ProviderProcess) checkProcesses(resultWrapper *ScrapeResultWrapper, name string, matcher processMatcher, processes []processInfo, totalMemory uint64) {
+	matched := []processInfo{}
+	for _, process := range processes {
+		if matcher.match(process) {
+			matched = append(matched, process)
+		}
+	}
+
+	metricCount := resultWrapper.Metric("process_"+name+"_count", "process "+name)
+	if uint(len(matched)) < matcher.MinCount {
+		if len(matched) == 0 {
+			metricCount.PushFailure("no matching process")
+		} else {
+			metricCount.PushFailure("%v matching processes (min %v)", len(matched), matcher.MinCount)
+		}
+	} else if matcher.MaxCount > 0 && uint(len(matched)) > matcher.MaxCount {
+		metricCount.PushFailure("%v matching processes (max %v)", len(matched), matcher.MaxCount)
+	} else {
+		metricCount.PushOK("")
+	}
+
+	if maxRSS := matcher.MaxRSS.GetValue(totalMemory); maxRSS > 0 {
+		metricRSS := resultWrapper.Metric("process_"+name+"_rss", "process "+name+" memory")
+		exceeded := []string{}
+		for _, process := range matched {
+			if process.rss > maxRSS {
+				exceeded = append(exceeded, fmt.Sprintf("pid %v: %v", process.pid, humanize.Bytes(process.rss)))
+			}
+		}
+		if len(exceeded) > 0 {
+			metricRSS.PushFailure("memory usage above %v (%v)", humanize.Bytes(maxRSS), strings.Join(exceeded, ", "))
+		} else {
+			metricRSS.PushOK("")
+		}
+	}
+
+	if matcher.MaxCPU > 0 {
+		metricCPU := resultWrapper.Metric("process_"+name+"_cpu", "process "+name+" cpu")
+		exceeded := []string{}
+		for _, process := range matched {
+			if process.cpuPercent > float64(matcher.MaxCPU) {
+				exceeded = append(exceeded, fmt.Sprintf("pid %v: %.0f%%", process.pid, process.cpuPercent))
+			}
+		}
+		if len(exceeded) > 0 {
+			metricCPU.PushFailure("cpu usage above %v%% (%v)", matcher.MaxCPU, strings.Join(exceeded, ", "))
+		} else {
+			metricCPU.PushOK("")
+		}
+	}
+}
+
+func (provider *ProviderProcess) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			metricList := resultWrapper.Metric("list_processes", "list processes")
+			processes, err := provider.listProcesses(time.Now())
+			if err != nil {
+				metricList.PushFailure("failed to list processes: %v", err)
+				return
+			}
+			totalMemory, err := provider.totalMemory()
+			if err != nil {
+				metricList.PushFailure("failed to read total memory: %v", err)
+				return
+			}
+			metricList.PushOK("")
+
+			for name, matcher := range provider.Processes {
+				provider.checkProcesses(resultWrapper, name, matcher, processes, totalMemory)
+			}
+		},
+	}
+}
+
+func (*ProviderProcess) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderProcess) Destroy() {
+}
+
+func init() {
+	RegisterProvider("process", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderProcess(cfg.Params)
+	})
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+func writeProcess(t *testing.T, prefix string, pid int, comm, cmdline string, cpuTicks, rssPages uint64) {
+	stat := fmt.Sprintf("%v (%v) S 1 1 1 0 -1 4194560 100 0 0 0 %v 0 0 0 20 0 1 0 100 1000000 %v 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0",
+		pid, comm, cpuTicks, rssPages)
+	writeFile(t, filepath.Join(prefix, "proc", fmt.Sprint(pid), "stat"), stat)
+	writeFile(t, filepath.Join(prefix, "proc", fmt.Sprint(pid), "cmdline"), cmdline)
+}
+
+func TestParseProcStat(t *testing.T) {
+	comm, ticks, rss, err := parseProcStat("42 (tmux: server (1)) S 1 42 42 0 -1 4194560 511 0 0 0 150 50 0 0 20 0 1 0 1234 8192000 256 18446744073709551615")
+	assert.NilError(t, err)
+	assert.Equal(t, "tmux: server (1)", comm)
+	assert.Equal(t, uint64(200), ticks)
+	assert.Equal(t, uint64(256), rss)
+
+	_, _, _, err = parseProcStat("42 tmux S 1")
+	assert.ErrorIs(t, err, ErrInvalidProcStat)
+}
+
+func TestProcess(t *testing.T) {
+	prefix := t.TempDir()
+	writeFile(t, filepath.Join(prefix, "proc/meminfo"), "MemTotal:        1000000 kB\nMemFree:          500000 kB\n")
+	writeProcess(t, prefix, 10, "tmux: server", "tmux\x00new-session\x00-d\x00", 0, 10)
+	writeProcess(t, prefix, 20, "python3", "/usr/bin/python3\x00/opt/bot/main.py\x00", 0, 1)
+	writeProcess(t, prefix, 21, "python3", "/usr/bin/python3\x00/opt/bot/main.py\x00", 0, 1)
+	// unparsable process is skipped, others are still checked
+	writeFile(t, filepath.Join(prefix, "proc/30/stat"), "30 (broken")
+	writeFile(t, filepath.Join(prefix, "proc/30/cmdline"), "broken\x00")
+
+	params := map[string]any{
+		"mountprefix": prefix,
+		"processes": map[string]any{
+			"tmux": map[string]any{"name": "tmux: server", "max_rss": "10k"},
+			"bot":  map[string]any{"regex": "/opt/bot/main\\.py", "max_count": uint64(1)},
+			"sync": map[string]any{"name": "syncthing"},
+			"big":  map[string]any{"name": "python3", "max_rss": "1%", "min_count": uint64(0)},
+		},
+	}
+	provider, err := NewProviderProcess(params)
+	assert.NilError(t, err)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("proc", resultChan)
+	taskList := provider.GetUpdateTaskList(context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, 1, len(taskList))
+	taskList[0]()
+
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "proc_list_processes").Status)
+
+	results := map[string]MetricState{}
+	for len(resultChan) > 0 {
+		if state, ok := (<-resultChan).(MetricState); ok {
+			results[state.MetricID] = state
+		}
+	}
+
+	assert.Equal(t, Healthy, results["proc_process_tmux_count"].Status)
+	assert.Equal(t, Unhealthy, results["proc_process_tmux_rss"].Status)
+	assert.Equal(t, Unhealthy, results["proc_process_bot_count"].Status)
+	assert.Equal(t, "2 matching processes (max 1)", results["proc_process_bot_count"].Description)
+	assert.Equal(t, Unhealthy, results["proc_process_sync_count"].Status)
+	assert.Equal(t, "no matching process", results["proc_process_sync_count"].Description)
+	assert.Equal(t, Healthy, results["proc_process_big_rss"].Status)
+	_, cpuChecked := results["proc_process_tmux_cpu"]
+	assert.Equal(t, false, cpuChecked)
+
+	// Invalid matcher
+	_, err = NewProviderProcess(map[string]any{"processes": map[string]any{"empty": map[string]any{}}})
+	assert.ErrorIs(t, err, ErrInvalidProcessMatcher)
+
+	// Name longer than comm can ever be
+	_, err = NewProviderProcess(map[string]any{"processes": map[string]any{"long": map[string]any{"name": "my-long-running-daemon"}}})
+	assert.ErrorIs(t, err, ErrProcessNameTooLong)
+}
@@ -0,0 +1,306 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/comparison"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/exposition"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/stats"
+)
+
+const prometheusMaxReportedSeries = 5
+
+var ErrInvalidSelector = errors.New("invalid series selector")
+
+// label matcher operators, longest first
+var labelMatcherOperatorList = []string{"!=", "=~", "!~", "="}
+
+type labelMatcher struct {
+	name     string
+	operator string
+	value    string
+	regex    *regexp.Regexp
+}
+
+func (matcher *labelMatcher) match(value string) bool {
+	switch matcher.operator {
+	case "=":
+		return value == matcher.value
+	case "!=":
+		return value != matcher.value
+	case "=~":
+		return matcher.regex.MatchString(value)
+	default: // !~
+		return !matcher.regex.MatchString(value)
+	}
+}
+
+// Series selector, ie. http_requests_total{code=~"5..",method!="get"}
+type seriesSelector struct {
+	name     string
+	matchers []labelMatcher
+}
+
+func parseLabelMatcher(content string) (labelMatcher, error) {
+	for i := range len(content) {
+		for _, operator := range labelMatcherOperatorList {
+			if !strings.HasPrefix(content[i:], operator) {
+				continue
+			}
+			value, err := strconv.Unquote(strings.TrimSpace(content[i+len(operator):]))
+			if err != nil {
+				return labelMatcher{}, fmt.Errorf("%w: %v (%v)", ErrInvalidSelector, content, err)
+			}
+			matcher := labelMatcher{name: strings.TrimSpace(content[:i]), operator: operator, value: value}
+			if operator == "=~" || operator == "!~" {
+				// anchored, as in PromQL
+				if matcher.regex, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+					return labelMatcher{}, err
+				}
+			}
+			return matcher, nil
+		}
+	}
+	return labelMatcher{}, fmt.Errorf("%w: %v", ErrInvalidSelector, content)
+}
+
+func parseSeriesSelector(selector string) (seriesSelector, error) {
+	name, matchersContent, hasMatchers := strings.Cut(selector, "{")
+	result := seriesSelector{name: strings.TrimSpace(name)}
+	if result.name == "" {
+		return seriesSelector{}, fmt.Errorf("%w: %v", ErrInvalidSelector, selector)
+	}
+	if !hasMatchers {
+		return result, nil
+	}
+	matchersContent, found := strings.CutSuffix(strings.TrimSpace(matchersContent), "}")
+	if !found {
+		return seriesSelector{}, fmt.Errorf("%w: %v", ErrInvalidSelector, selector)
+	}
+
+	// split on commas outside quotes
+	start, inQuotes := 0, false
+	for i := 0; i <= len(matchersContent); i++ {
+		switch {
+		case i < len(matchersContent) && matchersContent[i] == '\\' && inQuotes:
+			i++
+		case i < len(matchersContent) && matchersContent[i] == '"':
+			inQuotes = !inQuotes
+		case i == len(matchersContent) || (matchersContent[i] == ',' && !inQuotes):
+			if part := strings.TrimSpace(matchersContent[start:i]); part != "" {
+				matcher, err := parseLabelMatcher(part)
+				if err != nil {
+					return seriesSelector{}, err
+				}
+				result.matchers = append(result.matchers, matcher)
+			}
+			start = i + 1
+		}
+	}
+	return result, nil
+}
+
+func (selector *seriesSelector) match(sample exposition.Sample) bool {
+	if sample.Name != selector.name {
+		return false
+	}
+	for _, matcher := range selector.matchers {
+		if !matcher.match(sample.Labels[matcher.name]) {
+			return false
+		}
+	}
+	return true
+}
+
+type prometheusRule struct {
+	Expression   string `json:"expression"`                     // ie. rate(http_requests_total{code="500"}) > 1
+	FailIfAbsent bool   `json:"fail_if_absent" default:"false"` // labelled series are usually created lazily (no 500 yet)
+
+	comparison comparison.Comparison
+	selector   seriesSelector
+	rate       bool
+}
+
+type ProviderPrometheus struct {
+	URL        string                    `json:"url"`
+	Headers    map[string]string         `json:"headers" default:"{}"` // ie. Authorization: Bearer $TOKEN
+	Timeout    customtypes.Duration      `json:"timeout" default:"10s"`
+	RateWindow customtypes.Duration      `json:"rate_window" default:"5m"`
+	Rules      map[string]prometheusRule `json:"rules"`
+
+	httpClient  *http.Client
+	seriesStats map[string]*stats.WindowCollector[float64]
+}
+
+func NewProviderPrometheus(params map[string]any, scrapeInterval time.Duration) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderPrometheus](params)
+	if err != nil {
+		return nil, err
+	}
+	for name, rule := range cfg.Rules {
+		if !utils.IsNameValid(name) {
+			return nil, fmt.Errorf("forbidden characters in rule name '%v'", name)
+		}
+		if rule.comparison, err = comparison.Parse(rule.Expression); err != nil {
+			return nil, err
+		}
+		if _, err = strconv.ParseFloat(rule.comparison.Value, 64); err != nil {
+			return nil, fmt.Errorf("%w: '%v' in rule %v", comparison.ErrNotANumber, rule.comparison.Value, name)
+		}
+		selector := rule.comparison.Subject
+		if inner, found := strings.CutPrefix(selector, "rate("); found {
+			if selector, found = strings.CutSuffix(inner, ")"); !found {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSelector, rule.comparison.Subject)
+			}
+			rule.rate = true
+		}
+		if rule.selector, err = parseSeriesSelector(selector); err != nil {
+			return nil, err
+		}
+		cfg.Rules[name] = rule
+	}
+	if cfg.RateWindow.AsDuration() < scrapeInterval {
+		return nil, fmt.Errorf("%w: (%v < %v)", ErrInvalidRateWindow, cfg.RateWindow, scrapeInterval)
+	}
+	cfg.httpClient = &http.Client{Timeout: cfg.Timeout.AsDuration()}
+	cfg.seriesStats = make(map[string]*stats.WindowCollector[float64])
+	return &cfg, nil
+}
+
+func (provider *ProviderPrometheus) fetch(ctx context.Context) ([]exposition.Sample, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "text/plain;version=0.0.4")
+	for name, value := range provider.Headers {
+		request.Header.Set(name, value)
+	}
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.SafeClose(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", response.Status)
+	}
+	return exposition.Parse(response.Body)
+}
+
+// counterRate returns the per-second rate of a counter over the window, false until enough samples are collected
+func (provider *ProviderPrometheus) counterRate(key string, value float64) (float64, bool) {
+	collector, exists := provider.seriesStats[key]
+	// Counter reset (ie. restarted application)
+	if !exists || (collector.Count() > 0 && value < collector.Last().Data) {
+		newCollector := stats.MakeWindowCollector[float64](provider.RateWindow.AsDuration())
+		collector = &newCollector
+		provider.seriesStats[key] = collector
+	}
+	collector.AddNew(value)
+	if collector.Count() < 2 {
+		return 0, false
+	}
+	first, last := collector.First(), collector.Last()
+	elapsed := last.Timestamp.Sub(first.Timestamp).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (last.Data - first.Data) / elapsed, true
+}
+
+func (provider *ProviderPrometheus) evaluateRule(resultWrapper *ScrapeResultWrapper, name string, rule prometheusRule, samples []exposition.Sample, seenKeys map[string]bool) {
+	metric := resultWrapper.Metric("prometheus_"+name, "prometheus "+name)
+
+	matched, evaluated := 0, 0
+	violations := []string{}
+	for _, sample := range samples {
+		if !rule.selector.match(sample) {
+			continue
+		}
+		matched++
+		value := sample.Value
+		if rule.rate {
+			key := name + "/" + sample.Key()
+			seenKeys[key] = true
+			var ready bool
+			if value, ready = provider.counterRate(key, value); !ready {
+				continue
+			}
+		}
+		evaluated++
+		if rule.comparison.MatchNumber(value) {
+			violations = append(violations, fmt.Sprintf("%v = %.4g", sample.Key(), value))
+		}
+	}
+
+	switch {
+	case matched == 0 && rule.FailIfAbsent:
+		metric.PushFailure("no series matching %v", rule.comparison.Subject)
+	case matched == 0:
+		metric.PushOK("")
+	case len(violations) > 0:
+		slices.Sort(violations)
+		if len(violations) > prometheusMaxReportedSeries {
+			violations = append(violations[:prometheusMaxReportedSeries], fmt.Sprintf("and %v more", len(violations)-prometheusMaxReportedSeries))
+		}
+		metric.PushFailure("%v (%v)", rule.Expression, strings.Join(violations, ", "))
+	case evaluated > 0:
+		metric.PushOK("")
+	}
+	// otherwise, waiting for enough samples to compute rates
+}
+
+func (provider *ProviderPrometheus) update(ctx context.Context, resultWrapper *ScrapeResultWrapper) {
+	metricScrape := resultWrapper.Metric("prometheus", "prometheus ["+provider.URL+"]")
+	samples, err := provider.fetch(ctx)
+	if err != nil {
+		metricScrape.PushFailure("unable to scrape: %v", err)
+		return
+	}
+	metricScrape.PushOK("")
+
+	seenKeys := map[string]bool{}
+	for name, rule := range provider.Rules {
+		provider.evaluateRule(resultWrapper, name, rule, samples, seenKeys)
+	}
+
+	// Clean up stats of missing series
+	for key := range provider.seriesStats {
+		if !seenKeys[key] {
+			delete(provider.seriesStats, key)
+		}
+	}
+}
+
+func (provider *ProviderPrometheus) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			provider.update(ctx, resultWrapper)
+		},
+	}
+}
+
+func (*ProviderPrometheus) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderPrometheus) Destroy() {
+}
+
+func init() {
+	RegisterProvider("prometheus", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderPrometheus(cfg.Params, cfg.ScrapeInterval.AsDuration())
+	})
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/comparison"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/exposition"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseSeriesSelector(t *testing.T) {
+	selector, err := parseSeriesSelector(`http_requests_total{code=~"5..", method!="get", path="/a,b"}`)
+	assert.NilError(t, err)
+	assert.Equal(t, "http_requests_total", selector.name)
+	assert.Equal(t, 3, len(selector.matchers))
+
+	sample := exposition.Sample{Name: "http_requests_total", Labels: map[string]string{"code": "503", "method": "post", "path": "/a,b"}}
+	assert.Equal(t, true, selector.match(sample))
+	sample.Labels["code"] = "5030"
+	assert.Equal(t, false, selector.match(sample))
+	sample.Labels["code"] = "500"
+	sample.Labels["method"] = "get"
+	assert.Equal(t, false, selector.match(sample))
+
+	selector, err = parseSeriesSelector("up")
+	assert.NilError(t, err)
+	assert.Equal(t, true, selector.match(exposition.Sample{Name: "up"}))
+
+	_, err = parseSeriesSelector(`up{job="node"`)
+	assert.ErrorIs(t, err, ErrInvalidSelector)
+	_, err = parseSeriesSelector(`up{job}`)
+	assert.ErrorIs(t, err, ErrInvalidSelector)
+}
+
+func TestPrometheus(t *testing.T) {
+	errorCount := atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "# TYPE node_filesystem_readonly gauge\n")
+		fmt.Fprintf(w, "node_filesystem_readonly{mountpoint=\"/\"} 0\n")
+		fmt.Fprintf(w, "node_filesystem_readonly{mountpoint=\"/data\"} 1\n")
+		fmt.Fprintf(w, "# TYPE http_requests_total counter\n")
+		fmt.Fprintf(w, "http_requests_total{code=\"200\"} 1000\n")
+		fmt.Fprintf(w, "http_requests_total{code=\"500\"} %v\n", errorCount.Load())
+	}))
+	defer server.Close()
+
+	provider, err := NewProviderPrometheus(map[string]any{
+		"url":         server.URL,
+		"headers":     map[string]any{"Authorization": "Bearer secret"},
+		"rate_window": "1m",
+		"rules": map[string]any{
+			"readonly": map[string]any{"expression": "node_filesystem_readonly > 0"},
+			"errors":   map[string]any{"expression": `rate(http_requests_total{code=~"5.."}) > 10`},
+			"missing":  map[string]any{"expression": `up{job="app"} == 0`, "fail_if_absent": true},
+			"notfound": map[string]any{"expression": `rate(http_requests_total{code="404"}) > 1`},
+		},
+	}, time.Second)
+	assert.NilError(t, err)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("app", resultChan)
+	scrape := func() map[string]MetricState {
+		getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+		results := map[string]MetricState{}
+		for len(resultChan) > 0 {
+			if state, ok := (<-resultChan).(MetricState); ok {
+				results[state.MetricID] = state
+			}
+		}
+		return results
+	}
+
+	results := scrape()
+	assert.Equal(t, Healthy, results["app_prometheus"].Status)
+	assert.Equal(t, Unhealthy, results["app_prometheus_readonly"].Status)
+	assert.Equal(t, `node_filesystem_readonly > 0 (node_filesystem_readonly{mountpoint="/data"} = 1)`, results["app_prometheus_readonly"].Description)
+	assert.Equal(t, Unhealthy, results["app_prometheus_missing"].Status)
+	assert.Equal(t, `no series matching up{job="app"}`, results["app_prometheus_missing"].Description)
+	// series not created yet (no 404)
+	assert.Equal(t, Healthy, results["app_prometheus_notfound"].Status)
+	// rate requires two samples
+	_, evaluated := results["app_prometheus_errors"]
+	assert.Equal(t, false, evaluated)
+
+	time.Sleep(100 * time.Millisecond)
+	results = scrape()
+	assert.Equal(t, Healthy, results["app_prometheus_errors"].Status)
+
+	// about 100 errors per second
+	errorCount.Add(10)
+	time.Sleep(100 * time.Millisecond)
+	results = scrape()
+	assert.Equal(t, Unhealthy, results["app_prometheus_errors"].Status)
+
+	// counter reset
+	errorCount.Store(0)
+	results = scrape()
+	_, evaluated = results["app_prometheus_errors"]
+	assert.Equal(t, false, evaluated)
+
+	// Scrape failure
+	provider.(*ProviderPrometheus).Headers = map[string]string{}
+	results = scrape()
+	assert.Equal(t, Unhealthy, results["app_prometheus"].Status)
+	assert.Equal(t, "unable to scrape: unexpected status 401 Unauthorized", results["app_prometheus"].Description)
+}
+
+func TestPrometheusConfig(t *testing.T) {
+	_, err := NewProviderPrometheus(map[string]any{"url": "http://localhost", "rules": map[string]any{}}, 10*time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidRateWindow)
+	_, err = NewProviderPrometheus(map[string]any{"url": "http://localhost", "rules": map[string]any{
+		"bad": map[string]any{"expression": "rate(http_requests_total > 1"}}}, time.Second)
+	assert.ErrorIs(t, err, ErrInvalidSelector)
+	_, err = NewProviderPrometheus(map[string]any{"url": "http://localhost", "rules": map[string]any{
+		"bad": map[string]any{"expression": "up == down"}}}, time.Second)
+	assert.ErrorIs(t, err, comparison.ErrNotANumber)
+}
@@ -16,3 +16,16 @@ type Provider interface {
 	MultipleInstanceAllowed() bool
 	Destroy()
 }
+
+// Optional interface for providers able to push metrics as soon as an event occurs.
+// Periodic update tasks keep running and act as a reconciliation pass.
+type Watcher interface {
+	// Watch is called only once at startup, in its own goroutine, after GetUpdateTaskList.
+	// It must return once ctx is done.
+	Watch(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager)
+}
+
+// Optional interface for providers able to scrape a remote host (see Config.SSH).
+type RemoteProvider interface {
+	IsRemote() bool
+}
@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
+	"github.com/moby/sys/mountinfo"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrEventsOverSSH    = errors.New("events are not supported over ssh")
+	ErrUnexpectedOutput = errors.New("unexpected command output")
+)
+
+// Run commands on a remote host (see sshclient.Client)
+type CommandRunner interface {
+	Run(ctx context.Context, command string) ([]byte, error)
+	Close() error
+}
+
+// SystemdClient implementation based on `systemctl list-units --output=json`
+type remoteSystemdClient struct {
+	runner CommandRunner
+}
+
+type systemctlUnit struct {
+	Unit        string `json:"unit"`
+	Load        string `json:"load"`
+	Active      string `json:"active"`
+	Sub         string `json:"sub"`
+	Description string `json:"description"`
+}
+
+func (client *remoteSystemdClient) ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]dbus.UnitStatus, error) {
+	command := "systemctl list-units --all --no-pager --output=json"
+	if len(states) > 0 {
+		command += " --state=" + sshclient.Quote(strings.Join(states, ","))
+	}
+	for _, pattern := range patterns {
+		command += " " + sshclient.Quote(pattern)
+	}
+
+	output, err := client.runner.Run(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []systemctlUnit
+	err = json.Unmarshal(output, &units)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedOutput, err)
+	}
+
+	result := make([]dbus.UnitStatus, 0, len(units))
+	for _, unit := range units {
+		result = append(result, dbus.UnitStatus{
+			Name:        unit.Unit,
+			Description: unit.Description,
+			LoadState:   unit.Load,
+			ActiveState: unit.Active,
+			SubState:    unit.Sub,
+		})
+	}
+	return result, nil
+}
+
+func (*remoteSystemdClient) Subscribe() error {
+	return ErrEventsOverSSH
+}
+
+func (*remoteSystemdClient) SetPropertiesSubscriber(updateCh chan<- *dbus.PropertiesUpdate, errCh chan<- error) {
+}
+
+func (client *remoteSystemdClient) Close() {
+	// Drop the connection, next command will reconnect
+	_ = client.runner.Close()
+}
+
+// FileSystemClient implementation based on `df`
+type remoteFileSystemClient struct {
+	runner  CommandRunner
+	timeout time.Duration // a hung command (ie. stale NFS mount) must not block the scrape forever
+}
+
+// Parse `df -P` output, skipping header. Mountpoint is the last column and may contain spaces.
+func parseDf(output []byte, columns int) ([][]string, error) {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	result := [][]string{}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < columns {
+			return nil, fmt.Errorf("%w: '%v'", ErrUnexpectedOutput, line)
+		}
+		fields[columns-1] = strings.Join(fields[columns-1:], " ")
+		result = append(result, fields[:columns])
+	}
+	return result, nil
+}
+
+func (client *remoteFileSystemClient) run(ctx context.Context, command string) ([]byte, error) {
+	if client.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.timeout)
+		defer cancel()
+	}
+	return client.runner.Run(ctx, command)
+}
+
+func (client *remoteFileSystemClient) Statfs(ctx context.Context, path string, buf *unix.Statfs_t) error {
+	output, err := client.run(ctx, "df -P -B1 "+sshclient.Quote(path))
+	if err != nil {
+		return err
+	}
+	// Filesystem 1-blocks Used Available Capacity Mounted-on
+	rows, err := parseDf(output, 6)
+	if err != nil {
+		return err
+	}
+	if len(rows) != 1 {
+		return fmt.Errorf("%w: expected a single filesystem for %v", ErrUnexpectedOutput, path)
+	}
+
+	buf.Bsize = 1
+	buf.Blocks, err = strconv.ParseUint(rows[0][1], 10, 64)
+	if err == nil {
+		buf.Bavail, err = strconv.ParseUint(rows[0][3], 10, 64)
+	}
+	return err
+}
+
+func (client *remoteFileSystemClient) GetMounts(ctx context.Context, filter func(info *mountinfo.Info) (skip, stop bool)) ([]*mountinfo.Info, error) {
+	output, err := client.run(ctx, "df -P -T -B1")
+	if err != nil {
+		return nil, err
+	}
+	// Filesystem Type 1-blocks Used Available Capacity Mounted-on
+	rows, err := parseDf(output, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*mountinfo.Info{}
+	for _, row := range rows {
+		info := &mountinfo.Info{Source: row[0], FSType: row[1], Mountpoint: row[6]}
+		skip, stop := false, false
+		if filter != nil {
+			skip, stop = filter(info)
+		}
+		if !skip {
+			result = append(result, info)
+		}
+		if stop {
+			break
+		}
+	}
+	return result, nil
+}
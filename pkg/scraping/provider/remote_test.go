@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
+	"github.com/moby/sys/mountinfo"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+type mockCommandRunner struct {
+	RunFunc func(ctx context.Context, command string) ([]byte, error)
+}
+
+func (m *mockCommandRunner) Run(ctx context.Context, command string) ([]byte, error) {
+	if m.RunFunc != nil {
+		return m.RunFunc(ctx, command)
+	}
+	return nil, nil
+}
+
+func (m *mockCommandRunner) Close() error {
+	return nil
+}
+
+func TestRemoteSystemdListUnits(t *testing.T) {
+	runner := &mockCommandRunner{}
+	client := &remoteSystemdClient{runner: runner}
+
+	runner.RunFunc = func(ctx context.Context, command string) ([]byte, error) {
+		assert.Equal(t, "systemctl list-units --all --no-pager --output=json '*.service'", command)
+		return []byte(`[
+			{"unit":"ok.service","load":"loaded","active":"active","sub":"running","description":"OK service"},
+			{"unit":"ko.service","load":"loaded","active":"failed","sub":"failed","description":"KO service"}
+		]`), nil
+	}
+
+	units, err := client.ListUnitsByPatternsContext(context.Background(), []string{}, []string{"*.service"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []dbus.UnitStatus{
+		{Name: "ok.service", Description: "OK service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		{Name: "ko.service", Description: "KO service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+	}, units)
+
+	runner.RunFunc = func(ctx context.Context, command string) ([]byte, error) {
+		return []byte("Unknown option --output"), nil
+	}
+	_, err = client.ListUnitsByPatternsContext(context.Background(), []string{}, []string{"*.service"})
+	assert.Assert(t, errors.Is(err, ErrUnexpectedOutput))
+
+	assert.Assert(t, errors.Is(client.Subscribe(), ErrEventsOverSSH))
+}
+
+func TestRemoteFileSystem(t *testing.T) {
+	runner := &mockCommandRunner{}
+	client := &remoteFileSystemClient{runner: runner}
+
+	runner.RunFunc = func(ctx context.Context, command string) ([]byte, error) {
+		switch command {
+		case "df -P -T -B1":
+			return []byte(`Filesystem     Type     1-blocks       Used  Available Capacity Mounted on
+/dev/mmcblk0p2 ext4  31068966912 4929413120 24835391488      17% /
+tmpfs          tmpfs   194617344          0   194617344       0% /run
+/dev/sda1      btrfs 1000204886016 1000 1000204885016         1% /mnt/my data
+`), nil
+		case "df -P -B1 '/mnt/my data'":
+			return []byte(`Filesystem        1-blocks  Used     Available Capacity Mounted on
+/dev/sda1      1000204886016  1000 1000204885016         1% /mnt/my data
+`), nil
+		}
+		return nil, sshclient.ErrCommand
+	}
+
+	mounts, err := client.GetMounts(context.Background(), func(info *mountinfo.Info) (skip, stop bool) {
+		return info.FSType == "tmpfs", false
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []*mountinfo.Info{
+		{Source: "/dev/mmcblk0p2", FSType: "ext4", Mountpoint: "/"},
+		{Source: "/dev/sda1", FSType: "btrfs", Mountpoint: "/mnt/my data"},
+	}, mounts)
+
+	var stat unix.Statfs_t
+	assert.NilError(t, client.Statfs(context.Background(), "/mnt/my data", &stat))
+	assert.Equal(t, uint64(1000204886016), stat.Blocks*uint64(stat.Bsize))
+	assert.Equal(t, uint64(1000204885016), stat.Bavail*uint64(stat.Bsize))
+
+	assert.Assert(t, errors.Is(client.Statfs(context.Background(), "/missing", &stat), sshclient.ErrCommand))
+}
+
+func TestRemoteFileSystemTimeout(t *testing.T) {
+	runner := &mockCommandRunner{}
+	client := &remoteFileSystemClient{runner: runner, timeout: 10 * time.Millisecond}
+
+	// hung command (ie. stale NFS mount)
+	runner.RunFunc = func(ctx context.Context, command string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	var stat unix.Statfs_t
+	assert.Assert(t, errors.Is(client.Statfs(context.Background(), "/mnt/nfs", &stat), context.DeadlineExceeded))
+	_, err := client.GetMounts(context.Background(), nil)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+}
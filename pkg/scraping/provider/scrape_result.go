@@ -10,9 +10,11 @@ const (
 	Healthy MetricStatus = iota
 	Unhealthy
 	Removed
+	Warning // degraded but not yet failed (less severe than Unhealthy)
 )
 
 type MetricState struct {
+	Scraper     string
 	MetricID    string
 	Name        string
 	Status      MetricStatus
@@ -20,6 +22,7 @@ type MetricState struct {
 }
 
 type MetricMessage struct {
+	Scraper     string
 	MetricID    string
 	Name        string
 	Description string
@@ -57,6 +60,7 @@ func (wrapper *ScrapeResultWrapper) getFullID(metricId string) string {
 
 func (wrapper *ScrapeResultWrapper) pushState(metricId, name string, status MetricStatus, description string, args ...any) {
 	wrapper.resultChan <- MetricState{
+		Scraper:     wrapper.prefix,
 		MetricID:    wrapper.getFullID(metricId),
 		Name:        name,
 		Status:      status,
@@ -68,6 +72,10 @@ func (wrapper *MetricWrapper) PushFailure(description string, args ...any) {
 	wrapper.resultWrapper.pushState(wrapper.metricID, wrapper.name, Unhealthy, description, args...)
 }
 
+func (wrapper *MetricWrapper) PushWarning(description string, args ...any) {
+	wrapper.resultWrapper.pushState(wrapper.metricID, wrapper.name, Warning, description, args...)
+}
+
 func (wrapper *MetricWrapper) PushOK(description string, args ...any) {
 	wrapper.resultWrapper.pushState(wrapper.metricID, wrapper.name, Healthy, description, args...)
 }
@@ -78,6 +86,7 @@ func (wrapper *MetricWrapper) PushRemoved(description string, args ...any) {
 
 func (wrapper *MetricWrapper) PushMessage(description string, args ...any) {
 	wrapper.resultWrapper.resultChan <- MetricMessage{
+		Scraper:     wrapper.resultWrapper.prefix,
 		MetricID:    wrapper.resultWrapper.getFullID(wrapper.metricID),
 		Name:        wrapper.name,
 		Description: fmt.Sprintf(description, args...),
@@ -0,0 +1,293 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/comparison"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+const snmpSysUpTimeOID = "1.3.6.1.2.1.1.3.0"
+
+var (
+	ErrInvalidSNMPConfig     = errors.New("invalid snmp configuration")
+	ErrInvalidSNMPExpression = errors.New("snmp failure expression must compare 'value'")
+	ErrSNMPNoSuchObject      = errors.New("no such object")
+)
+
+var snmpAuthProtocolMap = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+}
+
+var snmpPrivacyProtocolMap = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C,
+	"AES256C": gosnmp.AES256C,
+}
+
+type snmpV3Config struct {
+	Username        string `json:"username"`
+	AuthProtocol    string `json:"auth_protocol" default:""` // empty means no authentication
+	AuthPassword    string `json:"auth_password" default:""`
+	PrivacyProtocol string `json:"privacy_protocol" default:""` // empty means no privacy
+	PrivacyPassword string `json:"privacy_password" default:""`
+}
+
+type snmpOID struct {
+	OID               string `json:"oid"`
+	Walk              bool   `json:"walk" default:"false"` // one metric per index under oid
+	LabelOID          string `json:"label_oid" default:""` // walked to name each index (ie. ifDescr)
+	FailureExpression string `json:"failure_expression"`   // ie. value != 1
+
+	failureComparison comparison.Comparison
+}
+
+type ProviderSNMP struct {
+	Target    string               `json:"target"`
+	Port      uint16               `json:"port" default:"161"`
+	Version   string               `json:"version" default:"2c"` // 2c or 3
+	Community string               `json:"community" default:"public"`
+	V3        *snmpV3Config        `json:"v3"`
+	Timeout   customtypes.Duration `json:"timeout" default:"5s"`
+	Retries   uint                 `json:"retries" default:"1"`
+	OIDs      map[string]snmpOID   `json:"oids"`
+
+	knownIndexList map[string][]string
+}
+
+func NewProviderSNMP(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderSNMP](params)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Version {
+	case "2c":
+	case "3":
+		if cfg.V3 == nil {
+			return nil, fmt.Errorf("%w: v3 settings are required with version 3", ErrInvalidSNMPConfig)
+		}
+		if _, exists := snmpAuthProtocolMap[cfg.V3.AuthProtocol]; cfg.V3.AuthProtocol != "" && !exists {
+			return nil, fmt.Errorf("%w: unknown auth_protocol %v", ErrInvalidSNMPConfig, cfg.V3.AuthProtocol)
+		}
+		if _, exists := snmpPrivacyProtocolMap[cfg.V3.PrivacyProtocol]; cfg.V3.PrivacyProtocol != "" && !exists {
+			return nil, fmt.Errorf("%w: unknown privacy_protocol %v", ErrInvalidSNMPConfig, cfg.V3.PrivacyProtocol)
+		}
+		if cfg.V3.PrivacyProtocol != "" && cfg.V3.AuthProtocol == "" {
+			return nil, fmt.Errorf("%w: privacy requires authentication", ErrInvalidSNMPConfig)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported version %v", ErrInvalidSNMPConfig, cfg.Version)
+	}
+
+	for name, oid := range cfg.OIDs {
+		if !utils.IsNameValid(name) {
+			return nil, fmt.Errorf("forbidden characters in oid name '%v'", name)
+		}
+		oid.failureComparison, err = comparison.Parse(oid.FailureExpression)
+		if err != nil {
+			return nil, err
+		}
+		if oid.failureComparison.Subject != "value" {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSNMPExpression, oid.FailureExpression)
+		}
+		oid.OID = strings.TrimPrefix(oid.OID, ".")
+		oid.LabelOID = strings.TrimPrefix(oid.LabelOID, ".")
+		cfg.OIDs[name] = oid
+	}
+	cfg.knownIndexList = make(map[string][]string)
+	return &cfg, nil
+}
+
+func (provider *ProviderSNMP) makeClient(ctx context.Context) *gosnmp.GoSNMP {
+	client := &gosnmp.GoSNMP{
+		Context:            ctx,
+		Target:             provider.Target,
+		Port:               provider.Port,
+		Transport:          "udp",
+		Community:          provider.Community,
+		Version:            gosnmp.Version2c,
+		Timeout:            provider.Timeout.AsDuration(),
+		Retries:            int(provider.Retries),
+		MaxOids:            gosnmp.MaxOids,
+		ExponentialTimeout: true,
+	}
+	if provider.Version == "3" {
+		msgFlags := gosnmp.NoAuthNoPriv
+		securityParameters := &gosnmp.UsmSecurityParameters{
+			UserName:               provider.V3.Username,
+			AuthenticationProtocol: gosnmp.NoAuth,
+			PrivacyProtocol:        gosnmp.NoPriv,
+		}
+		if provider.V3.AuthProtocol != "" {
+			msgFlags = gosnmp.AuthNoPriv
+			securityParameters.AuthenticationProtocol = snmpAuthProtocolMap[provider.V3.AuthProtocol]
+			securityParameters.AuthenticationPassphrase = provider.V3.AuthPassword
+		}
+		if provider.V3.PrivacyProtocol != "" {
+			msgFlags = gosnmp.AuthPriv
+			securityParameters.PrivacyProtocol = snmpPrivacyProtocolMap[provider.V3.PrivacyProtocol]
+			securityParameters.PrivacyPassphrase = provider.V3.PrivacyPassword
+		}
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = msgFlags
+		client.SecurityParameters = securityParameters
+	}
+	return client
+}
+
+func snmpValueString(pdu gosnmp.SnmpPDU) (string, error) {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return "", fmt.Errorf("%w: %v", ErrSNMPNoSuchObject, pdu.Name)
+	case gosnmp.OctetString:
+		value, _ := pdu.Value.([]byte)
+		return string(value), nil
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		return fmt.Sprint(pdu.Value), nil
+	default:
+		return gosnmp.ToBigInt(pdu.Value).String(), nil
+	}
+}
+
+func pushSNMPValue(metric MetricWrapper, oid snmpOID, pdu gosnmp.SnmpPDU) {
+	value, err := snmpValueString(pdu)
+	if err != nil {
+		metric.PushFailure("%v", err)
+		return
+	}
+	matched, err := oid.failureComparison.Match(value)
+	if err != nil {
+		metric.PushFailure("unable to evaluate '%v': %v", oid.FailureExpression, err)
+	} else if matched {
+		metric.PushFailure("%v (%v)", oid.FailureExpression, value)
+	} else {
+		metric.PushOK("")
+	}
+}
+
+// walk an oid, returning values by index (oid suffix)
+func walkIndexes(client *gosnmp.GoSNMP, rootOID string) (map[string]gosnmp.SnmpPDU, error) {
+	pduList, err := client.BulkWalkAll(rootOID)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]gosnmp.SnmpPDU, len(pduList))
+	for _, pdu := range pduList {
+		result[strings.TrimPrefix(pdu.Name, "."+rootOID+".")] = pdu
+	}
+	return result, nil
+}
+
+func (provider *ProviderSNMP) checkWalk(client *gosnmp.GoSNMP, resultWrapper *ScrapeResultWrapper, name string, oid snmpOID) {
+	metricWalk := resultWrapper.Metric("snmp_"+name, "snmp "+name)
+	pduMap, err := walkIndexes(client, oid.OID)
+	if err != nil {
+		metricWalk.PushFailure("unable to walk %v: %v", oid.OID, err)
+		return
+	}
+	labels := map[string]gosnmp.SnmpPDU{}
+	if oid.LabelOID != "" {
+		labels, err = walkIndexes(client, oid.LabelOID)
+		if err != nil {
+			metricWalk.PushFailure("unable to walk %v: %v", oid.LabelOID, err)
+			return
+		}
+	}
+	metricWalk.PushOK("")
+
+	indexList := make([]string, 0, len(pduMap))
+	for index, pdu := range pduMap {
+		indexList = append(indexList, index)
+		pushSNMPValue(provider.indexMetric(resultWrapper, name, index, labels), oid, pdu)
+	}
+
+	// Clean up missing indexes
+	for _, knownIndex := range provider.knownIndexList[name] {
+		if !slices.Contains(indexList, knownIndex) {
+			metric := provider.indexMetric(resultWrapper, name, knownIndex, labels)
+			metric.PushRemoved("oid removed")
+		}
+	}
+	provider.knownIndexList[name] = indexList
+}
+
+func (provider *ProviderSNMP) indexMetric(resultWrapper *ScrapeResultWrapper, name, index string, labels map[string]gosnmp.SnmpPDU) MetricWrapper {
+	label := index
+	if labelPDU, exists := labels[index]; exists {
+		if value, err := snmpValueString(labelPDU); err == nil {
+			label = value
+		}
+	}
+	return resultWrapper.Metric("snmp_"+name+"_"+index, "snmp "+name+" ["+label+"]")
+}
+
+func (provider *ProviderSNMP) update(ctx context.Context, resultWrapper *ScrapeResultWrapper) {
+	metricTarget := resultWrapper.Metric("snmp", "snmp ["+provider.Target+"]")
+	client := provider.makeClient(ctx)
+	if err := client.Connect(); err != nil {
+		metricTarget.PushFailure("unable to connect: %v", err)
+		return
+	}
+	defer utils.SafeClose(client.Conn)
+
+	// Probe target with sysUpTime
+	if _, err := client.Get([]string{snmpSysUpTimeOID}); err != nil {
+		metricTarget.PushFailure("unreachable: %v", err)
+		return
+	}
+	metricTarget.PushOK("")
+
+	for name, oid := range provider.OIDs {
+		if oid.Walk {
+			provider.checkWalk(client, resultWrapper, name, oid)
+			continue
+		}
+		metric := resultWrapper.Metric("snmp_"+name, "snmp "+name)
+		result, err := client.Get([]string{oid.OID})
+		if err != nil {
+			metric.PushFailure("unable to get %v: %v", oid.OID, err)
+		} else if len(result.Variables) != 1 {
+			metric.PushFailure("unable to get %v: %v", oid.OID, ErrSNMPNoSuchObject)
+		} else {
+			pushSNMPValue(metric, oid, result.Variables[0])
+		}
+	}
+}
+
+func (provider *ProviderSNMP) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			provider.update(ctx, resultWrapper)
+		},
+	}
+}
+
+func (*ProviderSNMP) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderSNMP) Destroy() {
+}
+
+func init() {
+	RegisterProvider("snmp", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderSNMP(cfg.Params)
+	})
+}
@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+func compareOID(a, b string) int {
+	aParts := strings.Split(strings.TrimPrefix(a, "."), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "."), ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aValue, _ := strconv.Atoi(aParts[i])
+		bValue, _ := strconv.Atoi(bParts[i])
+		if aValue != bValue {
+			return aValue - bValue
+		}
+	}
+	return len(aParts) - len(bParts)
+}
+
+// runSNMPAgentStub answers v2c Get/GetNext/GetBulk requests from a static mib
+func runSNMPAgentStub(t *testing.T, mib []gosnmp.SnmpPDU) (string, uint16) {
+	slices.SortFunc(mib, func(a, b gosnmp.SnmpPDU) int { return compareOID(a.Name, b.Name) })
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	next := func(oid string) gosnmp.SnmpPDU {
+		for _, pdu := range mib {
+			if compareOID(pdu.Name, oid) > 0 {
+				return pdu
+			}
+		}
+		return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView}
+	}
+	get := func(oid string) gosnmp.SnmpPDU {
+		for _, pdu := range mib {
+			if compareOID(pdu.Name, oid) == 0 {
+				return pdu
+			}
+		}
+		return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.NoSuchObject}
+	}
+
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public", Logger: gosnmp.Default.Logger}
+	go func() {
+		buffer := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			request, err := decoder.SnmpDecodePacket(buffer[:n])
+			if err != nil || request.Community != "public" {
+				continue
+			}
+			variables := []gosnmp.SnmpPDU{}
+			for _, variable := range request.Variables {
+				switch request.PDUType {
+				case gosnmp.GetRequest:
+					variables = append(variables, get(variable.Name))
+				case gosnmp.GetNextRequest:
+					variables = append(variables, next(variable.Name))
+				case gosnmp.GetBulkRequest:
+					oid := variable.Name
+					for range request.MaxRepetitions {
+						pdu := next(oid)
+						variables = append(variables, pdu)
+						if pdu.Type == gosnmp.EndOfMibView {
+							break
+						}
+						oid = pdu.Name
+					}
+				}
+			}
+			response := &gosnmp.SnmpPacket{
+				Version:   gosnmp.Version2c,
+				Community: request.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: request.RequestID,
+				Variables: variables,
+				Logger:    gosnmp.Default.Logger,
+			}
+			encoded, err := response.MarshalMsg()
+			if err == nil {
+				_, _ = conn.WriteTo(encoded, addr)
+			}
+		}
+	}()
+	address := conn.LocalAddr().(*net.UDPAddr)
+	return address.IP.String(), uint16(address.Port)
+}
+
+func TestSNMP(t *testing.T) {
+	mib := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(123456)},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("lan")},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: []byte("wan")},
+		{Name: ".1.3.6.1.2.1.2.2.1.8.1", Type: gosnmp.Integer, Value: 1},
+		{Name: ".1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: 2},
+		{Name: ".1.3.6.1.4.1.9999.1.0", Type: gosnmp.Gauge32, Value: uint(71)},
+		{Name: ".1.3.6.1.4.1.9999.2.0", Type: gosnmp.OctetString, Value: []byte("ok")},
+	}
+	target, port := runSNMPAgentStub(t, mib)
+
+	provider, err := NewProviderSNMP(map[string]any{
+		"target":  target,
+		"port":    uint64(port),
+		"timeout": "200ms",
+		"oids": map[string]any{
+			"interfaces":  map[string]any{"oid": ".1.3.6.1.2.1.2.2.1.8", "walk": true, "label_oid": "1.3.6.1.2.1.2.2.1.2", "failure_expression": "value != 1"},
+			"temperature": map[string]any{"oid": "1.3.6.1.4.1.9999.1.0", "failure_expression": "value > 70"},
+			"status":      map[string]any{"oid": "1.3.6.1.4.1.9999.2.0", "failure_expression": "value != ok"},
+			"missing":     map[string]any{"oid": "1.3.6.1.4.1.9999.3.0", "failure_expression": "value < 10"},
+		},
+	})
+	assert.NilError(t, err)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("router", resultChan)
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+
+	results := map[string]MetricState{}
+	for len(resultChan) > 0 {
+		if state, ok := (<-resultChan).(MetricState); ok {
+			results[state.MetricID] = state
+		}
+	}
+	assert.Equal(t, Healthy, results["router_snmp"].Status)
+	assert.Equal(t, Healthy, results["router_snmp_interfaces"].Status)
+	assert.Equal(t, Healthy, results["router_snmp_interfaces_1"].Status)
+	assert.Equal(t, "snmp interfaces [lan]", results["router_snmp_interfaces_1"].Name)
+	assert.Equal(t, Unhealthy, results["router_snmp_interfaces_2"].Status)
+	assert.Equal(t, "snmp interfaces [wan]", results["router_snmp_interfaces_2"].Name)
+	assert.Equal(t, "value != 1 (2)", results["router_snmp_interfaces_2"].Description)
+	assert.Equal(t, Unhealthy, results["router_snmp_temperature"].Status)
+	assert.Equal(t, "value > 70 (71)", results["router_snmp_temperature"].Description)
+	assert.Equal(t, Healthy, results["router_snmp_status"].Status)
+	assert.Equal(t, Unhealthy, results["router_snmp_missing"].Status)
+	assert.Equal(t, "no such object: .1.3.6.1.4.1.9999.3.0", results["router_snmp_missing"].Description)
+
+	// Unreachable target
+	provider.(*ProviderSNMP).Port = 1
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, Unhealthy, waitForMetricState(t, resultChan, "router_snmp").Status)
+}
+
+func TestSNMPConfig(t *testing.T) {
+	_, err := NewProviderSNMP(map[string]any{"target": "nas", "version": "1", "oids": map[string]any{}})
+	assert.ErrorIs(t, err, ErrInvalidSNMPConfig)
+	_, err = NewProviderSNMP(map[string]any{"target": "nas", "version": "3", "oids": map[string]any{}})
+	assert.ErrorIs(t, err, ErrInvalidSNMPConfig)
+	_, err = NewProviderSNMP(map[string]any{"target": "nas", "version": "3", "oids": map[string]any{},
+		"v3": map[string]any{"username": "monitoring", "privacy_protocol": "AES", "privacy_password": "secret"}})
+	assert.ErrorIs(t, err, ErrInvalidSNMPConfig)
+	_, err = NewProviderSNMP(map[string]any{"target": "nas", "oids": map[string]any{
+		"temperature": map[string]any{"oid": "1.3.6.1.4.1.9999.1.0", "failure_expression": "temperature > 70"}}})
+	assert.ErrorIs(t, err, ErrInvalidSNMPExpression)
+
+	provider, err := NewProviderSNMP(map[string]any{"target": "nas", "version": "3", "oids": map[string]any{},
+		"v3": map[string]any{"username": "monitoring", "auth_protocol": "SHA256", "auth_password": "secret1234", "privacy_protocol": "AES", "privacy_password": "secret5678"}})
+	assert.NilError(t, err)
+	client := provider.(*ProviderSNMP).makeClient(context.Background())
+	assert.Equal(t, gosnmp.AuthPriv, client.MsgFlags)
+	assert.Equal(t, gosnmp.SHA256, client.SecurityParameters.(*gosnmp.UsmSecurityParameters).AuthenticationProtocol)
+}
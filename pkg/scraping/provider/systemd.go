@@ -4,17 +4,24 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strings"
+	"sync"
 
 	"github.com/coreos/go-systemd/v22/dbus"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
 )
 
 const NB_RETRIES = 3
 
+const systemdPropertiesUpdateBuffer = 256
+
 type SystemdClient interface {
 	ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]dbus.UnitStatus, error)
+	Subscribe() error
+	SetPropertiesSubscriber(updateCh chan<- *dbus.PropertiesUpdate, errCh chan<- error)
 	Close()
 }
 
@@ -22,25 +29,57 @@ type ProviderSystemd struct {
 	client        SystemdClient
 	clientFactory func(context.Context) (SystemdClient, error)
 	knownUnitList []dbus.UnitStatus
+	sshClient     *sshclient.Client
+	Events        bool `json:"events" default:"false"` // subscribe to unit changes to push state changes immediately
+
+	mutex      sync.Mutex // update task and event handler may run concurrently
+	updateChan chan *dbus.PropertiesUpdate
+	errChan    chan error
 }
 
 func (provider *ProviderSystemd) reset(ctx context.Context) error {
 	var err error
 	provider.client.Close()
 	provider.client, err = provider.clientFactory(ctx)
+	if err == nil {
+		err = provider.subscribe()
+	}
 	return err
 }
 
+// (re)register the properties subscriber on the current connection, if watching
+func (provider *ProviderSystemd) subscribe() error {
+	if provider.updateChan == nil {
+		return nil
+	}
+	provider.client.SetPropertiesSubscriber(provider.updateChan, provider.errChan)
+	return provider.client.Subscribe()
+}
+
 func defaultSystemdFactory(ctx context.Context) (SystemdClient, error) {
 	return dbus.NewSystemdConnectionContext(ctx)
 }
 
-func NewProviderSystemd(ctx context.Context, params map[string]any) (Provider, error) {
+func NewProviderSystemd(ctx context.Context, params map[string]any, sshCfg *sshclient.Config) (Provider, error) {
 	cfg, err := configmapper.MapOnStruct[ProviderSystemd](params)
-	if err == nil {
+	if err != nil {
+		return nil, err
+	}
+	if sshCfg != nil {
+		if cfg.Events {
+			return nil, ErrEventsOverSSH
+		}
+		cfg.sshClient, err = sshclient.NewClient(*sshCfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.clientFactory = func(context.Context) (SystemdClient, error) {
+			return &remoteSystemdClient{runner: cfg.sshClient}, nil
+		}
+	} else {
 		cfg.clientFactory = defaultSystemdFactory
-		cfg.client, err = cfg.clientFactory(ctx)
 	}
+	cfg.client, err = cfg.clientFactory(ctx)
 	return &cfg, err
 }
 
@@ -79,9 +118,21 @@ func getServicePrettyName(unit dbus.UnitStatus) string {
 	}
 }
 
+func pushUnitState(resultWrapper *ScrapeResultWrapper, unit dbus.UnitStatus) {
+	metric := resultWrapper.Metric("systemd_"+unit.Name, getServicePrettyName(unit)+"@systemd")
+	if unit.ActiveState == "failed" {
+		metric.PushFailure("")
+	} else {
+		metric.PushOK("")
+	}
+}
+
 func (systemdProvider *ProviderSystemd) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
 	return UpdateTaskList{
 		func() {
+			systemdProvider.mutex.Lock()
+			defer systemdProvider.mutex.Unlock()
+
 			metricListServices := resultWrapper.Metric("list_services", "list services")
 			listOfUnits, err := systemdProvider.listServiceUnits(ctx)
 			if err != nil {
@@ -95,13 +146,7 @@ func (systemdProvider *ProviderSystemd) GetUpdateTaskList(ctx context.Context, r
 
 			for _, unit := range listOfUnits {
 				currentUnitsMap[unit.Name] = struct{}{}
-				prettyName := getServicePrettyName(unit)
-				metric := resultWrapper.Metric("systemd_"+unit.Name, prettyName+"@systemd")
-				if unit.ActiveState == "failed" {
-					metric.PushFailure("")
-				} else {
-					metric.PushOK("")
-				}
+				pushUnitState(resultWrapper, unit)
 			}
 
 			for _, knownUnit := range systemdProvider.knownUnitList {
@@ -116,8 +161,65 @@ func (systemdProvider *ProviderSystemd) GetUpdateTaskList(ctx context.Context, r
 	}
 }
 
-func (*ProviderSystemd) MultipleInstanceAllowed() bool {
-	return false
+func (systemdProvider *ProviderSystemd) handlePropertiesUpdate(resultWrapper *ScrapeResultWrapper, update *dbus.PropertiesUpdate) {
+	if !strings.HasSuffix(update.UnitName, ".service") {
+		return
+	}
+	activeState, ok := update.Changed["ActiveState"]
+	if !ok {
+		return
+	}
+
+	systemdProvider.mutex.Lock()
+	defer systemdProvider.mutex.Unlock()
+
+	for i := range systemdProvider.knownUnitList {
+		unit := &systemdProvider.knownUnitList[i]
+		if unit.Name == update.UnitName {
+			unit.ActiveState, _ = activeState.Value().(string)
+			logging.Debug("Unit %v is now %v", unit.Name, unit.ActiveState)
+			pushUnitState(resultWrapper, *unit)
+			return
+		}
+	}
+	// Unknown units lack description (pretty name) and removal tracking: next scrape will pick them up
+	logging.Debug("Ignoring update of unknown unit %v", update.UnitName)
+}
+
+func (systemdProvider *ProviderSystemd) Watch(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) {
+	if !systemdProvider.Events {
+		return
+	}
+
+	systemdProvider.mutex.Lock()
+	systemdProvider.updateChan = make(chan *dbus.PropertiesUpdate, systemdPropertiesUpdateBuffer)
+	systemdProvider.errChan = make(chan error, 1)
+	err := systemdProvider.subscribe()
+	systemdProvider.mutex.Unlock()
+	if err != nil {
+		// Next connection reset will try again
+		logging.Warning("unable to subscribe to unit changes: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-systemdProvider.updateChan:
+			systemdProvider.handlePropertiesUpdate(resultWrapper, update)
+		case err := <-systemdProvider.errChan:
+			// Updates were lost, periodic scraping will resync
+			logging.Warning("unit changes subscription: %v", err)
+		}
+	}
+}
+
+func (systemdProvider *ProviderSystemd) MultipleInstanceAllowed() bool {
+	return systemdProvider.sshClient != nil
+}
+
+func (systemdProvider *ProviderSystemd) IsRemote() bool {
+	return systemdProvider.sshClient != nil
 }
 
 func (systemdProvider *ProviderSystemd) Destroy() {
@@ -126,6 +228,6 @@ func (systemdProvider *ProviderSystemd) Destroy() {
 
 func init() {
 	RegisterProvider("systemd", func(ctx context.Context, cfg Config) (Provider, error) {
-		return NewProviderSystemd(ctx, cfg.Params)
+		return NewProviderSystemd(ctx, cfg.Params, cfg.SSH)
 	})
 }
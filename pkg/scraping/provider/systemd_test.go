@@ -6,13 +6,16 @@ import (
 	"testing"
 
 	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
 	"gotest.tools/v3/assert"
 )
 
 type mockSystemdClient struct {
-	ListUnitsFunc func(ctx context.Context, states []string, patterns []string) ([]dbus.UnitStatus, error)
-	CloseFunc     func()
+	ListUnitsFunc     func(ctx context.Context, states []string, patterns []string) ([]dbus.UnitStatus, error)
+	SubscribeFunc     func() error
+	SetSubscriberFunc func(updateCh chan<- *dbus.PropertiesUpdate, errCh chan<- error)
+	CloseFunc         func()
 }
 
 func (m *mockSystemdClient) ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]dbus.UnitStatus, error) {
@@ -22,6 +25,19 @@ func (m *mockSystemdClient) ListUnitsByPatternsContext(ctx context.Context, stat
 	return nil, nil
 }
 
+func (m *mockSystemdClient) Subscribe() error {
+	if m.SubscribeFunc != nil {
+		return m.SubscribeFunc()
+	}
+	return nil
+}
+
+func (m *mockSystemdClient) SetPropertiesSubscriber(updateCh chan<- *dbus.PropertiesUpdate, errCh chan<- error) {
+	if m.SetSubscriberFunc != nil {
+		m.SetSubscriberFunc(updateCh, errCh)
+	}
+}
+
 func (m *mockSystemdClient) Close() {
 	if m.CloseFunc != nil {
 		m.CloseFunc()
@@ -137,3 +153,56 @@ func TestSystemdDisappearance(t *testing.T) {
 	assert.Equal(t, Removed, metric.Status)
 	assert.Equal(t, "service removed", metric.Description)
 }
+
+func TestSystemdEvents(t *testing.T) {
+	mockClient := &mockSystemdClient{}
+	factory := func(ctx context.Context) (SystemdClient, error) { return mockClient, nil }
+	provider := &ProviderSystemd{client: mockClient, clientFactory: factory, knownUnitList: []dbus.UnitStatus{}, Events: true}
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("systemd", resultChan)
+
+	subscribed := make(chan chan<- *dbus.PropertiesUpdate, 1)
+	mockClient.SetSubscriberFunc = func(updateCh chan<- *dbus.PropertiesUpdate, errCh chan<- error) {
+		subscribed <- updateCh
+	}
+	mockClient.ListUnitsFunc = func(ctx context.Context, states []string, patterns []string) ([]dbus.UnitStatus, error) {
+		return []dbus.UnitStatus{{Name: "test.service", ActiveState: "active"}}, nil
+	}
+
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	drainChannel(resultChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		provider.Watch(ctx, &wrapper, storage.NewMemoryStorage())
+	}()
+	updateCh := <-subscribed
+
+	// 1. Unit enters failed state: pushed immediately
+	updateCh <- &dbus.PropertiesUpdate{UnitName: "test.service", Changed: map[string]godbus.Variant{"ActiveState": godbus.MakeVariant("failed")}}
+	metric := waitForMetricState(t, resultChan, "systemd_systemd_test.service")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "test.service@systemd", metric.Name)
+
+	// 2. Unrelated updates are ignored
+	updateCh <- &dbus.PropertiesUpdate{UnitName: "test.timer", Changed: map[string]godbus.Variant{"ActiveState": godbus.MakeVariant("failed")}}
+	updateCh <- &dbus.PropertiesUpdate{UnitName: "test.service", Changed: map[string]godbus.Variant{"SubState": godbus.MakeVariant("dead")}}
+	updateCh <- &dbus.PropertiesUpdate{UnitName: "unknown.service", Changed: map[string]godbus.Variant{"ActiveState": godbus.MakeVariant("failed")}}
+
+	// 3. Unit is active again
+	updateCh <- &dbus.PropertiesUpdate{UnitName: "test.service", Changed: map[string]godbus.Variant{"ActiveState": godbus.MakeVariant("active")}}
+	// Updates are handled in order: nothing was pushed for the ignored ones
+	metric = (<-resultChan).(MetricState)
+	assert.Equal(t, "systemd_systemd_test.service", metric.MetricID)
+	assert.Equal(t, Healthy, metric.Status)
+
+	// 4. Connection reset: subscription is registered again on the new connection
+	assert.NilError(t, provider.reset(context.Background()))
+	assert.Assert(t, <-subscribed != nil)
+
+	cancel()
+	<-done
+}
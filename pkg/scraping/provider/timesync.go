@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ntpPacketSize = 48
+	ntpEpochDelta = 2208988800 // seconds between 1900 (NTP epoch) and 1970 (unix epoch)
+	// Same threshold as systemd-timedated (NTPSynchronized)
+	maxSynchronizedError = 16 * time.Second
+)
+
+var (
+	ErrInvalidNTPResponse = errors.New("invalid NTP response")
+	ErrNTPKissOfDeath     = errors.New("NTP server refused the request (kiss of death)")
+)
+
+type ProviderTimeSync struct {
+	Synchronized bool                 `json:"synchronized" default:"true"` // check kernel clock synchronization status
+	Server       string               `json:"server" default:""`           // NTP server used to measure offset, empty means disabled
+	MaxOffset    customtypes.Duration `json:"max_offset" default:"1s"`
+	Timeout      customtypes.Duration `json:"timeout" default:"5s"`
+
+	adjtimex func(buf *unix.Timex) (int, error)
+}
+
+func NewProviderTimeSync(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderTimeSync](params)
+	if err != nil {
+		return nil, err
+	}
+	cfg.adjtimex = unix.Adjtimex
+	return &cfg, nil
+}
+
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochDelta)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ntpTime uint64) time.Time {
+	seconds := int64(ntpTime>>32) - ntpEpochDelta
+	nanoseconds := int64((ntpTime & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// sntpQuery returns the offset of the local clock compared to the server (RFC 4330)
+func sntpQuery(ctx context.Context, server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer utils.SafeClose(conn)
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+
+	request := make([]byte, ntpPacketSize)
+	request[0] = 4<<3 | 3 // LI = 0, version 4, client mode
+	sent := time.Now()
+	transmitTimestamp := toNTPTime(sent)
+	binary.BigEndian.PutUint64(request[40:], transmitTimestamp)
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+
+	response := make([]byte, ntpPacketSize)
+	n, err := conn.Read(response)
+	received := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	if n < ntpPacketSize || response[0]&0x7 != 4 {
+		return 0, ErrInvalidNTPResponse
+	}
+	if response[1] == 0 {
+		return 0, fmt.Errorf("%w: %v", ErrNTPKissOfDeath, string(response[12:16]))
+	}
+	if binary.BigEndian.Uint64(response[24:]) != transmitTimestamp {
+		return 0, fmt.Errorf("%w: originate timestamp mismatch", ErrInvalidNTPResponse)
+	}
+
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(response[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(response[40:]))
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+func (provider *ProviderTimeSync) checkSynchronized(resultWrapper *ScrapeResultWrapper) {
+	metric := resultWrapper.Metric("timesync_synchronized", "clock synchronization")
+	timex := unix.Timex{}
+	state, err := provider.adjtimex(&timex)
+	if err != nil {
+		metric.PushFailure("unable to read clock status: %v", err)
+	} else if state == unix.TIME_ERROR || timex.Status&unix.STA_UNSYNC != 0 {
+		metric.PushFailure("clock is not synchronized")
+	} else if maxError := time.Duration(timex.Maxerror) * time.Microsecond; maxError >= maxSynchronizedError {
+		metric.PushFailure("clock is not synchronized (estimated error %v)", maxError)
+	} else {
+		metric.PushOK("")
+	}
+}
+
+func (provider *ProviderTimeSync) checkOffset(ctx context.Context, resultWrapper *ScrapeResultWrapper) {
+	metric := resultWrapper.Metric("timesync_offset_"+provider.Server, "clock offset ["+provider.Server+"]")
+	offset, err := sntpQuery(ctx, provider.Server, provider.Timeout.AsDuration())
+	if err != nil {
+		metric.PushFailure("unable to query %v: %v", provider.Server, err)
+	} else if offset.Abs() > provider.MaxOffset.AsDuration() {
+		metric.PushFailure("clock offset is %v (max %v)", offset.Round(time.Millisecond), provider.MaxOffset)
+	} else {
+		metric.PushOK("")
+	}
+}
+
+func (provider *ProviderTimeSync) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	taskList := UpdateTaskList{}
+	if provider.Synchronized {
+		taskList = append(taskList, func() { provider.checkSynchronized(resultWrapper) })
+	}
+	if provider.Server != "" {
+		taskList = append(taskList, func() { provider.checkOffset(ctx, resultWrapper) })
+	}
+	return taskList
+}
+
+func (*ProviderTimeSync) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderTimeSync) Destroy() {
+}
+
+func init() {
+	RegisterProvider("timesync", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderTimeSync(cfg.Params)
+	})
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+// runFakeNTPServer answers SNTP requests with a clock shifted by offset
+func runFakeNTPServer(t *testing.T, offset time.Duration, stratum byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		request := make([]byte, ntpPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(request)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+			response := make([]byte, ntpPacketSize)
+			response[0] = 4<<3 | 4 // version 4, server mode
+			response[1] = stratum
+			copy(response[12:16], "RATE")
+			copy(response[24:32], request[40:48])
+			binary.BigEndian.PutUint64(response[32:], toNTPTime(time.Now().Add(offset)))
+			binary.BigEndian.PutUint64(response[40:], toNTPTime(time.Now().Add(offset)))
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNTPTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 15, 250000000, time.UTC)
+	assert.Assert(t, fromNTPTime(toNTPTime(now)).Sub(now).Abs() < time.Microsecond)
+}
+
+func TestSNTPQuery(t *testing.T) {
+	offset, err := sntpQuery(context.Background(), runFakeNTPServer(t, 10*time.Second, 2), time.Second)
+	assert.NilError(t, err)
+	assert.Assert(t, (offset-10*time.Second).Abs() < 100*time.Millisecond, "offset: %v", offset)
+
+	_, err = sntpQuery(context.Background(), runFakeNTPServer(t, 0, 0), time.Second)
+	assert.ErrorIs(t, err, ErrNTPKissOfDeath)
+}
+
+func TestTimeSync(t *testing.T) {
+	server := runFakeNTPServer(t, -3*time.Second, 1)
+	provider, err := NewProviderTimeSync(map[string]any{"server": server, "max_offset": "2s"})
+	assert.NilError(t, err)
+	timeSyncProvider := provider.(*ProviderTimeSync)
+
+	timeSyncProvider.adjtimex = func(buf *unix.Timex) (int, error) {
+		*buf = unix.Timex{Status: unix.STA_UNSYNC}
+		return unix.TIME_ERROR, nil
+	}
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("clock", resultChan)
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+
+	metric := waitForMetricState(t, resultChan, "clock_timesync_synchronized")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "clock is not synchronized", metric.Description)
+	metric = waitForMetricState(t, resultChan, "clock_timesync_offset_"+server)
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "clock offset is -3s (max 2s)", metric.Description)
+
+	timeSyncProvider.adjtimex = func(buf *unix.Timex) (int, error) {
+		*buf = unix.Timex{Maxerror: 50000}
+		return unix.TIME_OK, nil
+	}
+	timeSyncProvider.Server = runFakeNTPServer(t, 0, 1)
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "clock_timesync_synchronized").Status)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "clock_timesync_offset_"+timeSyncProvider.Server).Status)
+}
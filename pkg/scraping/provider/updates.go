@@ -0,0 +1,494 @@
+package provider
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+)
+
+var ErrUnknownPendingUpdates = errors.New("no package index available")
+
+var (
+	aptUpdatesRegex         = regexp.MustCompile(`(?m)^(\d+) (?:updates|packages) can be (?:applied|updated)`)
+	aptSecurityUpdatesRegex = regexp.MustCompile(`(?m)^(\d+) (?:of these )?updates are (?:standard )?security updates`)
+)
+
+type pendingUpdates struct {
+	total    uint
+	security uint
+}
+
+// packageManager reads pending updates from package manager caches, without running any command
+type packageManager struct {
+	name           string
+	detectPath     string
+	pendingUpdates func(prefix string) (pendingUpdates, error)
+}
+
+var packageManagerList = []packageManager{
+	{
+		name:           "apt",
+		detectPath:     "/var/lib/dpkg/status",
+		pendingUpdates: aptPendingUpdates,
+	},
+	{
+		name:           "apk",
+		detectPath:     "/lib/apk/db/installed",
+		pendingUpdates: apkPendingUpdates,
+	},
+}
+
+type ProviderUpdates struct {
+	MountPrefix     string `json:"mountprefix" default:""` // Host root filesytem when running inside a container
+	RebootRequired  bool   `json:"reboot_required" default:"true"`
+	SecurityUpdates bool   `json:"security_updates" default:"true"` // fail when security updates are pending
+	AllUpdates      bool   `json:"all_updates" default:"false"`     // fail when any update is pending
+}
+
+func NewProviderUpdates(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderUpdates](params)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (provider *ProviderUpdates) path(elem ...string) string {
+	return filepath.Join(append([]string{provider.MountPrefix}, elem...)...)
+}
+
+// aptPendingUpdates parses the summary maintained by update-notifier (apt hook), or compares
+// installed packages with apt lists when update-notifier isn't installed
+func aptPendingUpdates(prefix string) (pendingUpdates, error) {
+	content, err := os.ReadFile(filepath.Join(prefix, "/var/lib/update-notifier/updates-available"))
+	if errors.Is(err, os.ErrNotExist) {
+		return aptListsPendingUpdates(prefix)
+	}
+	if err != nil {
+		return pendingUpdates{}, err
+	}
+	updates := pendingUpdates{}
+	if match := aptUpdatesRegex.FindSubmatch(content); match != nil {
+		total, _ := strconv.ParseUint(string(match[1]), 10, 32)
+		updates.total = uint(total)
+	}
+	if match := aptSecurityUpdatesRegex.FindSubmatch(content); match != nil {
+		security, _ := strconv.ParseUint(string(match[1]), 10, 32)
+		updates.security = uint(security)
+	}
+	return updates, nil
+}
+
+// parseDpkgDatabase returns package versions from a dpkg database (status or apt list)
+func parseDpkgDatabase(reader io.Reader, installedOnly bool) (map[string]string, error) {
+	packages := map[string]string{}
+	name, version, installed := "", "", false
+	addPackage := func() {
+		if name != "" && version != "" && (installed || !installedOnly) {
+			packages[name] = version
+		}
+		name, version, installed = "", "", false
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1024*1024) // long descriptions
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, found := strings.CutPrefix(line, "Package: "); found {
+			name = value
+		} else if value, found := strings.CutPrefix(line, "Version: "); found {
+			version = value
+		} else if value, found := strings.CutPrefix(line, "Status: "); found {
+			installed = strings.HasSuffix(value, " installed")
+		} else if line == "" {
+			addPackage()
+		}
+	}
+	addPackage()
+	return packages, scanner.Err()
+}
+
+func readDpkgDatabase(path string, installedOnly bool) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.SafeClose(file)
+	return parseDpkgDatabase(file, installedOnly)
+}
+
+// aptListsPendingUpdates compares installed packages with uncompressed apt lists (`apt update`).
+// Pinning is ignored: the newest available version is the candidate. Lists from *-security suites
+// provide security updates.
+func aptListsPendingUpdates(prefix string) (pendingUpdates, error) {
+	listPathList, err := filepath.Glob(filepath.Join(prefix, "/var/lib/apt/lists/*_Packages"))
+	if err != nil {
+		return pendingUpdates{}, err
+	}
+	if len(listPathList) == 0 {
+		return pendingUpdates{}, ErrUnknownPendingUpdates
+	}
+	installed, err := readDpkgDatabase(filepath.Join(prefix, "/var/lib/dpkg/status"), true)
+	if err != nil {
+		return pendingUpdates{}, err
+	}
+
+	available := map[string]string{}
+	availableSecurity := map[string]string{}
+	for _, listPath := range listPathList {
+		list, err := readDpkgDatabase(listPath, false)
+		if err != nil {
+			return pendingUpdates{}, err
+		}
+		isSecurity := strings.Contains(filepath.Base(listPath), "-security_")
+		for name, version := range list {
+			if current, ok := available[name]; !ok || compareDebianVersion(version, current) > 0 {
+				available[name] = version
+			}
+			if current, ok := availableSecurity[name]; isSecurity && (!ok || compareDebianVersion(version, current) > 0) {
+				availableSecurity[name] = version
+			}
+		}
+	}
+
+	updates := pendingUpdates{}
+	for name, version := range installed {
+		if candidate, ok := available[name]; ok && compareDebianVersion(candidate, version) > 0 {
+			updates.total++
+		}
+		if candidate, ok := availableSecurity[name]; ok && compareDebianVersion(candidate, version) > 0 {
+			updates.security++
+		}
+	}
+	return updates, nil
+}
+
+// debianVersionOrder weights a character like dpkg: '~' sorts before anything, even the end of the string,
+// then letters, then other characters
+func debianVersionOrder(version string, i int) int {
+	if i >= len(version) {
+		return 0
+	}
+	c := version[i]
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func isDigitAt(version string, i int) bool {
+	return i < len(version) && version[i] >= '0' && version[i] <= '9'
+}
+
+// compareDebianVersionPart compares upstream versions or revisions (verrevcmp in dpkg)
+func compareDebianVersionPart(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigitAt(a, i)) || (j < len(b) && !isDigitAt(b, j)) {
+			if orderA, orderB := debianVersionOrder(a, i), debianVersionOrder(b, j); orderA != orderB {
+				return orderA - orderB
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for isDigitAt(a, i) && isDigitAt(b, j) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if isDigitAt(a, i) {
+			return 1
+		}
+		if isDigitAt(b, j) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+func splitDebianVersion(version string) (epoch uint64, upstream, revision string) {
+	if epochStr, rest, found := strings.Cut(version, ":"); found {
+		epoch, _ = strconv.ParseUint(epochStr, 10, 64)
+		version = rest
+	}
+	if index := strings.LastIndexByte(version, '-'); index >= 0 {
+		return epoch, version[:index], version[index+1:]
+	}
+	return epoch, version, ""
+}
+
+// compareDebianVersion returns a negative number when a is older than b, a positive one when newer (dpkg ordering)
+func compareDebianVersion(a, b string) int {
+	epochA, upstreamA, revisionA := splitDebianVersion(a)
+	epochB, upstreamB, revisionB := splitDebianVersion(b)
+	if epochA != epochB {
+		if epochA > epochB {
+			return 1
+		}
+		return -1
+	}
+	if result := compareDebianVersionPart(upstreamA, upstreamB); result != 0 {
+		return result
+	}
+	return compareDebianVersionPart(revisionA, revisionB)
+}
+
+// parseApkDatabase returns package versions from an apk database (installed or APKINDEX)
+func parseApkDatabase(reader io.Reader) (map[string]string, error) {
+	packages := map[string]string{}
+	name := ""
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, found := strings.CutPrefix(line, "P:"); found {
+			name = value
+		} else if value, found := strings.CutPrefix(line, "V:"); found && name != "" {
+			packages[name] = value
+		} else if line == "" {
+			name = ""
+		}
+	}
+	return packages, scanner.Err()
+}
+
+func readApkIndex(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.SafeClose(file)
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	// APKINDEX.tar.gz is made of concatenated gzip streams (signature, then index), read as a single tar archive
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			return nil, fmt.Errorf("APKINDEX not found in %v: %w", path, err)
+		}
+		if header.Name == "APKINDEX" {
+			return parseApkDatabase(tarReader)
+		}
+	}
+}
+
+// splitVersion splits a version into numeric and non-numeric tokens (1.2.3-r4 => 1 . 2 . 3 -r 4)
+func splitVersion(version string) []string {
+	tokens := []string{}
+	for i := 0; i < len(version); {
+		j := i
+		isDigit := version[i] >= '0' && version[i] <= '9'
+		for j < len(version) && (version[j] >= '0' && version[j] <= '9') == isDigit {
+			j++
+		}
+		tokens = append(tokens, version[i:j])
+		i = j
+	}
+	return tokens
+}
+
+// isVersionNewer reports whether candidate is newer than current (simplified apk version ordering)
+func isVersionNewer(candidate, current string) bool {
+	candidateTokens := splitVersion(candidate)
+	currentTokens := splitVersion(current)
+	for i := 0; i < len(candidateTokens) && i < len(currentTokens); i++ {
+		if candidateTokens[i] == currentTokens[i] {
+			continue
+		}
+		candidateNumber, errCandidate := strconv.ParseUint(candidateTokens[i], 10, 64)
+		currentNumber, errCurrent := strconv.ParseUint(currentTokens[i], 10, 64)
+		if errCandidate == nil && errCurrent == nil {
+			return candidateNumber > currentNumber
+		}
+		return candidateTokens[i] > currentTokens[i]
+	}
+	return len(candidateTokens) > len(currentTokens)
+}
+
+// apkPendingUpdates compares installed packages with cached indexes (apk doesn't track security updates)
+func apkPendingUpdates(prefix string) (pendingUpdates, error) {
+	file, err := os.Open(filepath.Join(prefix, "/lib/apk/db/installed"))
+	if err != nil {
+		return pendingUpdates{}, err
+	}
+	defer utils.SafeClose(file)
+	installed, err := parseApkDatabase(file)
+	if err != nil {
+		return pendingUpdates{}, err
+	}
+
+	indexPathList, err := filepath.Glob(filepath.Join(prefix, "/var/cache/apk/APKINDEX.*.tar.gz"))
+	if err != nil {
+		return pendingUpdates{}, err
+	}
+	available := map[string]string{}
+	for _, indexPath := range indexPathList {
+		index, err := readApkIndex(indexPath)
+		if err != nil {
+			return pendingUpdates{}, err
+		}
+		for name, version := range index {
+			if current, ok := available[name]; !ok || isVersionNewer(version, current) {
+				available[name] = version
+			}
+		}
+	}
+
+	updates := pendingUpdates{}
+	for name, version := range installed {
+		if candidate, ok := available[name]; ok && isVersionNewer(candidate, version) {
+			updates.total++
+		}
+	}
+	return updates, nil
+}
+
+// kernelRebootRequired follows `needs-restarting -r` semantics for the kernel: reboot is required when
+// the running kernel was removed or when the newest installed kernel isn't the running one.
+func (provider *ProviderUpdates) kernelRebootRequired() (string, error) {
+	release, err := os.ReadFile(provider.path("/proc/sys/kernel/osrelease"))
+	if err != nil {
+		return "", err
+	}
+	runningKernel := strings.TrimSpace(string(release))
+
+	for _, modulesDir := range []string{"/lib/modules", "/usr/lib/modules"} {
+		entries, err := os.ReadDir(provider.path(modulesDir))
+		if err != nil {
+			continue
+		}
+		runningFound := false
+		newestKernel := ""
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if entry.Name() == runningKernel {
+				runningFound = true
+			}
+			if newestKernel == "" || isVersionNewer(entry.Name(), newestKernel) {
+				newestKernel = entry.Name()
+			}
+		}
+		if !runningFound {
+			return fmt.Sprintf("running kernel %v is no longer installed", runningKernel), nil
+		}
+		if isVersionNewer(newestKernel, runningKernel) {
+			return fmt.Sprintf("kernel %v is newer than running kernel %v", newestKernel, runningKernel), nil
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+// debianRebootRequired checks the flag file created by update-notifier (and unattended-upgrades)
+func (provider *ProviderUpdates) debianRebootRequired() (string, bool) {
+	for _, runDir := range []string{"/run", "/var/run"} {
+		if _, err := os.Stat(provider.path(runDir, "reboot-required")); err == nil {
+			packages, _ := os.ReadFile(provider.path(runDir, "reboot-required.pkgs"))
+			packageList := strings.Fields(string(packages))
+			if len(packageList) == 0 {
+				return "reboot required", true
+			}
+			slices.Sort(packageList)
+			return fmt.Sprintf("reboot required by %v", strings.Join(slices.Compact(packageList), ", ")), true
+		}
+	}
+	return "", false
+}
+
+func (provider *ProviderUpdates) checkRebootRequired(resultWrapper *ScrapeResultWrapper) {
+	metric := resultWrapper.Metric("reboot_required", "reboot")
+	if reason, required := provider.debianRebootRequired(); required {
+		metric.PushFailure("%v", reason)
+		return
+	}
+	reason, err := provider.kernelRebootRequired()
+	if err != nil {
+		metric.PushFailure("unable to check running kernel: %v", err)
+	} else if reason != "" {
+		metric.PushFailure("reboot required (%v)", reason)
+	} else {
+		metric.PushOK("")
+	}
+}
+
+func (provider *ProviderUpdates) checkPendingUpdates(resultWrapper *ScrapeResultWrapper, manager packageManager) {
+	metric := resultWrapper.Metric("updates_"+manager.name, manager.name+" updates")
+	updates, err := manager.pendingUpdates(provider.MountPrefix)
+	if errors.Is(err, ErrUnknownPendingUpdates) {
+		metric.PushOK("pending updates unknown (%v)", err)
+	} else if err != nil {
+		metric.PushFailure("unable to read pending updates: %v", err)
+	} else if provider.SecurityUpdates && updates.security > 0 {
+		metric.PushFailure("%v pending security updates (%v pending updates)", updates.security, updates.total)
+	} else if provider.AllUpdates && updates.total > 0 {
+		metric.PushFailure("%v pending updates", updates.total)
+	} else {
+		metric.PushOK("")
+	}
+}
+
+func (provider *ProviderUpdates) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			if provider.RebootRequired {
+				provider.checkRebootRequired(resultWrapper)
+			}
+			if provider.SecurityUpdates || provider.AllUpdates {
+				for _, manager := range packageManagerList {
+					if _, err := os.Stat(provider.path(manager.detectPath)); err == nil {
+						provider.checkPendingUpdates(resultWrapper, manager)
+					}
+				}
+			}
+		},
+	}
+}
+
+func (*ProviderUpdates) MultipleInstanceAllowed() bool {
+	return false
+}
+
+func (*ProviderUpdates) Destroy() {
+}
+
+func init() {
+	RegisterProvider("updates", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderUpdates(cfg.Params)
+	})
+}
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+func writeApkIndex(t *testing.T, path, content string) {
+	buffer := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	assert.NilError(t, tarWriter.WriteHeader(&tar.Header{Name: "DESCRIPTION", Mode: 0o644, Size: 4}))
+	_, err := tarWriter.Write([]byte("main"))
+	assert.NilError(t, err)
+	assert.NilError(t, tarWriter.WriteHeader(&tar.Header{Name: "APKINDEX", Mode: 0o644, Size: int64(len(content))}))
+	_, err = tarWriter.Write([]byte(content))
+	assert.NilError(t, err)
+	assert.NilError(t, tarWriter.Close())
+	assert.NilError(t, gzipWriter.Close())
+	writeFile(t, path, buffer.String())
+}
+
+func writeKernel(t *testing.T, prefix, running string, installed ...string) {
+	writeFile(t, filepath.Join(prefix, "proc/sys/kernel/osrelease"), running+"\n")
+	for _, kernel := range installed {
+		assert.NilError(t, os.MkdirAll(filepath.Join(prefix, "lib/modules", kernel), 0o755))
+	}
+}
+
+func runUpdates(t *testing.T, params map[string]any) map[string]MetricState {
+	provider, err := NewProviderUpdates(params)
+	assert.NilError(t, err)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("updates", resultChan)
+	taskList := provider.GetUpdateTaskList(context.Background(), &wrapper, storage.NewMemoryStorage())
+	assert.Equal(t, 1, len(taskList))
+	taskList[0]()
+
+	results := map[string]MetricState{}
+	for len(resultChan) > 0 {
+		if state, ok := (<-resultChan).(MetricState); ok {
+			results[state.MetricID] = state
+		}
+	}
+	return results
+}
+
+func TestIsVersionNewer(t *testing.T) {
+	assert.Equal(t, true, isVersionNewer("1.2.4-r0", "1.2.3-r5"))
+	assert.Equal(t, true, isVersionNewer("1.2.3-r5", "1.2.3-r4"))
+	assert.Equal(t, true, isVersionNewer("1.10.0-r0", "1.9.0-r0"))
+	assert.Equal(t, true, isVersionNewer("1.2.3.1-r0", "1.2.3-r0"))
+	assert.Equal(t, false, isVersionNewer("1.2.3-r4", "1.2.3-r4"))
+	assert.Equal(t, false, isVersionNewer("1.9.0-r0", "1.10.0-r0"))
+}
+
+func TestCompareDebianVersion(t *testing.T) {
+	assert.Assert(t, compareDebianVersion("1.2.4-1", "1.2.3-5") > 0)
+	assert.Assert(t, compareDebianVersion("1.10-1", "1.9-1") > 0)
+	assert.Assert(t, compareDebianVersion("1:1.0-1", "2.0-1") > 0)
+	assert.Assert(t, compareDebianVersion("2.36-9+deb12u4", "2.36-9+deb12u3") > 0)
+	assert.Assert(t, compareDebianVersion("1.0~rc1-1", "1.0-1") < 0)
+	assert.Assert(t, compareDebianVersion("1.0a-1", "1.0-1") > 0)
+	assert.Assert(t, compareDebianVersion("1.0-1ubuntu1", "1.0-1") > 0)
+	assert.Equal(t, 0, compareDebianVersion("1.01-1", "1.1-1"))
+	assert.Equal(t, 0, compareDebianVersion("0:1.0", "1.0"))
+}
+
+func TestUpdatesAptLists(t *testing.T) {
+	prefix := t.TempDir()
+	writeKernel(t, prefix, "6.1.0-18-amd64", "6.1.0-18-amd64")
+	writeFile(t, filepath.Join(prefix, "var/lib/dpkg/status"),
+		"Package: libc6\nStatus: install ok installed\nVersion: 2.36-9+deb12u3\n\n"+
+			"Package: curl\nStatus: install ok installed\nVersion: 7.88.1-10+deb12u4\n\n"+
+			"Package: vim\nStatus: install ok installed\nVersion: 2:9.0.1378-2\n\n"+
+			"Package: nano\nStatus: deinstall ok config-files\nVersion: 7.2-1\n")
+
+	// No list (apt update never ran): unknown, not a failure
+	results := runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Healthy, results["updates_updates_apt"].Status)
+	assert.Equal(t, "pending updates unknown (no package index available)", results["updates_updates_apt"].Description)
+
+	listDir := filepath.Join(prefix, "var/lib/apt/lists")
+	writeFile(t, filepath.Join(listDir, "deb.debian.org_debian_dists_bookworm-updates_main_binary-amd64_Packages"),
+		"Package: curl\nVersion: 7.88.1-10+deb12u5\nDescription: command line tool\n multi-line description\n\n"+
+			"Package: vim\nVersion: 2:9.0.1378-2\n\n"+
+			"Package: nano\nVersion: 7.2-2\n")
+	results = runUpdates(t, map[string]any{"mountprefix": prefix, "all_updates": true})
+	assert.Equal(t, Unhealthy, results["updates_updates_apt"].Status)
+	assert.Equal(t, "1 pending updates", results["updates_updates_apt"].Description)
+
+	writeFile(t, filepath.Join(listDir, "deb.debian.org_debian-security_dists_bookworm-security_main_binary-amd64_Packages"),
+		"Package: libc6\nVersion: 2.36-9+deb12u4\n\nPackage: curl\nVersion: 7.88.1-10+deb12u2\n")
+	results = runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Unhealthy, results["updates_updates_apt"].Status)
+	assert.Equal(t, "1 pending security updates (2 pending updates)", results["updates_updates_apt"].Description)
+}
+
+func TestUpdatesApt(t *testing.T) {
+	prefix := t.TempDir()
+	writeKernel(t, prefix, "6.1.0-18-amd64", "6.1.0-17-amd64", "6.1.0-18-amd64")
+	writeFile(t, filepath.Join(prefix, "var/lib/dpkg/status"), "")
+	writeFile(t, filepath.Join(prefix, "var/lib/update-notifier/updates-available"),
+		"\n13 updates can be applied immediately.\n5 of these updates are standard security updates.\nTo see these additional updates run: apt list --upgradable\n")
+
+	results := runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Healthy, results["updates_reboot_required"].Status)
+	assert.Equal(t, Unhealthy, results["updates_updates_apt"].Status)
+	assert.Equal(t, "5 pending security updates (13 pending updates)", results["updates_updates_apt"].Description)
+	_, apkChecked := results["updates_updates_apk"]
+	assert.Equal(t, false, apkChecked)
+
+	writeFile(t, filepath.Join(prefix, "run/reboot-required"), "*** System restart required ***\n")
+	writeFile(t, filepath.Join(prefix, "run/reboot-required.pkgs"), "linux-image-6.1.0-18-amd64\nlibc6\nlibc6\n")
+	writeFile(t, filepath.Join(prefix, "var/lib/update-notifier/updates-available"), "\n0 updates can be applied immediately.\n")
+
+	results = runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Unhealthy, results["updates_reboot_required"].Status)
+	assert.Equal(t, "reboot required by libc6, linux-image-6.1.0-18-amd64", results["updates_reboot_required"].Description)
+	assert.Equal(t, Healthy, results["updates_updates_apt"].Status)
+}
+
+func TestUpdatesApk(t *testing.T) {
+	prefix := t.TempDir()
+	writeKernel(t, prefix, "6.6.14-0-lts", "6.6.14-0-lts")
+	writeFile(t, filepath.Join(prefix, "lib/apk/db/installed"), "C:Q1abc=\nP:musl\nV:1.2.4-r2\n\nP:openssl\nV:3.1.4-r5\n\nP:busybox\nV:1.36.1-r15\n")
+	writeApkIndex(t, filepath.Join(prefix, "var/cache/apk/APKINDEX.1234abcd.tar.gz"), "P:musl\nV:1.2.4-r3\n\nP:openssl\nV:3.1.4-r5\n\nP:busybox\nV:1.36.1-r14\n")
+
+	results := runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Healthy, results["updates_reboot_required"].Status)
+	// apk doesn't provide security information
+	assert.Equal(t, Healthy, results["updates_updates_apk"].Status)
+
+	results = runUpdates(t, map[string]any{"mountprefix": prefix, "all_updates": true})
+	assert.Equal(t, Unhealthy, results["updates_updates_apk"].Status)
+	assert.Equal(t, "1 pending updates", results["updates_updates_apk"].Description)
+
+	// Older kernel touched after boot (ie. dkms rebuild): no reboot required
+	assert.NilError(t, os.MkdirAll(filepath.Join(prefix, "lib/modules/6.6.9-0-lts"), 0o755))
+	results = runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Healthy, results["updates_reboot_required"].Status)
+
+	// Newer kernel installed
+	assert.NilError(t, os.MkdirAll(filepath.Join(prefix, "lib/modules/6.6.15-0-lts"), 0o755))
+	results = runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, Unhealthy, results["updates_reboot_required"].Status)
+	assert.Equal(t, "reboot required (kernel 6.6.15-0-lts is newer than running kernel 6.6.14-0-lts)", results["updates_reboot_required"].Description)
+
+	// Running kernel removed
+	assert.NilError(t, os.Remove(filepath.Join(prefix, "lib/modules/6.6.14-0-lts")))
+	writeKernel(t, prefix, "6.6.14-0-lts")
+	results = runUpdates(t, map[string]any{"mountprefix": prefix})
+	assert.Equal(t, "reboot required (running kernel 6.6.14-0-lts is no longer installed)", results["updates_reboot_required"].Description)
+}
@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/nutclient"
+)
+
+type UPSClient interface {
+	ListUPS(ctx context.Context) ([]string, error)
+	ListVar(ctx context.Context, ups string) (map[string]string, error)
+}
+
+type ProviderUPS struct {
+	client     UPSClient
+	Server     string               `json:"server" default:"localhost:3493"`
+	Username   string               `json:"username" default:""`
+	Password   string               `json:"password" default:""`
+	UPSList    []string             `json:"ups" default:"[]"` // empty means all UPS managed by upsd
+	MinCharge  uint                 `json:"min_charge" default:"50"`
+	MinRuntime customtypes.Duration `json:"min_runtime" default:"5m"`
+	Timeout    customtypes.Duration `json:"timeout" default:"10s"`
+
+	knownUPSList []string
+}
+
+func NewProviderUPS(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderUPS](params)
+	if err != nil {
+		return nil, err
+	}
+	cfg.client = nutclient.NewClient(cfg.Server, cfg.Username, cfg.Password, cfg.Timeout.AsDuration())
+	return &cfg, nil
+}
+
+// ups.status is a list of flags (ie. "OB DISCHRG LB")
+func upsStatusFlags(variables map[string]string) []string {
+	return strings.Fields(variables["ups.status"])
+}
+
+func (provider *ProviderUPS) upsMetrics(resultWrapper *ScrapeResultWrapper, ups string) (power, battery, replaceBattery MetricWrapper) {
+	return resultWrapper.Metric("ups_"+ups+"_power", "ups "+ups+" power"),
+		resultWrapper.Metric("ups_"+ups+"_battery", "ups "+ups+" battery"),
+		resultWrapper.Metric("ups_"+ups+"_replace_battery", "ups "+ups+" battery replacement")
+}
+
+func (provider *ProviderUPS) checkUPS(ctx context.Context, resultWrapper *ScrapeResultWrapper, ups string) {
+	metricPower, metricBattery, metricReplaceBattery := provider.upsMetrics(resultWrapper, ups)
+
+	variables, err := provider.client.ListVar(ctx, ups)
+	if err != nil {
+		metricPower.PushFailure("unable to read ups status: %v", err)
+		return
+	}
+	flags := upsStatusFlags(variables)
+	charge, chargeErr := strconv.ParseFloat(variables["battery.charge"], 64)
+	runtimeSeconds, runtimeErr := strconv.ParseFloat(variables["battery.runtime"], 64)
+	runtime := time.Duration(runtimeSeconds * float64(time.Second))
+
+	if slices.Contains(flags, "OB") {
+		details := []string{}
+		if chargeErr == nil {
+			details = append(details, fmt.Sprintf("charge %.0f%%", charge))
+		}
+		if runtimeErr == nil {
+			details = append(details, fmt.Sprintf("runtime %v", runtime))
+		}
+		if len(details) > 0 {
+			metricPower.PushFailure("on battery (%v)", strings.Join(details, ", "))
+		} else {
+			metricPower.PushFailure("on battery")
+		}
+	} else {
+		metricPower.PushOK("")
+	}
+
+	problems := []string{}
+	if slices.Contains(flags, "LB") {
+		problems = append(problems, "battery low")
+	}
+	if chargeErr == nil && charge < float64(provider.MinCharge) {
+		problems = append(problems, fmt.Sprintf("charge %.0f%% (min %v%%)", charge, provider.MinCharge))
+	}
+	if runtimeErr == nil && runtime < provider.MinRuntime.AsDuration() {
+		problems = append(problems, fmt.Sprintf("runtime %v (min %v)", runtime, provider.MinRuntime))
+	}
+	if len(problems) > 0 {
+		metricBattery.PushFailure("%v", strings.Join(problems, ", "))
+	} else {
+		metricBattery.PushOK("")
+	}
+
+	if slices.Contains(flags, "RB") {
+		metricReplaceBattery.PushFailure("battery needs to be replaced")
+	} else {
+		metricReplaceBattery.PushOK("")
+	}
+}
+
+func (provider *ProviderUPS) update(ctx context.Context, resultWrapper *ScrapeResultWrapper) {
+	upsList := provider.UPSList
+	if len(upsList) == 0 {
+		metricList := resultWrapper.Metric("list_ups", "list ups")
+		var err error
+		upsList, err = provider.client.ListUPS(ctx)
+		if err != nil {
+			metricList.PushFailure("unable to list ups: %v", err)
+			return
+		}
+		metricList.PushOK("")
+	}
+
+	for _, ups := range upsList {
+		provider.checkUPS(ctx, resultWrapper, ups)
+	}
+
+	// Clean up missing ups
+	for _, knownUPS := range provider.knownUPSList {
+		if !slices.Contains(upsList, knownUPS) {
+			metricPower, metricBattery, metricReplaceBattery := provider.upsMetrics(resultWrapper, knownUPS)
+			metricPower.PushRemoved("ups removed")
+			metricBattery.PushRemoved("ups removed")
+			metricReplaceBattery.PushRemoved("ups removed")
+		}
+	}
+	provider.knownUPSList = upsList
+}
+
+func (provider *ProviderUPS) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			provider.update(ctx, resultWrapper)
+		},
+	}
+}
+
+func (*ProviderUPS) MultipleInstanceAllowed() bool {
+	return true
+}
+
+func (*ProviderUPS) Destroy() {
+}
+
+func init() {
+	RegisterProvider("ups", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderUPS(cfg.Params)
+	})
+}
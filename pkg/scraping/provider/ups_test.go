@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+type mockUPSClient struct {
+	ListUPSFunc func(ctx context.Context) ([]string, error)
+	ListVarFunc func(ctx context.Context, ups string) (map[string]string, error)
+}
+
+func (m *mockUPSClient) ListUPS(ctx context.Context) ([]string, error) {
+	if m.ListUPSFunc != nil {
+		return m.ListUPSFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockUPSClient) ListVar(ctx context.Context, ups string) (map[string]string, error) {
+	if m.ListVarFunc != nil {
+		return m.ListVarFunc(ctx, ups)
+	}
+	return nil, nil
+}
+
+func TestUPS(t *testing.T) {
+	upsList := []string{"myups"}
+	variables := map[string]string{
+		"battery.charge":  "100",
+		"battery.runtime": "1800",
+		"ups.status":      "OL",
+	}
+	mockClient := &mockUPSClient{
+		ListUPSFunc: func(ctx context.Context) ([]string, error) {
+			return upsList, nil
+		},
+		ListVarFunc: func(ctx context.Context, ups string) (map[string]string, error) {
+			if ups != "myups" {
+				return nil, errors.New("ERR UNKNOWN-UPS")
+			}
+			return variables, nil
+		},
+	}
+
+	provider, err := NewProviderUPS(map[string]any{"min_charge": uint64(40)})
+	assert.NilError(t, err)
+	upsProvider := provider.(*ProviderUPS)
+	upsProvider.client = mockClient
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("power", resultChan)
+	memoryStorage := storage.NewMemoryStorage()
+
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "power_list_ups").Status)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "power_ups_myups_power").Status)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "power_ups_myups_battery").Status)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "power_ups_myups_replace_battery").Status)
+
+	// Power outage
+	variables = map[string]string{
+		"battery.charge":  "35",
+		"battery.runtime": "240",
+		"ups.status":      "OB DISCHRG LB RB",
+	}
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	metric := waitForMetricState(t, resultChan, "power_ups_myups_power")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "on battery (charge 35%, runtime 4m0s)", metric.Description)
+	metric = waitForMetricState(t, resultChan, "power_ups_myups_battery")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "battery low, charge 35% (min 40%), runtime 4m0s (min 5m0s)", metric.Description)
+	metric = waitForMetricState(t, resultChan, "power_ups_myups_replace_battery")
+	assert.Equal(t, Unhealthy, metric.Status)
+
+	// Mains power is back
+	variables = map[string]string{
+		"battery.charge":  "60",
+		"battery.runtime": "900",
+		"ups.status":      "OL CHRG",
+	}
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "power_ups_myups_power").Status)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "power_ups_myups_battery").Status)
+
+	// UPS removed
+	upsList = []string{}
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	assert.Equal(t, Removed, waitForMetricState(t, resultChan, "power_ups_myups_power").Status)
+	assert.Equal(t, Removed, waitForMetricState(t, resultChan, "power_ups_myups_battery").Status)
+	assert.Equal(t, Removed, waitForMetricState(t, resultChan, "power_ups_myups_replace_battery").Status)
+
+	// Configured ups, unknown to upsd
+	upsProvider.UPSList = []string{"otherups"}
+	drainChannel(resultChan)
+	getAndExecuteTaskList(provider, context.Background(), &wrapper, memoryStorage)
+	metric = waitForMetricState(t, resultChan, "power_ups_otherups_power")
+	assert.Equal(t, Unhealthy, metric.Status)
+	assert.Equal(t, "unable to read ups status: ERR UNKNOWN-UPS", metric.Description)
+}
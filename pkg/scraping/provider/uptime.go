@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper"
+)
+
+var ErrInvalidProcStatBootTime = errors.New("btime not found in /proc/stat")
+
+const (
+	uptimeBootIDKey   = "boot_id"
+	uptimeBootTimeKey = "boot_time"
+	uptimeLastSeenKey = "last_seen"
+)
+
+type ProviderUptime struct {
+	MountPrefix   string `json:"mountprefix" default:""`      // Host root filesytem when running inside a container
+	MaxUptimeDays uint   `json:"max_uptime_days" default:"0"` // 0 means disabled
+}
+
+func NewProviderUptime(params map[string]any) (Provider, error) {
+	cfg, err := configmapper.MapOnStruct[ProviderUptime](params)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func readBootTime(prefix string) (time.Time, error) {
+	file, err := os.Open(filepath.Join(prefix, "/proc/stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer utils.SafeClose(file)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), "btime "); found {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return time.Unix(seconds, 0), err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, ErrInvalidProcStatBootTime
+}
+
+// formatUptime formats a duration with days (ie. 12d 3h 4m)
+func formatUptime(duration time.Duration) string {
+	duration = duration.Round(time.Minute)
+	days := duration / (24 * time.Hour)
+	hours := (duration % (24 * time.Hour)) / time.Hour
+	minutes := (duration % time.Hour) / time.Minute
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+func loadTime(storage storage.Storager, key string) (time.Time, bool) {
+	value, exists := storage.Get(key)
+	if !exists {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+func (provider *ProviderUptime) update(resultWrapper *ScrapeResultWrapper, storage storage.Storager, now time.Time) {
+	metricBoot := resultWrapper.Metric("boot", "host")
+
+	bootID, err := os.ReadFile(filepath.Join(provider.MountPrefix, "/proc/sys/kernel/random/boot_id"))
+	if err != nil {
+		metricBoot.PushFailure("unable to read boot id: %v", err)
+		return
+	}
+	bootTime, err := readBootTime(provider.MountPrefix)
+	if err != nil {
+		metricBoot.PushFailure("unable to read boot time: %v", err)
+		return
+	}
+	metricBoot.PushOK("")
+
+	previousBootID, known := storage.Get(uptimeBootIDKey)
+	if known && previousBootID != strings.TrimSpace(string(bootID)) {
+		previousBootTime, bootTimeKnown := loadTime(storage, uptimeBootTimeKey)
+		lastSeen, lastSeenKnown := loadTime(storage, uptimeLastSeenKey)
+		if bootTimeKnown && lastSeenKnown {
+			metricBoot.PushMessage("host rebooted at %v (previous uptime %v)", bootTime.Format(time.DateTime), formatUptime(lastSeen.Sub(previousBootTime)))
+		} else {
+			metricBoot.PushMessage("host rebooted at %v", bootTime.Format(time.DateTime))
+		}
+	}
+	storage.Set(uptimeBootIDKey, strings.TrimSpace(string(bootID)))
+	storage.Set(uptimeBootTimeKey, strconv.FormatInt(bootTime.Unix(), 10))
+	storage.Set(uptimeLastSeenKey, strconv.FormatInt(now.Unix(), 10))
+
+	if provider.MaxUptimeDays > 0 {
+		metricUptime := resultWrapper.Metric("uptime", "uptime")
+		uptime := now.Sub(bootTime)
+		if uptime > time.Duration(provider.MaxUptimeDays)*24*time.Hour {
+			metricUptime.PushFailure("host is up since %v (max %v days), consider rebooting to apply kernel updates", formatUptime(uptime), provider.MaxUptimeDays)
+		} else {
+			metricUptime.PushOK("")
+		}
+	}
+}
+
+func (provider *ProviderUptime) GetUpdateTaskList(ctx context.Context, resultWrapper *ScrapeResultWrapper, storage storage.Storager) UpdateTaskList {
+	return UpdateTaskList{
+		func() {
+			provider.update(resultWrapper, storage, time.Now())
+		},
+	}
+}
+
+func (*ProviderUptime) MultipleInstanceAllowed() bool {
+	return false
+}
+
+func (*ProviderUptime) Destroy() {
+}
+
+func init() {
+	RegisterProvider("uptime", func(ctx context.Context, cfg Config) (Provider, error) {
+		return NewProviderUptime(cfg.Params)
+	})
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/storage"
+	"gotest.tools/v3/assert"
+)
+
+func writeBoot(t *testing.T, prefix, bootID string, bootTime time.Time) {
+	writeFile(t, filepath.Join(prefix, "proc/sys/kernel/random/boot_id"), bootID+"\n")
+	writeFile(t, filepath.Join(prefix, "proc/stat"), fmt.Sprintf("cpu  1 2 3 4\nbtime %v\nprocesses 42\n", bootTime.Unix()))
+}
+
+func TestFormatUptime(t *testing.T) {
+	assert.Equal(t, "0h 5m", formatUptime(5*time.Minute))
+	assert.Equal(t, "12d 3h 4m", formatUptime(12*24*time.Hour+3*time.Hour+4*time.Minute+10*time.Second))
+}
+
+func TestUptime(t *testing.T) {
+	prefix := t.TempDir()
+	firstBoot := time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+	writeBoot(t, prefix, "a1b2c3d4-0000-0000-0000-000000000001", firstBoot)
+
+	provider, err := NewProviderUptime(map[string]any{"mountprefix": prefix, "max_uptime_days": uint64(30)})
+	assert.NilError(t, err)
+	uptimeProvider := provider.(*ProviderUptime)
+
+	resultChan := make(chan any, 100)
+	wrapper := MakeScrapeResultWrapper("host", resultChan)
+	providerStorage := storage.NewMemoryStorage()
+
+	// First run: nothing to compare with
+	uptimeProvider.update(&wrapper, providerStorage, firstBoot.Add(time.Hour))
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "host_boot").Status)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "host_uptime").Status)
+	assert.Equal(t, 0, len(resultChan))
+
+	// Monitor restarted, host didn't reboot
+	uptimeProvider.update(&wrapper, providerStorage, firstBoot.Add(40*24*time.Hour))
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "host_boot").Status)
+	metricUptime := waitForMetricState(t, resultChan, "host_uptime")
+	assert.Equal(t, Unhealthy, metricUptime.Status)
+	assert.Equal(t, "host is up since 40d 0h 0m (max 30 days), consider rebooting to apply kernel updates", metricUptime.Description)
+	assert.Equal(t, 0, len(resultChan))
+
+	// Host rebooted
+	secondBoot := firstBoot.Add(41 * 24 * time.Hour)
+	writeBoot(t, prefix, "a1b2c3d4-0000-0000-0000-000000000002", secondBoot)
+	uptimeProvider.update(&wrapper, providerStorage, secondBoot.Add(time.Minute))
+	message := waitForMessage(t, resultChan, "host_boot")
+	assert.Equal(t, fmt.Sprintf("host rebooted at %v (previous uptime 40d 0h 0m)", secondBoot.Format(time.DateTime)), message.Description)
+	assert.Equal(t, Healthy, waitForMetricState(t, resultChan, "host_uptime").Status)
+}
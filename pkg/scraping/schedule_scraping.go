@@ -2,6 +2,7 @@ package scraping
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/logging"
@@ -19,6 +20,7 @@ func ScheduleScraping(ctx context.Context, providerCfgList map[string]provider.C
 
 	providerList := make([]provider.Provider, 0, len(providerCfgList))
 	instanciatedProviderTypeMap := map[string]int{}
+	watcherWg := sync.WaitGroup{}
 	// Load and schedule providers
 	for providerName, providerCfg := range providerCfgList {
 		if !utils.IsNameValid(providerName) {
@@ -32,19 +34,26 @@ func ScheduleScraping(ctx context.Context, providerCfgList map[string]provider.C
 		}
 
 		providerList = append(providerList, providerInstance)
-		instanciatedProviderTypeMap[providerCfg.Type]++
 		if !providerInstance.MultipleInstanceAllowed() {
+			instanciatedProviderTypeMap[providerCfg.Type]++
 			if instanciatedProviderTypeMap[providerCfg.Type] >= 2 {
 				logging.Fatal("Cannot instantiate provider %v multiple times", providerCfg.Type)
 			}
 		}
 		resultWrapper := provider.MakeScrapeResultWrapper(providerName, resultChan)
+		providerStorage := storage.NewSubStorage(storageInstance, providerName)
 
-		updateTaskList := providerInstance.GetUpdateTaskList(ctx, &resultWrapper, storage.NewSubStorage(storageInstance, providerName))
+		updateTaskList := providerInstance.GetUpdateTaskList(ctx, &resultWrapper, providerStorage)
 
 		for _, updateTask := range updateTaskList {
 			taskList = append(taskList, scheduler.MakePeriodicTask(updateTask, providerCfg.ScrapeInterval.AsDuration()))
 		}
+
+		if watcher, ok := providerInstance.(provider.Watcher); ok {
+			watcherWg.Go(func() {
+				watcher.Watch(ctx, &resultWrapper, providerStorage)
+			})
+		}
 	}
 
 	scheduler := scheduler.MakeScheduler(taskList)
@@ -52,6 +61,7 @@ func ScheduleScraping(ctx context.Context, providerCfgList map[string]provider.C
 	logging.Info("Start collecting metrics (%d providers, %d max threads)", len(providerCfgList), maxParallelScrapingJobs)
 	scheduler.ScheduleAsync(ctx, maxParallelScrapingJobs)
 	logging.Info("Exiting scraping...")
+	watcherWg.Wait()
 	for _, providerInstance := range providerList {
 		providerInstance.Destroy()
 	}
@@ -0,0 +1,38 @@
+package utils
+
+import "time"
+
+// Clock provides the current time and tickers (injectable for tests)
+type Clock interface {
+	Now() time.Time
+	NewTicker(interval time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(interval time.Duration) Ticker {
+	return systemTicker{time.NewTicker(interval)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (ticker systemTicker) C() <-chan time.Time {
+	return ticker.ticker.C
+}
+
+func (ticker systemTicker) Stop() {
+	ticker.ticker.Stop()
+}
+
+var SystemClock Clock = systemClock{}
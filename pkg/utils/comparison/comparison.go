@@ -0,0 +1,104 @@
+package comparison
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidExpression = errors.New("invalid comparison expression")
+	ErrNotANumber        = errors.New("not a number")
+)
+
+// operators, longest first
+var operatorList = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// Comparison between a subject and a value (ie. "battery < 10", `up{job="node"} == 0`)
+type Comparison struct {
+	Subject  string
+	Operator string
+	Value    string
+}
+
+// Parse an expression "<subject> <operator> <value>". Operators inside braces or quotes are ignored.
+// Value can be quoted.
+func Parse(expression string) (Comparison, error) {
+	depth := 0
+	inQuotes := false
+	for i := 0; i < len(expression); i++ {
+		switch char := expression[i]; {
+		case char == '\\' && inQuotes:
+			i++
+		case char == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case char == '{':
+			depth++
+		case char == '}':
+			depth--
+		case depth == 0:
+			for _, operator := range operatorList {
+				if strings.HasPrefix(expression[i:], operator) {
+					subject := strings.TrimSpace(expression[:i])
+					value := strings.TrimSpace(expression[i+len(operator):])
+					if subject == "" || value == "" {
+						return Comparison{}, fmt.Errorf("%w: '%v'", ErrInvalidExpression, expression)
+					}
+					if unquoted, err := strconv.Unquote(value); err == nil {
+						value = unquoted
+					}
+					return Comparison{Subject: subject, Operator: operator, Value: value}, nil
+				}
+			}
+		}
+	}
+	return Comparison{}, fmt.Errorf("%w: no operator in '%v'", ErrInvalidExpression, expression)
+}
+
+// Match compares actual against expected value, numerically when both are numbers.
+// Only == and != are allowed on strings.
+func (comparison Comparison) Match(actual string) (bool, error) {
+	actualNumber, errActual := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+	_, errExpected := strconv.ParseFloat(comparison.Value, 64)
+	if errActual == nil && errExpected == nil {
+		return comparison.MatchNumber(actualNumber), nil
+	}
+
+	switch comparison.Operator {
+	case "==":
+		return actual == comparison.Value, nil
+	case "!=":
+		return actual != comparison.Value, nil
+	default:
+		return false, fmt.Errorf("%w: '%v' %v '%v'", ErrNotANumber, actual, comparison.Operator, comparison.Value)
+	}
+}
+
+// MatchNumber compares actual against expected value, which must be a number (false otherwise)
+func (comparison Comparison) MatchNumber(actual float64) bool {
+	expected, err := strconv.ParseFloat(comparison.Value, 64)
+	if err != nil {
+		return false
+	}
+	switch comparison.Operator {
+	case "<=":
+		return actual <= expected
+	case ">=":
+		return actual >= expected
+	case "==":
+		return actual == expected
+	case "!=":
+		return actual != expected
+	case "<":
+		return actual < expected
+	case ">":
+		return actual > expected
+	}
+	return false
+}
+
+func (comparison Comparison) String() string {
+	return fmt.Sprintf("%v %v %v", comparison.Subject, comparison.Operator, comparison.Value)
+}
@@ -0,0 +1,66 @@
+package comparison_test
+
+import (
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/comparison"
+	"gotest.tools/v3/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    comparison.Comparison
+		wantErr bool
+	}{
+		{input: "battery < 10", want: comparison.Comparison{Subject: "battery", Operator: "<", Value: "10"}},
+		{input: "state.contact==false", want: comparison.Comparison{Subject: "state.contact", Operator: "==", Value: "false"}},
+		{input: `payload != "on line"`, want: comparison.Comparison{Subject: "payload", Operator: "!=", Value: "on line"}},
+		{input: `up{job="node",instance!="a>b"} <= 0`, want: comparison.Comparison{Subject: `up{job="node",instance!="a>b"}`, Operator: "<=", Value: "0"}},
+		{input: "temperature >= 80.5", want: comparison.Comparison{Subject: "temperature", Operator: ">=", Value: "80.5"}},
+		{input: "temperature", wantErr: true},
+		{input: "> 5", wantErr: true},
+		{input: "temperature >", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := comparison.Parse(test.input)
+			if test.wantErr {
+				assert.ErrorIs(t, err, comparison.ErrInvalidExpression)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, test.want, got)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		actual     string
+		want       bool
+		wantErr    bool
+	}{
+		{expression: "battery < 10", actual: "9.5", want: true},
+		{expression: "battery < 10", actual: "10", want: false},
+		{expression: "battery >= 10", actual: "10", want: true},
+		{expression: "battery != 10", actual: "10.0", want: false},
+		{expression: "state == offline", actual: "offline", want: true},
+		{expression: "state != offline", actual: "online", want: true},
+		{expression: "state > offline", actual: "online", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.expression+" ("+test.actual+")", func(t *testing.T) {
+			parsed, err := comparison.Parse(test.expression)
+			assert.NilError(t, err)
+			got, err := parsed.Match(test.actual)
+			if test.wantErr {
+				assert.ErrorIs(t, err, comparison.ErrNotANumber)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package customtypes
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidDateTimeFormat = errors.New("invalid date time format (expected YYYY-MM-DD HH:MM[:SS] or RFC3339)")
+
+var dateTimeLayouts = []string{time.DateTime, "2006-01-02 15:04", time.RFC3339}
+
+// Date and time, in local time zone unless specified (RFC3339)
+type DateTime time.Time
+
+func ParseDateTime(s string) (DateTime, error) {
+	for _, layout := range dateTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return DateTime(parsed), nil
+		}
+	}
+	return DateTime{}, fmt.Errorf("%w: %s", ErrInvalidDateTimeFormat, s)
+}
+
+func (d *DateTime) UnmarshalText(text []byte) error {
+	parsed, err := ParseDateTime(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d DateTime) MarshalText() ([]byte, error) {
+	return []byte(time.Time(d).Format(time.RFC3339)), nil
+}
+
+func (d DateTime) AsTime() time.Time {
+	return time.Time(d)
+}
+
+func (d DateTime) String() string {
+	return time.Time(d).Format(time.DateTime)
+}
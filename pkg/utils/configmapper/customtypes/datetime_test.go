@@ -0,0 +1,32 @@
+package customtypes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseDateTime(t *testing.T) {
+	parsed, err := customtypes.ParseDateTime("2024-03-10 02:30")
+	assert.NilError(t, err)
+	assert.Assert(t, parsed.AsTime().Equal(time.Date(2024, 3, 10, 2, 30, 0, 0, time.Local)))
+
+	parsed, err = customtypes.ParseDateTime("2024-03-10 02:30:15")
+	assert.NilError(t, err)
+	assert.Equal(t, "2024-03-10 02:30:15", parsed.String())
+
+	parsed, err = customtypes.ParseDateTime("2024-03-10T02:30:00Z")
+	assert.NilError(t, err)
+	assert.Assert(t, parsed.AsTime().Equal(time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC)))
+
+	text, err := parsed.MarshalText()
+	assert.NilError(t, err)
+	var roundTrip customtypes.DateTime
+	assert.NilError(t, roundTrip.UnmarshalText(text))
+	assert.Assert(t, roundTrip.AsTime().Equal(parsed.AsTime()))
+
+	_, err = customtypes.ParseDateTime("10/03/2024")
+	assert.ErrorIs(t, err, customtypes.ErrInvalidDateTimeFormat)
+}
@@ -0,0 +1,25 @@
+package customtypes
+
+import (
+	"github.com/dustin/go-humanize"
+)
+
+// Size in bytes, parsed using go-humanize (ie. 500k, 1gb, 2gib)
+type Size uint64
+
+func (s *Size) UnmarshalText(text []byte) error {
+	parsed, err := humanize.ParseBytes(string(text))
+	if err != nil {
+		return err
+	}
+	*s = Size(parsed)
+	return nil
+}
+
+func (s Size) AsBytes() uint64 {
+	return uint64(s)
+}
+
+func (s Size) String() string {
+	return humanize.Bytes(uint64(s))
+}
@@ -50,3 +50,7 @@ func (t *TimeOfDay) UnmarshalText(text []byte) error {
 	*t = timeOfDay
 	return nil
 }
+
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
@@ -146,9 +146,12 @@ func mapOnPtr(ctx *Context, type_ reflect.Type, raw any, level string) (reflect.
 		return reflect.Value{}, nil
 	} else {
 		value, err := mapOnAny(ctx, type_.Elem(), raw, level)
+		if err != nil {
+			return reflect.Value{}, err
+		}
 		newValue := reflect.New(value.Type())
 		newValue.Elem().Set(value)
-		return newValue, err
+		return newValue, nil
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
@@ -16,20 +17,28 @@ var (
 	ErrListContainers    = errors.New("failed to list containers")
 	ErrInspectContainer  = errors.New("failed to inspect container")
 	ErrContainerNotFound = errors.New("container not found")
+	ErrEvents            = errors.New("failed to stream events")
 )
 
 type Client struct {
 	http *http.Client
 }
 
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
 func NewClient() (*Client, error) {
+	return NewClientWithDialer(func(_ context.Context, _, _ string) (net.Conn, error) {
+		return net.Dial("unix", "/var/run/docker.sock")
+	})
+}
+
+// Create a client using a custom dialer (ie. another socket path, or a socket forwarded over SSH).
+func NewClientWithDialer(dialContext DialContextFunc) (*Client, error) {
 	httpc := http.Client{
 		Transport: &http.Transport{
 			MaxIdleConns:    6,
 			IdleConnTimeout: 30 * time.Second,
-			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-				return net.Dial("unix", "/var/run/docker.sock")
-			},
+			DialContext:     dialContext,
 		},
 	}
 
@@ -76,3 +85,41 @@ func (c *Client) ContainerInspect(ctx context.Context, containerId string) (Cont
 
 	return ContainerInspect{}, err
 }
+
+// Stream engine events matching filters and call handler for each of them.
+// Blocks until ctx is done or the stream is interrupted.
+func (c *Client) Events(ctx context.Context, filters EventFilters, handler func(Event)) error {
+	encodedFilters, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/events?filters="+url.QueryEscape(string(encodedFilters)), nil)
+	if err != nil {
+		return err
+	}
+
+	//nolint:bodyclose // SafeClose instead of Close
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer utils.SafeClose(resp.Body)
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%w: status %v", ErrEvents, resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event Event
+		err = decoder.Decode(&event)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w: %v", ErrEvents, err)
+		}
+		handler(event)
+	}
+}
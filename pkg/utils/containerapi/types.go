@@ -15,6 +15,33 @@ type ContainerList []Container
 
 // GET "containers/{id}/json"
 
+type ContainerHealth struct {
+	Status string `json:"Status"` // starting, healthy or unhealthy (empty without healthcheck)
+}
+
+type ContainerState struct {
+	Health *ContainerHealth `json:"Health"`
+}
+
 type ContainerInspect struct {
-	RestartCount int `json:"RestartCount"`
+	RestartCount int            `json:"RestartCount"`
+	State        ContainerState `json:"State"`
+}
+
+// GET "events"
+
+type EventActor struct {
+	ID         string            `json:"ID"`
+	Attributes map[string]string `json:"Attributes"`
 }
+
+type Event struct {
+	Type     string     `json:"Type"`
+	Action   string     `json:"Action"`
+	Actor    EventActor `json:"Actor"`
+	Time     int64      `json:"time"`
+	TimeNano int64      `json:"timeNano"`
+}
+
+// Event filters, as expected by the "filters" query parameter (ie. {"type": ["container"], "event": ["die"]})
+type EventFilters map[string][]string
@@ -0,0 +1,152 @@
+package exposition
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSample = errors.New("invalid sample")
+
+// Sample of the Prometheus text exposition format (see https://prometheus.io/docs/instrumenting/exposition_formats/)
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Key uniquely identifies a series (ie. http_requests_total{code="500",method="get"})
+func (sample Sample) Key() string {
+	if len(sample.Labels) == 0 {
+		return sample.Name
+	}
+	labels := []string{}
+	for _, name := range slices.Sorted(maps.Keys(sample.Labels)) {
+		labels = append(labels, fmt.Sprintf("%v=%q", name, sample.Labels[name]))
+	}
+	return sample.Name + "{" + strings.Join(labels, ",") + "}"
+}
+
+func parseValue(value string) (float64, error) {
+	switch value {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	default:
+		return strconv.ParseFloat(value, 64)
+	}
+}
+
+// ParseLabels parses a label set without braces (ie. code="500",method="get")
+func ParseLabels(content string) (map[string]string, error) {
+	labels := map[string]string{}
+	for {
+		content = strings.TrimLeft(content, ", ")
+		if content == "" {
+			return labels, nil
+		}
+		name, rest, found := strings.Cut(content, "=")
+		if !found || !strings.HasPrefix(rest, `"`) {
+			return nil, fmt.Errorf("%w: labels '%v'", ErrInvalidSample, content)
+		}
+		value := strings.Builder{}
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				switch rest[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(rest[i])
+				}
+			} else {
+				value.WriteByte(rest[i])
+			}
+		}
+		if i >= len(rest) {
+			return nil, fmt.Errorf("%w: unterminated label value '%v'", ErrInvalidSample, content)
+		}
+		labels[strings.TrimSpace(name)] = value.String()
+		content = rest[i+1:]
+	}
+}
+
+// find the closing brace of a label set, ignoring braces in quoted values
+func findLabelsEnd(line string) int {
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && inQuotes:
+			i++
+		case line[i] == '"':
+			inQuotes = !inQuotes
+		case line[i] == '}' && !inQuotes:
+			return i
+		}
+	}
+	return -1
+}
+
+func parseSample(line string) (Sample, error) {
+	sample := Sample{Labels: map[string]string{}}
+	rest := ""
+	if braceIndex := strings.IndexByte(line, '{'); braceIndex >= 0 {
+		sample.Name = strings.TrimSpace(line[:braceIndex])
+		end := findLabelsEnd(line)
+		if end < braceIndex {
+			return Sample{}, fmt.Errorf("%w: '%v'", ErrInvalidSample, line)
+		}
+		labels, err := ParseLabels(line[braceIndex+1 : end])
+		if err != nil {
+			return Sample{}, err
+		}
+		sample.Labels = labels
+		rest = line[end+1:]
+	} else {
+		name, value, found := strings.Cut(line, " ")
+		if !found {
+			return Sample{}, fmt.Errorf("%w: '%v'", ErrInvalidSample, line)
+		}
+		sample.Name = name
+		rest = value
+	}
+
+	// value [timestamp]
+	fields := strings.Fields(rest)
+	if sample.Name == "" || len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, fmt.Errorf("%w: '%v'", ErrInvalidSample, line)
+	}
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return Sample{}, fmt.Errorf("%w: '%v' (%v)", ErrInvalidSample, line, err)
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// Parse reads all samples, comments (HELP, TYPE) are ignored
+func Parse(reader io.Reader) ([]Sample, error) {
+	samples := []Sample{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
@@ -0,0 +1,50 @@
+package exposition_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/exposition"
+	"gotest.tools/v3/assert"
+)
+
+const metrics = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+
+# Escaping in label values:
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\" {}"} 1.458255915e9
+
+# Minimalistic line:
+metric_without_timestamp_and_labels 12.47
+something_weird{problem="division by zero"} +Inf -3982045
+`
+
+func TestParse(t *testing.T) {
+	samples, err := exposition.Parse(strings.NewReader(metrics))
+	assert.NilError(t, err)
+	assert.Equal(t, 5, len(samples))
+
+	assert.Equal(t, "http_requests_total", samples[0].Name)
+	assert.DeepEqual(t, map[string]string{"method": "post", "code": "200"}, samples[0].Labels)
+	assert.Equal(t, 1027., samples[0].Value)
+	assert.Equal(t, `http_requests_total{code="400",method="post"}`, samples[1].Key())
+
+	assert.DeepEqual(t, map[string]string{"path": `C:\DIR\FILE.TXT`, "error": "Cannot find file:\n\"FILE.TXT\" {}"}, samples[2].Labels)
+	assert.Equal(t, 1.458255915e9, samples[2].Value)
+
+	assert.Equal(t, "metric_without_timestamp_and_labels", samples[3].Key())
+	assert.Equal(t, 12.47, samples[3].Value)
+	assert.Equal(t, math.Inf(1), samples[4].Value)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := exposition.Parse(strings.NewReader(`http_requests_total{method="post" 1027`))
+	assert.ErrorIs(t, err, exposition.ErrInvalidSample)
+	_, err = exposition.Parse(strings.NewReader(`http_requests_total abc`))
+	assert.ErrorIs(t, err, exposition.ErrInvalidSample)
+	_, err = exposition.Parse(strings.NewReader(`http_requests_total`))
+	assert.ErrorIs(t, err, exposition.ErrInvalidSample)
+}
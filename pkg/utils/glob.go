@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// CompileGlob converts a glob pattern (* and ?) into an anchored regexp.
+// Unlike path.Match, * also matches '/' (metric IDs may contain paths).
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, char := range pattern {
+		switch char {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
+
+func CompileGlobList(patterns []string) ([]*regexp.Regexp, error) {
+	globs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		glob, err := CompileGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", pattern, err)
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+// MatchGlobList reports whether value matches any glob (an empty list matches everything)
+func MatchGlobList(globs []*regexp.Regexp, value string) bool {
+	return len(globs) == 0 || slices.ContainsFunc(globs, func(glob *regexp.Regexp) bool {
+		return glob.MatchString(value)
+	})
+}
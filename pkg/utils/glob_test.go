@@ -0,0 +1,22 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestCompileGlob(t *testing.T) {
+	glob, err := utils.CompileGlob("disk_filesystemusage_*")
+	assert.NilError(t, err)
+	assert.Assert(t, glob.MatchString("disk_filesystemusage_/var/lib"))
+	assert.Assert(t, glob.MatchString("disk_filesystemusage_"))
+	assert.Assert(t, !glob.MatchString("other_disk_filesystemusage_/"))
+
+	glob, err = utils.CompileGlob("ping_?.example.com")
+	assert.NilError(t, err)
+	assert.Assert(t, glob.MatchString("ping_a.example.com"))
+	assert.Assert(t, !glob.MatchString("ping_ab.example.com"))
+	assert.Assert(t, !glob.MatchString("ping_a-example-com"))
+}
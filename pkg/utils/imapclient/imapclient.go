@@ -0,0 +1,223 @@
+package imapclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+)
+
+var (
+	ErrServer              = errors.New("imap error")
+	ErrProtocol            = errors.New("unexpected imap response")
+	ErrSTARTTLSUnsupported = errors.New("STARTTLS not supported by server")
+	ErrInvalidSecurity     = errors.New("invalid security mode (tls, starttls or none)")
+)
+
+// Connection security, shared with SMTP
+type Security string
+
+const (
+	SecurityTLS      Security = "tls"
+	SecuritySTARTTLS Security = "starttls"
+	SecurityNone     Security = "none"
+)
+
+func (security Security) Validate() error {
+	switch security {
+	case SecurityTLS, SecuritySTARTTLS, SecurityNone:
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidSecurity, security)
+}
+
+// Minimal IMAP4rev1 client (RFC 3501), limited to what is needed to check a mailbox
+type Client struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	tagCounter int
+}
+
+func Dial(ctx context.Context, address string, security Security, tlsConfig *tls.Config, timeout time.Duration) (*Client, error) {
+	if err := security.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if security == SecurityTLS {
+		dialer := tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		dialer := net.Dialer{}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		utils.SafeClose(conn)
+		return nil, err
+	}
+	client := &Client{conn: conn, reader: bufio.NewReader(conn)}
+
+	greeting, err := client.readLine()
+	if err != nil {
+		utils.SafeClose(client)
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		utils.SafeClose(client)
+		return nil, fmt.Errorf("%w: greeting '%v'", ErrProtocol, greeting)
+	}
+
+	if security == SecuritySTARTTLS {
+		if err := client.startTLS(tlsConfig); err != nil {
+			utils.SafeClose(client)
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func (client *Client) startTLS(tlsConfig *tls.Config) error {
+	capabilities, err := client.Command("CAPABILITY")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(strings.ToUpper(strings.Join(capabilities, " ")), "STARTTLS") {
+		return ErrSTARTTLSUnsupported
+	}
+	if _, err := client.Command("STARTTLS"); err != nil {
+		return err
+	}
+	tlsConn := tls.Client(client.conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	client.conn = tlsConn
+	client.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// read a line, including literals ({size} followed by size bytes)
+func (client *Client) readLine() (string, error) {
+	line, err := client.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasSuffix(line, "}") {
+		if start := strings.LastIndexByte(line, '{'); start >= 0 {
+			if size, err := strconv.Atoi(line[start+1 : len(line)-1]); err == nil {
+				literal := make([]byte, size)
+				if _, err := io.ReadFull(client.reader, literal); err != nil {
+					return "", err
+				}
+				rest, err := client.readLine()
+				return line + string(literal) + rest, err
+			}
+		}
+	}
+	return line, nil
+}
+
+// Quote a string argument
+func Quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Command sends a command and returns untagged responses (without "* ")
+func (client *Client) Command(command string) ([]string, error) {
+	client.tagCounter++
+	tag := fmt.Sprintf("a%03d", client.tagCounter)
+	if _, err := fmt.Fprintf(client.conn, "%v %v\r\n", tag, command); err != nil {
+		return nil, err
+	}
+
+	untagged := []string{}
+	for {
+		line, err := client.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if response, found := strings.CutPrefix(line, "* "); found {
+			untagged = append(untagged, response)
+			continue
+		}
+		if response, found := strings.CutPrefix(line, tag+" "); found {
+			status, text, _ := strings.Cut(response, " ")
+			if status != "OK" {
+				return nil, fmt.Errorf("%w: %v %v", ErrServer, status, text)
+			}
+			return untagged, nil
+		}
+		// continuation requests (+) and unknown lines are ignored
+	}
+}
+
+func (client *Client) Login(username, password string) error {
+	_, err := client.Command("LOGIN " + Quote(username) + " " + Quote(password))
+	return err
+}
+
+func (client *Client) Select(mailbox string) error {
+	_, err := client.Command("SELECT " + Quote(mailbox))
+	return err
+}
+
+// Search returns sequence numbers of messages matching criteria (ie. SUBJECT "hello")
+func (client *Client) Search(criteria string) ([]uint32, error) {
+	untagged, err := client.Command("SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+	result := []uint32{}
+	for _, response := range untagged {
+		if ids, found := strings.CutPrefix(response, "SEARCH"); found {
+			for _, id := range strings.Fields(ids) {
+				value, err := strconv.ParseUint(id, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrProtocol, response)
+				}
+				result = append(result, uint32(value))
+			}
+		}
+	}
+	return result, nil
+}
+
+// Delete flags messages as deleted and expunges the mailbox
+func (client *Client) Delete(ids []uint32) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	idList := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idList = append(idList, strconv.FormatUint(uint64(id), 10))
+	}
+	if _, err := client.Command("STORE " + strings.Join(idList, ",") + ` +FLAGS.SILENT (\Deleted)`); err != nil {
+		return err
+	}
+	_, err := client.Command("EXPUNGE")
+	return err
+}
+
+func (client *Client) Logout() error {
+	_, err := client.Command("LOGOUT")
+	return err
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
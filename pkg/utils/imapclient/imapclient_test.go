@@ -0,0 +1,122 @@
+package imapclient_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/imapclient"
+	"gotest.tools/v3/assert"
+)
+
+func makeCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NilError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// runFakeIMAPServer serves a mailbox with 5 messages, matching SUBJECT "hello" on messages 2 and 5
+func runFakeIMAPServer(t *testing.T, startTLS bool) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{makeCertificate(t)}}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				reader := bufio.NewReader(conn)
+				write := func(lines ...string) {
+					_, _ = conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+				}
+				write("* OK IMAP4rev1 ready")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					tag, command, _ := strings.Cut(strings.TrimSpace(line), " ")
+					switch {
+					case command == "CAPABILITY" && startTLS:
+						write("* CAPABILITY IMAP4rev1 STARTTLS LOGINDISABLED", tag+" OK done")
+					case command == "CAPABILITY":
+						write("* CAPABILITY IMAP4rev1", tag+" OK done")
+					case command == "STARTTLS" && startTLS:
+						write(tag + " OK begin TLS")
+						tlsConn := tls.Server(conn, tlsConfig)
+						if tlsConn.Handshake() != nil {
+							return
+						}
+						conn = tlsConn
+						reader = bufio.NewReader(conn)
+					case command == `LOGIN "monitoring" "p@ss\"word"`:
+						write(tag + " OK logged in")
+					case strings.HasPrefix(command, "LOGIN"):
+						write(tag + " NO [AUTHENTICATIONFAILED] invalid credentials")
+					case command == `SELECT "INBOX"`:
+						write("* 5 EXISTS", "* FLAGS (\\Seen \\Deleted)", tag+" OK [READ-WRITE] done")
+					case command == `SEARCH SUBJECT "hello"`:
+						write("* SEARCH 2 5", tag+" OK done")
+					case command == `STORE 2,5 +FLAGS.SILENT (\Deleted)`, command == "EXPUNGE":
+						write(tag + " OK done")
+					case command == "LOGOUT":
+						write("* BYE", tag+" OK done")
+						return
+					default:
+						write(tag + " BAD unknown command")
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestIMAPClient(t *testing.T) {
+	address := runFakeIMAPServer(t, true)
+	client, err := imapclient.Dial(context.Background(), address, imapclient.SecuritySTARTTLS, &tls.Config{InsecureSkipVerify: true}, time.Second)
+	assert.NilError(t, err)
+	defer func() { _ = client.Close() }()
+
+	assert.ErrorContains(t, client.Login("monitoring", "wrong"), "AUTHENTICATIONFAILED")
+	assert.NilError(t, client.Login("monitoring", `p@ss"word`))
+	assert.NilError(t, client.Select("INBOX"))
+	ids, err := client.Search("SUBJECT " + imapclient.Quote("hello"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []uint32{2, 5}, ids)
+	assert.NilError(t, client.Delete(ids))
+	assert.NilError(t, client.Logout())
+}
+
+func TestIMAPClientSTARTTLSUnsupported(t *testing.T) {
+	address := runFakeIMAPServer(t, false)
+	_, err := imapclient.Dial(context.Background(), address, imapclient.SecuritySTARTTLS, &tls.Config{InsecureSkipVerify: true}, time.Second)
+	assert.ErrorIs(t, err, imapclient.ErrSTARTTLSUnsupported)
+
+	_, err = imapclient.Dial(context.Background(), address, "ssl", nil, time.Second)
+	assert.ErrorIs(t, err, imapclient.ErrInvalidSecurity)
+}
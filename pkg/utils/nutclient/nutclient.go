@@ -0,0 +1,202 @@
+package nutclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+)
+
+var (
+	ErrServer   = errors.New("upsd error")
+	ErrProtocol = errors.New("unexpected upsd response")
+)
+
+// Network UPS Tools client (upsd text protocol, see https://networkupstools.org/docs/developer-guide.chunked/net-protos.html)
+// A new session is opened for each request.
+type Client struct {
+	address  string
+	username string
+	password string
+	timeout  time.Duration
+}
+
+type session struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewClient(address, username, password string, timeout time.Duration) *Client {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "3493")
+	}
+	return &Client{
+		address:  address,
+		username: username,
+		password: password,
+		timeout:  timeout,
+	}
+}
+
+// split a line into words, handling double quotes and backslash escapes
+func splitLine(line string) ([]string, error) {
+	words := []string{}
+	current := strings.Builder{}
+	inWord, inQuotes, escaped := false, false, false
+	for _, char := range line {
+		switch {
+		case escaped:
+			current.WriteRune(char)
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == '"':
+			inQuotes = !inQuotes
+			inWord = true
+		case char == ' ' && !inQuotes:
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(char)
+			inWord = true
+		}
+	}
+	if inQuotes || escaped {
+		return nil, fmt.Errorf("%w: unterminated line '%v'", ErrProtocol, line)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+func (c *Client) openSession(ctx context.Context) (*session, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		utils.SafeClose(conn)
+		return nil, err
+	}
+	s := &session{conn: conn, reader: bufio.NewReader(conn)}
+
+	if c.username != "" {
+		if _, err := s.command("USERNAME " + c.username); err != nil {
+			s.close()
+			return nil, err
+		}
+		if _, err := s.command("PASSWORD " + c.password); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *session) readLine() ([]string, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if message, found := strings.CutPrefix(line, "ERR "); found {
+		return nil, fmt.Errorf("%w: %v", ErrServer, message)
+	}
+	return splitLine(line)
+}
+
+// send a command and return the first response line
+func (s *session) command(command string) ([]string, error) {
+	if _, err := fmt.Fprintf(s.conn, "%v\n", command); err != nil {
+		return nil, err
+	}
+	return s.readLine()
+}
+
+// run a LIST command, returning each item without its prefix (ie. "VAR <ups>")
+func (s *session) list(query string) ([][]string, error) {
+	queryWords, err := splitLine(query)
+	if err != nil {
+		return nil, err
+	}
+	words, err := s.command("LIST " + query)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(words, " ") != "BEGIN LIST "+strings.Join(queryWords, " ") {
+		return nil, fmt.Errorf("%w: '%v'", ErrProtocol, strings.Join(words, " "))
+	}
+	items := [][]string{}
+	for {
+		words, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(words) >= 2 && words[0] == "END" && words[1] == "LIST" {
+			return items, nil
+		}
+		if len(words) <= len(queryWords) || !slices.Equal(words[:len(queryWords)], queryWords) {
+			return nil, fmt.Errorf("%w: '%v'", ErrProtocol, strings.Join(words, " "))
+		}
+		items = append(items, words[len(queryWords):])
+	}
+}
+
+func (s *session) close() {
+	_, _ = fmt.Fprintf(s.conn, "LOGOUT\n")
+	utils.SafeClose(s.conn)
+}
+
+// ListUPS returns the names of UPS managed by upsd
+func (c *Client) ListUPS(ctx context.Context) ([]string, error) {
+	s, err := c.openSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	items, err := s.list("UPS")
+	if err != nil {
+		return nil, err
+	}
+	upsList := make([]string, 0, len(items))
+	for _, item := range items {
+		upsList = append(upsList, item[0])
+	}
+	return upsList, nil
+}
+
+// ListVar returns all variables of an UPS (ie. ups.status => OL)
+func (c *Client) ListVar(ctx context.Context, ups string) (map[string]string, error) {
+	s, err := c.openSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	items, err := s.list("VAR " + ups)
+	if err != nil {
+		return nil, err
+	}
+	variables := make(map[string]string, len(items))
+	for _, item := range items {
+		if len(item) != 2 {
+			return nil, fmt.Errorf("%w: '%v'", ErrProtocol, strings.Join(item, " "))
+		}
+		variables[item[0]] = item[1]
+	}
+	return variables, nil
+}
@@ -0,0 +1,106 @@
+package nutclient_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/nutclient"
+	"gotest.tools/v3/assert"
+)
+
+// runFakeUpsd serves a single UPS named "myups", with optional authentication
+func runFakeUpsd(t *testing.T, username, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeUpsd(conn, username, password)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveFakeUpsd(conn net.Conn, username, password string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	loggedUser, loggedPassword := "", ""
+	reply := func(lines ...string) {
+		_, _ = conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		authorized := username == "" || (loggedUser == username && loggedPassword == password)
+		switch {
+		case strings.HasPrefix(command, "USERNAME "):
+			loggedUser = strings.TrimPrefix(command, "USERNAME ")
+			reply("OK")
+		case strings.HasPrefix(command, "PASSWORD "):
+			loggedPassword = strings.TrimPrefix(command, "PASSWORD ")
+			reply("OK")
+		case !authorized && strings.HasPrefix(command, "LIST "):
+			reply("ERR ACCESS-DENIED")
+		case command == "LIST UPS":
+			reply("BEGIN LIST UPS", `UPS myups "Eaton \"5E\" 850"`, "END LIST UPS")
+		case command == "LIST VAR myups":
+			reply("BEGIN LIST VAR myups",
+				`VAR myups battery.charge "87"`,
+				`VAR myups battery.runtime "1260"`,
+				`VAR myups ups.status "OB DISCHRG"`,
+				"END LIST VAR myups")
+		case strings.HasPrefix(command, "LIST VAR "):
+			reply("ERR UNKNOWN-UPS")
+		case command == "LOGOUT":
+			reply("OK Goodbye")
+			return
+		default:
+			reply("ERR UNKNOWN-COMMAND")
+		}
+	}
+}
+
+func TestListUPS(t *testing.T) {
+	client := nutclient.NewClient(runFakeUpsd(t, "", ""), "", "", time.Second)
+	upsList, err := client.ListUPS(context.Background())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"myups"}, upsList)
+}
+
+func TestListVar(t *testing.T) {
+	address := runFakeUpsd(t, "monuser", "secret")
+	client := nutclient.NewClient(address, "monuser", "secret", time.Second)
+	variables, err := client.ListVar(context.Background(), "myups")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{
+		"battery.charge":  "87",
+		"battery.runtime": "1260",
+		"ups.status":      "OB DISCHRG",
+	}, variables)
+
+	_, err = client.ListVar(context.Background(), "otherups")
+	assert.ErrorIs(t, err, nutclient.ErrServer)
+	assert.ErrorContains(t, err, "UNKNOWN-UPS")
+
+	client = nutclient.NewClient(address, "monuser", "wrong", time.Second)
+	_, err = client.ListVar(context.Background(), "myups")
+	assert.ErrorContains(t, err, "ACCESS-DENIED")
+}
+
+func TestDefaultPort(t *testing.T) {
+	client := nutclient.NewClient("127.0.0.1", "", "", 100*time.Millisecond)
+	_, err := client.ListUPS(context.Background())
+	assert.ErrorContains(t, err, "127.0.0.1:3493")
+}
@@ -0,0 +1,169 @@
+package redisclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils"
+)
+
+var (
+	ErrServer   = errors.New("redis error")
+	ErrProtocol = errors.New("unexpected redis response")
+)
+
+// Minimal redis client (RESP2, see https://redis.io/docs/latest/develop/reference/protocol-spec/)
+type Client struct {
+	address  string
+	username string
+	password string
+	database int
+	timeout  time.Duration
+}
+
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewClient(address, username, password string, database int, timeout time.Duration) *Client {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "6379")
+	}
+	return &Client{
+		address:  address,
+		username: username,
+		password: password,
+		database: database,
+		timeout:  timeout,
+	}
+}
+
+// Open a new connection, authenticated and using the configured database
+func (c *Client) Open(ctx context.Context) (*Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	if err := netConn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		utils.SafeClose(netConn)
+		return nil, err
+	}
+	conn := &Conn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.Do(args...); err != nil {
+			utils.SafeClose(conn)
+			return nil, err
+		}
+	}
+	if c.database != 0 {
+		if _, err := conn.Do("SELECT", strconv.Itoa(c.database)); err != nil {
+			utils.SafeClose(conn)
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Do sends a command and returns its reply: string, int64, []any or nil
+func (conn *Conn) Do(args ...string) (any, error) {
+	request := strings.Builder{}
+	fmt.Fprintf(&request, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn.conn, request.String()); err != nil {
+		return nil, err
+	}
+	return conn.readReply()
+}
+
+func (conn *Conn) readReply() (any, error) {
+	line, err := conn.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("%w: %v", ErrServer, line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrProtocol, err)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buffer := make([]byte, size+2)
+		if _, err := io.ReadFull(conn.reader, buffer); err != nil {
+			return nil, err
+		}
+		return string(buffer[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrProtocol, err)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, 0, count)
+		for range count {
+			item, err := conn.readReply()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("%w: '%v'", ErrProtocol, line)
+	}
+}
+
+// Info runs INFO and returns its fields (ie. role => master)
+func (conn *Conn) Info(section string) (map[string]string, error) {
+	reply, err := conn.Do("INFO", section)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: INFO reply is not a string", ErrProtocol)
+	}
+	fields := map[string]string{}
+	for line := range strings.Lines(content) {
+		if key, value, found := strings.Cut(strings.TrimSpace(line), ":"); found && !strings.HasPrefix(key, "#") {
+			fields[key] = value
+		}
+	}
+	return fields, nil
+}
+
+func (conn *Conn) Close() error {
+	return conn.conn.Close()
+}
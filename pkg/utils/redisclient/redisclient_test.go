@@ -0,0 +1,135 @@
+package redisclient_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/redisclient"
+	"gotest.tools/v3/assert"
+)
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := []string{}
+	for range count {
+		if _, err := reader.ReadString('\n'); err != nil { // $<size>
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return args, nil
+}
+
+// runFakeRedis serves a few commands, requiring password "secret"
+func runFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				reader := bufio.NewReader(conn)
+				authenticated := false
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+					reply := ""
+					switch {
+					case args[0] == "AUTH":
+						authenticated = args[len(args)-1] == "secret"
+						reply = "+OK\r\n"
+						if !authenticated {
+							reply = "-WRONGPASS invalid username-password pair\r\n"
+						}
+					case !authenticated:
+						reply = "-NOAUTH Authentication required.\r\n"
+					case args[0] == "SELECT":
+						reply = "+OK\r\n"
+					case args[0] == "PING":
+						reply = "+PONG\r\n"
+					case args[0] == "DBSIZE":
+						reply = ":42\r\n"
+					case args[0] == "GET":
+						reply = "$-1\r\n"
+					case args[0] == "INFO":
+						info := "# Replication\r\nrole:slave\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:3\r\n"
+						reply = fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+					case args[0] == "CONFIG":
+						reply = "*2\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n"
+					default:
+						reply = "-ERR unknown command\r\n"
+					}
+					_, _ = conn.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestRedisClient(t *testing.T) {
+	address := runFakeRedis(t)
+	conn, err := redisclient.NewClient(address, "", "secret", 2, time.Second).Open(context.Background())
+	assert.NilError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	reply, err := conn.Do("PING")
+	assert.NilError(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	reply, err = conn.Do("DBSIZE")
+	assert.NilError(t, err)
+	assert.Equal(t, int64(42), reply)
+
+	reply, err = conn.Do("GET", "missing")
+	assert.NilError(t, err)
+	assert.Equal(t, nil, reply)
+
+	reply, err = conn.Do("CONFIG", "GET", "maxclients")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []any{"maxclients", "10000"}, reply)
+
+	info, err := conn.Info("replication")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"role": "slave", "master_link_status": "up", "master_last_io_seconds_ago": "3"}, info)
+
+	_, err = conn.Do("FLUSHALL")
+	assert.ErrorIs(t, err, redisclient.ErrServer)
+}
+
+func TestRedisClientAuth(t *testing.T) {
+	address := runFakeRedis(t)
+	_, err := redisclient.NewClient(address, "monitoring", "wrong", 0, time.Second).Open(context.Background())
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	conn, err := redisclient.NewClient(address, "", "", 0, time.Second).Open(context.Background())
+	assert.NilError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Do("PING")
+	assert.ErrorContains(t, err, "NOAUTH")
+}
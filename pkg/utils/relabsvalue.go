@@ -27,6 +27,11 @@ func (relAbsVal *RelativeAbsoluteValue) GetValue(reference uint64) uint64 {
 	}
 }
 
+// IsZero reports whether value is 0 (or 0%), usually meaning disabled
+func (relAbsVal *RelativeAbsoluteValue) IsZero() bool {
+	return relAbsVal.absValue == 0 && relAbsVal.relValue == 0
+}
+
 func RelativeAbsoluteValueFromString(value string) (RelativeAbsoluteValue, error) {
 	var relAbsValue RelativeAbsoluteValue
 	var err error
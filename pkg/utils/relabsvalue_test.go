@@ -52,3 +52,21 @@ func TestRelAbsValueParsingAbsolute(t *testing.T) {
 	assert.Equal(t, val.GetValue(100), uint64(0))
 	assert.Equal(t, val.GetValue(1000), uint64(0))
 }
+
+func TestRelAbsValueIsZero(t *testing.T) {
+
+	val, err := utils.RelativeAbsoluteValueFromString("0")
+
+	assert.NilError(t, err)
+	assert.Equal(t, val.IsZero(), true)
+
+	val, err = utils.RelativeAbsoluteValueFromString("0%")
+
+	assert.NilError(t, err)
+	assert.Equal(t, val.IsZero(), true)
+
+	val, err = utils.RelativeAbsoluteValueFromString("80%")
+
+	assert.NilError(t, err)
+	assert.Equal(t, val.IsZero(), false)
+}
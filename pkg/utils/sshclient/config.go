@@ -0,0 +1,14 @@
+package sshclient
+
+import (
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+)
+
+type Config struct {
+	Host       string               `json:"host"`
+	Port       uint16               `json:"port" default:"22"`
+	User       string               `json:"user"`
+	PrivateKey string               `json:"private_key"` // path to the private key (key-based authentication only)
+	KnownHosts string               `json:"known_hosts"` // path to a known_hosts file used to verify the host key
+	Timeout    customtypes.Duration `json:"timeout" default:"10s"`
+}
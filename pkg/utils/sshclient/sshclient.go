@@ -0,0 +1,158 @@
+package sshclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	ErrPrivateKey = errors.New("unable to load private key")
+	ErrKnownHosts = errors.New("unable to load known_hosts")
+	ErrCommand    = errors.New("remote command failed")
+)
+
+// SSH client connecting lazily to a remote host, reconnecting when the connection is lost.
+type Client struct {
+	address      string
+	clientConfig *ssh.ClientConfig
+
+	mutex  sync.Mutex
+	client *ssh.Client
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	keyBytes, err := os.ReadFile(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPrivateKey, err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPrivateKey, err)
+	}
+
+	hostKeyCallback, err := knownhosts.New(cfg.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKnownHosts, err)
+	}
+
+	return &Client{
+		address: net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		clientConfig: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         cfg.Timeout.AsDuration(),
+		},
+	}, nil
+}
+
+func (c *Client) getClient() (*ssh.Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == nil {
+		client, err := ssh.Dial("tcp", c.address, c.clientConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
+
+// drop the connection if it is still the current one, next call will reconnect
+func (c *Client) resetClient(client *ssh.Client) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == client {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+func (c *Client) newSession() (*ssh.Session, error) {
+	client, err := c.getClient()
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		// Connection is probably broken, retry once with a fresh one
+		c.resetClient(client)
+		client, err = c.getClient()
+		if err != nil {
+			return nil, err
+		}
+		session, err = client.NewSession()
+	}
+	return session, err
+}
+
+// Run a command on the remote host and return its standard output.
+func (c *Client) Run(ctx context.Context, command string) ([]byte, error) {
+	session, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = session.Close() }()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err = <-done:
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%v': %v (%v)", ErrCommand, command, err, string(bytes.TrimSpace(stderr.Bytes())))
+	}
+	return stdout.Bytes(), nil
+}
+
+// Open a connection from the remote host (ie. "unix", "/var/run/docker.sock").
+func (c *Client) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := c.getClient()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, address)
+	var openChannelErr *ssh.OpenChannelError
+	if err != nil && !errors.As(err, &openChannelErr) && ctx.Err() == nil {
+		// Rejected channels (ie. missing socket) are fine, anything else means the connection is broken
+		c.resetClient(client)
+	}
+	return conn, err
+}
+
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// Quote a string to be used as a single argument of a remote shell command.
+func Quote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
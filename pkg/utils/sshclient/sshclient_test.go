@@ -0,0 +1,205 @@
+package sshclient_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/configmapper/customtypes"
+	"github.com/mcarbonne/minimal-server-monitoring/v2/pkg/utils/sshclient"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gotest.tools/v3/assert"
+)
+
+// In-process SSH server: runs commands from a static map, echoes forwarded unix sockets.
+type testServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	commands map[string]string
+}
+
+func newTestServer(t *testing.T, authorizedKey ssh.PublicKey, commands map[string]string) (*testServer, ssh.PublicKey) {
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivateKey)
+	assert.NilError(t, err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &testServer{listener: listener, config: config, commands: commands}
+	go server.serve()
+	return server, hostSigner.PublicKey()
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(requests)
+			for newChannel := range channels {
+				go s.handleChannel(newChannel)
+			}
+		}()
+	}
+}
+
+func (s *testServer) handleChannel(newChannel ssh.NewChannel) {
+	switch newChannel.ChannelType() {
+	case "session":
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = channel.Close() }()
+		for req := range requests {
+			if req.Type != "exec" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			var payload struct{ Command string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			_ = req.Reply(true, nil)
+
+			status := uint32(0)
+			if output, ok := s.commands[payload.Command]; ok {
+				_, _ = io.WriteString(channel, output)
+			} else {
+				_, _ = io.WriteString(channel.Stderr(), "command not found")
+				status = 127
+			}
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		}
+	case "direct-streamlocal@openssh.com":
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(requests)
+		_, _ = io.Copy(channel, channel)
+		_ = channel.Close()
+	default:
+		_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+	}
+}
+
+func setup(t *testing.T, commands map[string]string) sshclient.Config {
+	dir := t.TempDir()
+
+	_, clientPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	pemBlock, err := ssh.MarshalPrivateKey(clientPrivateKey, "")
+	assert.NilError(t, err)
+	keyPath := filepath.Join(dir, "id_ed25519")
+	assert.NilError(t, os.WriteFile(keyPath, pem.EncodeToMemory(pemBlock), 0600))
+	clientSigner, err := ssh.NewSignerFromKey(clientPrivateKey)
+	assert.NilError(t, err)
+
+	server, hostPublicKey := newTestServer(t, clientSigner.PublicKey(), commands)
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	assert.NilError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	assert.NilError(t, err)
+
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.listener.Addr().String())}, hostPublicKey)
+	assert.NilError(t, os.WriteFile(knownHostsPath, []byte(line+"\n"), 0600))
+
+	return sshclient.Config{
+		Host:       host,
+		Port:       uint16(portNumber),
+		User:       "monitoring",
+		PrivateKey: keyPath,
+		KnownHosts: knownHostsPath,
+		Timeout:    customtypes.Duration(5 * time.Second),
+	}
+}
+
+func TestRun(t *testing.T) {
+	cfg := setup(t, map[string]string{"echo hello": "hello\n"})
+
+	client, err := sshclient.NewClient(cfg)
+	assert.NilError(t, err)
+	defer func() { _ = client.Close() }()
+
+	output, err := client.Run(context.Background(), "echo hello")
+	assert.NilError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+
+	_, err = client.Run(context.Background(), "unknown")
+	assert.Assert(t, errors.Is(err, sshclient.ErrCommand))
+	assert.ErrorContains(t, err, "command not found")
+
+	// Connection is re-established after being closed
+	assert.NilError(t, client.Close())
+	output, err = client.Run(context.Background(), "echo hello")
+	assert.NilError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+}
+
+func TestDialUnixSocket(t *testing.T) {
+	cfg := setup(t, map[string]string{})
+
+	client, err := sshclient.NewClient(cfg)
+	assert.NilError(t, err)
+	defer func() { _ = client.Close() }()
+
+	conn, err := client.DialContext(context.Background(), "unix", "/var/run/docker.sock")
+	assert.NilError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("ping"))
+	assert.NilError(t, err)
+	buffer := make([]byte, 4)
+	_, err = io.ReadFull(conn, buffer)
+	assert.NilError(t, err)
+	assert.Equal(t, "ping", string(buffer))
+}
+
+func TestUnknownHostKey(t *testing.T) {
+	cfg := setup(t, map[string]string{"echo hello": "hello\n"})
+
+	// known_hosts entry for another key
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	sshPublicKey, err := ssh.NewPublicKey(otherPublicKey)
+	assert.NilError(t, err)
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))))}, sshPublicKey)
+	assert.NilError(t, os.WriteFile(cfg.KnownHosts, []byte(line+"\n"), 0600))
+
+	client, err := sshclient.NewClient(cfg)
+	assert.NilError(t, err)
+
+	_, err = client.Run(context.Background(), "echo hello")
+	var keyErr *knownhosts.KeyError
+	assert.Assert(t, errors.As(err, &keyErr))
+}